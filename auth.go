@@ -0,0 +1,246 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// authCacheTTL is how long the results of TokenReviews and
+	// SubjectAccessReviews are reused, so that every scrape does not cause
+	// requests to the API server.
+	authCacheTTL = time.Minute
+	// authCacheNegativeTTL is how long denials are reused. It is shorter,
+	// so that granted access takes effect soon, but still spares the API
+	// server a review for every request with an invalid token.
+	authCacheNegativeTTL = 10 * time.Second
+	// authCacheSize bounds the number of cached results of each kind. The
+	// least recently used results are dropped first.
+	authCacheSize = 1024
+)
+
+// serverAuth serves the HTTP servers over TLS and authenticates and
+// authorizes their requests.
+type serverAuth struct {
+	certFile string
+	keyFile  string
+	// clientCAs verify client certificates. Without tokenReview a verified
+	// client certificate is required for every request.
+	clientCAs *x509.CertPool
+	// tokenReview enables authentication with client certificates or bearer
+	// tokens checked with TokenReviews. Authenticated users are authorized
+	// with SubjectAccessReviews for get on nonResourceURL.
+	tokenReview    bool
+	nonResourceURL string
+	kubeClient     clientset.Interface
+
+	// authenticated caches the results of TokenReviews by the hash of the
+	// token, authorized those of SubjectAccessReviews by user.
+	authenticated *cache.LRUExpireCache
+	authorized    *cache.LRUExpireCache
+}
+
+type authCacheEntry struct {
+	user authenticationv1.UserInfo
+	ok   bool
+}
+
+func newServerAuth(c config, kubeClient clientset.Interface) (*serverAuth, error) {
+	a := &serverAuth{
+		certFile:       c.TLSCertFile,
+		keyFile:        c.TLSPrivateKeyFile,
+		tokenReview:    c.AuthTokenReview,
+		nonResourceURL: c.AuthNonResourceURL,
+		kubeClient:     kubeClient,
+		authenticated:  cache.NewLRUExpireCache(authCacheSize),
+		authorized:     cache.NewLRUExpireCache(authCacheSize),
+	}
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		a.clientCAs = x509.NewCertPool()
+		if !a.clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+	}
+	if a.tokenReview && a.certFile == "" {
+		glog.Warning("Bearer tokens are accepted over plain HTTP, configure a TLS certificate to protect them")
+	}
+	return a, nil
+}
+
+// listenAndServe serves handler on address, over TLS if a certificate is
+// configured.
+func (a *serverAuth) listenAndServe(address string, handler http.Handler) error {
+	server := &http.Server{Addr: address, Handler: handler}
+	if a.certFile == "" {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = a.tlsConfig()
+	return server.ListenAndServeTLS(a.certFile, a.keyFile)
+}
+
+// tlsConfig returns the TLS config of the servers, which verifies client
+// certificates if client CAs are configured.
+func (a *serverAuth) tlsConfig() *tls.Config {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.clientCAs != nil {
+		c.ClientCAs = a.clientCAs
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		if a.tokenReview {
+			c.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return c
+}
+
+// handler wraps h so that requests are only passed on once they are
+// authenticated and authorized. Requests for the public paths are always
+// passed on.
+func (a *serverAuth) handler(h http.Handler, public ...string) http.Handler {
+	if !a.tokenReview {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range public {
+			if r.URL.Path == path {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		user, ok, err := a.authenticate(r)
+		if err != nil {
+			glog.Errorf("Authenticating request failed: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ok, err = a.authorize(user)
+		if err != nil {
+			glog.Errorf("Authorizing user %s failed: %v", user.Username, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("Forbidden (user=%s, verb=get, url=%s)", user.Username, a.nonResourceURL), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the user of a verified client certificate or of the
+// bearer token of r.
+func (a *serverAuth) authenticate(r *http.Request) (authenticationv1.UserInfo, bool, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		return authenticationv1.UserInfo{
+			Username: cert.Subject.CommonName,
+			Groups:   cert.Subject.Organization,
+		}, true, nil
+	}
+
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" || strings.TrimSpace(parts[1]) == "" {
+		return authenticationv1.UserInfo{}, false, nil
+	}
+	token := strings.TrimSpace(parts[1])
+
+	// Tokens are not kept in memory longer than needed.
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+	if e, ok := cachedAuth(a.authenticated, key); ok {
+		return e.user, e.ok, nil
+	}
+	review, err := a.kubeClient.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	})
+	if err != nil {
+		return authenticationv1.UserInfo{}, false, err
+	}
+	cacheAuth(a.authenticated, key, authCacheEntry{user: review.Status.User, ok: review.Status.Authenticated})
+	return review.Status.User, review.Status.Authenticated, nil
+}
+
+// authorize reports whether user may get the configured non-resource URL.
+func (a *serverAuth) authorize(user authenticationv1.UserInfo) (bool, error) {
+	key := fmt.Sprintf("%s/%s/%v/%v", user.Username, user.UID, user.Groups, user.Extra)
+	if e, ok := cachedAuth(a.authorized, key); ok {
+		return e.ok, nil
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := a.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: a.nonResourceURL,
+				Verb: "get",
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	cacheAuth(a.authorized, key, authCacheEntry{ok: review.Status.Allowed})
+	return review.Status.Allowed, nil
+}
+
+func cachedAuth(c *cache.LRUExpireCache, key string) (authCacheEntry, bool) {
+	e, ok := c.Get(key)
+	if !ok {
+		return authCacheEntry{}, false
+	}
+	return e.(authCacheEntry), true
+}
+
+func cacheAuth(c *cache.LRUExpireCache, key string, e authCacheEntry) {
+	ttl := authCacheTTL
+	if !e.ok {
+		ttl = authCacheNegativeTTL
+	}
+	c.Add(key, e, ttl)
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/clock"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// fakeReviewServer is an API server answering TokenReviews for the tokens
+// in users and SubjectAccessReviews allowing the users in allowed.
+type fakeReviewServer struct {
+	users   map[string]authenticationv1.UserInfo
+	allowed map[string]bool
+
+	mtx           sync.Mutex
+	tokenReviews  int
+	accessReviews []authorizationv1.SubjectAccessReviewSpec
+}
+
+func (s *fakeReviewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/apis/authentication.k8s.io/v1/tokenreviews":
+		var review authenticationv1.TokenReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.tokenReviews++
+		review.Status.User, review.Status.Authenticated = s.users[review.Spec.Token]
+		json.NewEncoder(w).Encode(review)
+	case "/apis/authorization.k8s.io/v1/subjectaccessreviews":
+		var review authorizationv1.SubjectAccessReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.accessReviews = append(s.accessReviews, review.Spec)
+		review.Status.Allowed = s.allowed[review.Spec.User]
+		json.NewEncoder(w).Encode(review)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *fakeReviewServer) reviews() (int, int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.tokenReviews, len(s.accessReviews)
+}
+
+// newTestServerAuth returns a serverAuth with TokenReviews enabled, sending
+// its reviews to s.
+func newTestServerAuth(t *testing.T, s *fakeReviewServer) (*serverAuth, func()) {
+	apiserver := httptest.NewServer(s)
+	kubeClient, err := clientset.NewForConfig(&rest.Config{Host: apiserver.URL})
+	if err != nil {
+		apiserver.Close()
+		t.Fatal(err)
+	}
+	a, err := newServerAuth(config{AuthTokenReview: true, AuthNonResourceURL: metricsPath}, kubeClient)
+	if err != nil {
+		apiserver.Close()
+		t.Fatal(err)
+	}
+	return a, apiserver.Close
+}
+
+func newReviewServer() *fakeReviewServer {
+	return &fakeReviewServer{
+		users: map[string]authenticationv1.UserInfo{
+			"alice-token": {Username: "alice", Groups: []string{"monitoring"}},
+			"bob-token":   {Username: "bob"},
+		},
+		allowed: map[string]bool{"alice": true, "carol": true},
+	}
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestServerAuthHandler(t *testing.T) {
+	s := newReviewServer()
+	a, stop := newTestServerAuth(t, s)
+	defer stop()
+	h := a.handler(okHandler, healthzPath)
+
+	cases := []struct {
+		path          string
+		authorization string
+		code          int
+		tokenReviews  int
+		accessReviews int
+	}{
+		// Requests without a bearer token are rejected without reviews.
+		{path: metricsPath, code: http.StatusUnauthorized},
+		{path: metricsPath, authorization: "Basic YWxpY2U6c2VjcmV0", code: http.StatusUnauthorized},
+		{path: metricsPath, authorization: "Bearer ", code: http.StatusUnauthorized},
+		{path: metricsPath, authorization: "Bearer unknown-token", code: http.StatusUnauthorized, tokenReviews: 1},
+		{path: metricsPath, authorization: "Bearer bob-token", code: http.StatusForbidden, tokenReviews: 1, accessReviews: 1},
+		{path: metricsPath, authorization: "Bearer alice-token", code: http.StatusOK, tokenReviews: 1, accessReviews: 1},
+		// The health check is reachable without authentication.
+		{path: healthzPath, code: http.StatusOK},
+		{path: healthzPath, authorization: "Bearer unknown-token", code: http.StatusOK},
+	}
+
+	for i, c := range cases {
+		// Start every case with empty caches.
+		a.authenticated = cache.NewLRUExpireCache(authCacheSize)
+		a.authorized = cache.NewLRUExpireCache(authCacheSize)
+		tokenReviews, accessReviews := s.reviews()
+
+		r := httptest.NewRequest("GET", c.path, nil)
+		if c.authorization != "" {
+			r.Header.Set("Authorization", c.authorization)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != c.code {
+			t.Errorf("case %d: expected status %d, got %d", i, c.code, w.Code)
+		}
+		gotTokenReviews, gotAccessReviews := s.reviews()
+		if gotTokenReviews-tokenReviews != c.tokenReviews || gotAccessReviews-accessReviews != c.accessReviews {
+			t.Errorf("case %d: expected %d token and %d access reviews, got %d and %d", i, c.tokenReviews, c.accessReviews, gotTokenReviews-tokenReviews, gotAccessReviews-accessReviews)
+		}
+	}
+
+	want := authorizationv1.SubjectAccessReviewSpec{
+		User:                  "alice",
+		Groups:                []string{"monitoring"},
+		NonResourceAttributes: &authorizationv1.NonResourceAttributes{Path: metricsPath, Verb: "get"},
+	}
+	if got := s.accessReviews[len(s.accessReviews)-1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected access review %+v, got %+v", want, got)
+	}
+}
+
+func TestServerAuthCache(t *testing.T) {
+	s := newReviewServer()
+	a, stop := newTestServerAuth(t, s)
+	defer stop()
+	h := a.handler(okHandler)
+
+	get := func(token string) int {
+		r := httptest.NewRequest("GET", metricsPath, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Decisions are reused within the TTL, denials included.
+	for i := 0; i < 3; i++ {
+		if code := get("alice-token"); code != http.StatusOK {
+			t.Errorf("request %d of alice: expected status %d, got %d", i, http.StatusOK, code)
+		}
+		if code := get("unknown-token"); code != http.StatusUnauthorized {
+			t.Errorf("request %d with an unknown token: expected status %d, got %d", i, http.StatusUnauthorized, code)
+		}
+	}
+	if tokenReviews, accessReviews := s.reviews(); tokenReviews != 2 || accessReviews != 1 {
+		t.Errorf("expected 2 token and 1 access review, got %d and %d", tokenReviews, accessReviews)
+	}
+
+	// Tokens are only cached by their hash.
+	for _, k := range a.authenticated.Keys() {
+		if k == "alice-token" || k == "unknown-token" {
+			t.Errorf("expected tokens to be cached by their hash, found %q", k)
+		}
+	}
+
+	// Denials expire before the other decisions.
+	fakeClock := clock.NewFakeClock(time.Now())
+	a.authenticated = cache.NewLRUExpireCacheWithClock(authCacheSize, fakeClock)
+	a.authorized = cache.NewLRUExpireCacheWithClock(authCacheSize, fakeClock)
+	get("alice-token")
+	get("unknown-token")
+	fakeClock.Step(authCacheNegativeTTL + time.Second)
+	get("alice-token")
+	get("unknown-token")
+	if tokenReviews, accessReviews := s.reviews(); tokenReviews != 5 || accessReviews != 2 {
+		t.Errorf("expected 5 token and 2 access reviews after denials expired, got %d and %d", tokenReviews, accessReviews)
+	}
+
+	// Expired decisions are reviewed again.
+	fakeClock.Step(authCacheTTL)
+	if code := get("alice-token"); code != http.StatusOK {
+		t.Errorf("request of alice after expiry: expected status %d, got %d", http.StatusOK, code)
+	}
+	if tokenReviews, accessReviews := s.reviews(); tokenReviews != 6 || accessReviews != 3 {
+		t.Errorf("expected 6 token and 3 access reviews after expiry, got %d and %d", tokenReviews, accessReviews)
+	}
+
+	// The least recently used decisions are dropped once the cache is full.
+	a.authenticated = cache.NewLRUExpireCache(2)
+	for _, token := range []string{"alice-token", "bob-token", "unknown-token"} {
+		get(token)
+	}
+	if n := len(a.authenticated.Keys()); n != 2 {
+		t.Errorf("expected 2 cached tokens, got %d", n)
+	}
+	get("alice-token")
+	if tokenReviews, _ := s.reviews(); tokenReviews != 10 {
+		t.Errorf("expected the evicted token to be reviewed again, got %d token reviews", tokenReviews)
+	}
+}
+
+func TestServerAuthClientCertificate(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "carol", Organization: []string{"ops", "monitoring"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newReviewServer()
+	a, stop := newTestServerAuth(t, s)
+	defer stop()
+	a.clientCAs = x509.NewCertPool()
+	a.clientCAs.AddCert(ca)
+
+	server := httptest.NewUnstartedServer(a.handler(okHandler))
+	server.TLS = a.tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	serverTransport := server.Client().Transport.(*http.Transport)
+	get := func(certs []tls.Certificate) int {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      serverTransport.TLSClientConfig.RootCAs,
+			Certificates: certs,
+		}}
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + metricsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The user is taken from the verified client certificate, without a
+	// TokenReview.
+	if code := get([]tls.Certificate{{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}}); code != http.StatusOK {
+		t.Errorf("expected status %d with a client certificate, got %d", http.StatusOK, code)
+	}
+	if tokenReviews, accessReviews := s.reviews(); tokenReviews != 0 || accessReviews != 1 {
+		t.Fatalf("expected 0 token and 1 access review, got %d and %d", tokenReviews, accessReviews)
+	}
+	if got := s.accessReviews[0]; got.User != "carol" || !reflect.DeepEqual(got.Groups, []string{"ops", "monitoring"}) {
+		t.Errorf("expected the access review of carol in groups ops and monitoring, got %s in %v", got.User, got.Groups)
+	}
+
+	// Without a client certificate or token the request is unauthorized.
+	if code := get(nil); code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a client certificate, got %d", http.StatusUnauthorized, code)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package builder sets up the kube-state-metrics collectors, so that other
+// programs can expose the same metrics without running kube-state-metrics.
+package builder
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"k8s.io/kube-state-metrics/collectors"
+)
+
+var (
+	// AvailableCollectors holds the register functions of all built-in
+	// collectors by name.
+	AvailableCollectors = map[string]collectors.RegisterFunc{
+		"cronjobs":                 collectors.RegisterCronJobCollector,
+		"daemonsets":               collectors.RegisterDaemonSetCollector,
+		"deployments":              collectors.RegisterDeploymentCollector,
+		"jobs":                     collectors.RegisterJobCollector,
+		"limitranges":              collectors.RegisterLimitRangeCollector,
+		"nodes":                    collectors.RegisterNodeCollector,
+		"pods":                     collectors.RegisterPodCollector,
+		"poddisruptionbudgets":     collectors.RegisterPodDisruptionBudgetCollector,
+		"replicasets":              collectors.RegisterReplicaSetCollector,
+		"replicationcontrollers":   collectors.RegisterReplicationControllerCollector,
+		"resourcequotas":           collectors.RegisterResourceQuotaCollector,
+		"rolebindings":             collectors.RegisterRoleBindingCollector,
+		"roles":                    collectors.RegisterRoleCollector,
+		"services":                 collectors.RegisterServiceCollector,
+		"statefulsets":             collectors.RegisterStatefulSetCollector,
+		"storageclasses":           collectors.RegisterStorageClassCollector,
+		"persistentvolumes":        collectors.RegisterPersistentVolumeCollector,
+		"persistentvolumeclaims":   collectors.RegisterPersistentVolumeClaimCollector,
+		"namespaces":               collectors.RegisterNamespaceCollector,
+		"networkpolicies":          collectors.RegisterNetworkPolicyCollector,
+		"horizontalpodautoscalers": collectors.RegisterHorizontalPodAutoScalerCollector,
+		"endpoints":                collectors.RegisterEndpointCollector,
+		"events":                   collectors.RegisterEventCollector,
+		"secrets":                  collectors.RegisterSecretCollector,
+		"clusterrolebindings":      collectors.RegisterClusterRoleBindingCollector,
+		"clusterroles":             collectors.RegisterClusterRoleCollector,
+		"configmaps":               collectors.RegisterConfigMapCollector,
+		"ingresses":                collectors.RegisterIngressCollector,
+	}
+
+	// DefaultCollectors are the collectors enabled unless others are
+	// selected. The events collector and the RBAC collectors, exposing the
+	// names of users and groups, are only enabled on request.
+	DefaultCollectors = []string{
+		"configmaps",
+		"cronjobs",
+		"daemonsets",
+		"deployments",
+		"endpoints",
+		"horizontalpodautoscalers",
+		"ingresses",
+		"jobs",
+		"limitranges",
+		"namespaces",
+		"networkpolicies",
+		"nodes",
+		"persistentvolumeclaims",
+		"persistentvolumes",
+		"poddisruptionbudgets",
+		"pods",
+		"replicasets",
+		"replicationcontrollers",
+		"resourcequotas",
+		"secrets",
+		"services",
+		"statefulsets",
+		"storageclasses",
+	}
+)
+
+// Builder sets up a collectors.Registry with the selected collectors. The
+// Registry serves the metrics as an http.Handler, and can be registered
+// with a prometheus.Registry as a prometheus.Collector.
+type Builder struct {
+	kubeClient kubernetes.Interface
+	namespaces []string
+	enabled    []string
+	stopCh     <-chan struct{}
+	opts       *collectors.Options
+	custom     map[string]collectors.RegisterFunc
+}
+
+// NewBuilder returns a Builder enabling the default collectors in all
+// namespaces.
+func NewBuilder() *Builder {
+	return &Builder{
+		namespaces: []string{metav1.NamespaceAll},
+		enabled:    DefaultCollectors,
+		custom:     map[string]collectors.RegisterFunc{},
+	}
+}
+
+// WithKubeClient sets the client the collectors watch the objects with.
+func (b *Builder) WithKubeClient(kubeClient kubernetes.Interface) *Builder {
+	b.kubeClient = kubeClient
+	return b
+}
+
+// WithNamespaces sets the namespaces the objects are watched in.
+func (b *Builder) WithNamespaces(namespaces []string) *Builder {
+	b.namespaces = namespaces
+	return b
+}
+
+// WithEnabledCollectors selects the built-in collectors by name.
+func (b *Builder) WithEnabledCollectors(enabled []string) *Builder {
+	b.enabled = enabled
+	return b
+}
+
+// WithStopChannel sets a channel that stops all collectors once it is
+// closed. Without it the collectors run until the program exits.
+func (b *Builder) WithStopChannel(stopCh <-chan struct{}) *Builder {
+	b.stopCh = stopCh
+	return b
+}
+
+// WithOptions sets the options all collectors are set up with.
+func (b *Builder) WithOptions(opts *collectors.Options) *Builder {
+	b.opts = opts
+	return b
+}
+
+// WithCustomCollector adds a collector that is enabled in addition to the
+// selected built-in collectors. register typically creates the informers
+// with collectors.NewSharedInformerList and registers its collector with
+// Registry.MustRegisterStore. Collectors that do not watch any objects are
+// registered with Registry.MustRegisterCollector. Collectors registered
+// with Registry.MustRegister are not served.
+func (b *Builder) WithCustomCollector(name string, register collectors.RegisterFunc) *Builder {
+	b.custom[name] = register
+	return b
+}
+
+// Build registers the collectors with a new collectors.Registry and starts
+// their informers. Built-in collectors whose resource the API server does
+// not serve are skipped.
+func (b *Builder) Build() (*collectors.Registry, error) {
+	if b.kubeClient == nil {
+		return nil, fmt.Errorf("no kubernetes client set")
+	}
+	registers := map[string]collectors.RegisterFunc{}
+	for _, name := range b.enabled {
+		register, ok := AvailableCollectors[name]
+		if !ok {
+			return nil, fmt.Errorf("collector %q does not exist", name)
+		}
+		registers[name] = register
+	}
+	for name, register := range b.custom {
+		if _, ok := AvailableCollectors[name]; ok {
+			return nil, fmt.Errorf("custom collector %q conflicts with a built-in collector", name)
+		}
+		registers[name] = register
+	}
+
+	opts := &collectors.Options{}
+	if b.opts != nil {
+		*opts = *b.opts
+	}
+	if opts.APIResources == nil {
+		apiResources, err := collectors.DiscoverAPIResources(b.kubeClient.Discovery())
+		if err != nil {
+			glog.Warningf("Discovering the served API resources failed: %v", err)
+		}
+		if len(apiResources) > 0 {
+			opts.APIResources = apiResources
+		}
+	}
+	namespaces := b.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	names := make([]string, 0, len(registers))
+	for name := range registers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	registry := collectors.NewRegistry()
+	for _, name := range names {
+		if _, builtin := AvailableCollectors[name]; builtin && opts.APIResources != nil {
+			if _, served := opts.APIResources.ServedVersion(name); !served {
+				glog.Warningf("Skipping collector %s, the API server does not serve %s in any supported version", name, name)
+				continue
+			}
+		}
+		register := registers[name]
+		registry.Enable(name, func(r *collectors.Registry) {
+			register(r, b.kubeClient, namespaces, opts)
+		})
+	}
+
+	if b.stopCh != nil {
+		go func() {
+			<-b.stopCh
+			for _, name := range registry.Enabled() {
+				registry.Disable(name)
+			}
+		}()
+	}
+	return registry, nil
+}
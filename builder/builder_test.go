@@ -0,0 +1,146 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"k8s.io/kube-state-metrics/collectors"
+)
+
+var descCustom = prometheus.NewDesc("custom_metric", "A custom metric.", nil, nil)
+
+type customCollector struct{}
+
+func (customCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descCustom
+}
+
+func (customCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(descCustom, prometheus.GaugeValue, 1)
+}
+
+func TestBuildErrors(t *testing.T) {
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := func(*collectors.Registry, kubernetes.Interface, []string, *collectors.Options) {}
+
+	cases := []*Builder{
+		NewBuilder(),
+		NewBuilder().WithKubeClient(kubeClient).WithEnabledCollectors([]string{"nonexisting"}),
+		NewBuilder().WithKubeClient(kubeClient).WithEnabledCollectors([]string{}).WithCustomCollector("pods", register),
+	}
+	for i, b := range cases {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestBuildCustomCollector(t *testing.T) {
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	var gotNamespaces []string
+	r, err := NewBuilder().
+		WithKubeClient(kubeClient).
+		WithEnabledCollectors([]string{}).
+		WithNamespaces([]string{"ns1", "ns2"}).
+		WithOptions(&collectors.Options{APIResources: collectors.APIResources{}}).
+		WithStopChannel(stopCh).
+		WithCustomCollector("custom", func(registry *collectors.Registry, _ kubernetes.Interface, namespaces []string, opts *collectors.Options) {
+			gotNamespaces = namespaces
+			registry.MustRegisterStore("custom", customCollector{}, &collectors.SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {}, opts)
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("building failed: %s", err)
+	}
+
+	if want := []string{"ns1", "ns2"}; !reflect.DeepEqual(gotNamespaces, want) {
+		t.Errorf("expected namespaces %v, got %v", want, gotNamespaces)
+	}
+	if want := []string{"custom"}; !reflect.DeepEqual(r.Enabled(), want) {
+		t.Errorf("expected enabled collectors %v, got %v", want, r.Enabled())
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(r); err != nil {
+		t.Fatalf("registering failed: %s", err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering failed: %s", err)
+	}
+	if len(mfs) != 1 || mfs[0].GetName() != "custom_metric" {
+		t.Errorf("unexpected metric families %v", mfs)
+	}
+}
+
+func TestBuildUnwatchedCollector(t *testing.T) {
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "custom_gauge", Help: "A custom gauge."})
+	r, err := NewBuilder().
+		WithKubeClient(kubeClient).
+		WithEnabledCollectors([]string{}).
+		WithOptions(&collectors.Options{APIResources: collectors.APIResources{}}).
+		WithStopChannel(stopCh).
+		WithCustomCollector("custom", func(registry *collectors.Registry, _ kubernetes.Interface, _ []string, opts *collectors.Options) {
+			registry.MustRegisterCollector("custom", gauge, opts)
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("building failed: %s", err)
+	}
+
+	for _, value := range []float64{1, 2} {
+		gauge.Set(value)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		want := fmt.Sprintf("# HELP custom_gauge A custom gauge.\n# TYPE custom_gauge gauge\ncustom_gauge %v\n", value)
+		if got := w.Body.String(); got != want {
+			t.Errorf("expected output:\n%s\ngot:\n%s", want, got)
+		}
+
+		mfs, err := r.Gather()
+		if err != nil {
+			t.Fatalf("gathering failed: %s", err)
+		}
+		if len(mfs) != 1 || mfs[0].GetName() != "custom_gauge" || mfs[0].Metric[0].GetGauge().GetValue() != value {
+			t.Errorf("unexpected metric families %v", mfs)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// allowAll is the allowlist entry allowing all keys of a resource.
+const allowAll = "*"
+
+// allowedLabels returns the Kubernetes labels of an object of the resource
+// that are exported as Prometheus labels. Without a labels allowlist all
+// labels are exported, otherwise only those listed for the resource.
+func (o *Options) allowedLabels(resource string, labels map[string]string) map[string]string {
+	if o == nil || o.LabelsAllowList == nil {
+		return labels
+	}
+	return filterAllowed(o.LabelsAllowList[resource], labels)
+}
+
+func filterAllowed(allowed []string, m map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for _, k := range allowed {
+		if k == allowAll {
+			return m
+		}
+		if v, ok := m[k]; ok {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// allowedAnnotations returns the Kubernetes annotations of an object of the
+// resource that are exported as Prometheus labels, and whether the resource
+// exports annotations at all. Annotations are only exported for resources
+// listed in the annotations allowlist.
+func (o *Options) allowedAnnotations(resource string, annotations map[string]string) (map[string]string, bool) {
+	if o == nil {
+		return nil, false
+	}
+	allowed, ok := o.AnnotationsAllowList[resource]
+	if !ok {
+		return nil, false
+	}
+	return filterAllowed(allowed, annotations), true
+}
+
+// stripMetadata drops the labels and annotations of an object of the
+// resource that are not exported, so that they are not cached.
+func (o *Options) stripMetadata(resource string, m *metav1.ObjectMeta) {
+	m.Labels = o.allowedLabels(resource, m.Labels)
+	m.Annotations, _ = o.allowedAnnotations(resource, m.Annotations)
+}
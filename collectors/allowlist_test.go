@@ -0,0 +1,146 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLabelsAllowList(t *testing.T) {
+	services := []v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-service1",
+				Namespace: "default",
+				Labels: map[string]string{
+					"app":  "example1",
+					"team": "a",
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		opts *Options
+		want string
+	}{
+		{
+			opts: nil,
+			want: `kube_service_labels{label_app="example1",label_team="a",namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{LabelsAllowList: map[string][]string{"services": {"team", "missing"}}},
+			want: `kube_service_labels{label_team="a",namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{LabelsAllowList: map[string][]string{"services": {"*"}}},
+			want: `kube_service_labels{label_app="example1",label_team="a",namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{LabelsAllowList: map[string][]string{"pods": {"*"}}},
+			want: `kube_service_labels{namespace="default",service="test-service1"} 1`,
+		},
+	}
+	for _, c := range cases {
+		sc := &serviceCollector{
+			store: &mockServiceStore{
+				list: func() ([]v1.Service, error) {
+					return services, nil
+				},
+			},
+			opts: c.opts,
+		}
+		want := `
+			# HELP kube_service_labels Kubernetes labels converted to Prometheus labels.
+			# TYPE kube_service_labels gauge
+			` + c.want
+		if err := gatherAndCompare(sc, want, []string{"kube_service_labels"}); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
+
+func TestAnnotationsAllowList(t *testing.T) {
+	services := []v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-service1",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"owner":       "team-a",
+					"cost-center": "1234",
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		opts *Options
+		want string
+	}{
+		{
+			opts: nil,
+			want: `
+				kube_service_labels{namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"pods": {"*"}}},
+			want: `
+				kube_service_labels{namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"services": {"cost-center"}}},
+			want: `
+				kube_service_labels{namespace="default",service="test-service1"} 1
+				kube_service_annotations{annotation_cost_center="1234",namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"services": {"*"}}},
+			want: `
+				kube_service_labels{namespace="default",service="test-service1"} 1
+				kube_service_annotations{annotation_cost_center="1234",annotation_owner="team-a",namespace="default",service="test-service1"} 1`,
+		},
+		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"services": {}}},
+			want: `
+				kube_service_labels{namespace="default",service="test-service1"} 1
+				kube_service_annotations{namespace="default",service="test-service1"} 1`,
+		},
+	}
+	for _, c := range cases {
+		sc := &serviceCollector{
+			store: &mockServiceStore{
+				list: func() ([]v1.Service, error) {
+					return services, nil
+				},
+			},
+			opts: c.opts,
+		}
+		want := `
+			# HELP kube_service_annotations Kubernetes annotations converted to Prometheus labels.
+			# TYPE kube_service_annotations gauge
+			# HELP kube_service_labels Kubernetes labels converted to Prometheus labels.
+			# TYPE kube_service_labels gauge
+			` + c.want
+		if err := gatherAndCompare(sc, want, []string{"kube_service_annotations", "kube_service_labels"}); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descClusterRoleLabelsName          = "kube_clusterrole_labels"
+	descClusterRoleLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descClusterRoleLabelsDefaultLabels = []string{"clusterrole"}
+
+	descClusterRoleAnnotationsName          = "kube_clusterrole_annotations"
+	descClusterRoleAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descClusterRoleAnnotationsDefaultLabels = []string{"clusterrole"}
+
+	descClusterRoleCreated = prometheus.NewDesc(
+		"kube_clusterrole_created",
+		"Unix creation timestamp",
+		[]string{"clusterrole"}, nil,
+	)
+
+	descClusterRoleRules = prometheus.NewDesc(
+		"kube_clusterrole_rules",
+		"Number of policy rules of the clusterrole.",
+		[]string{"clusterrole"}, nil,
+	)
+
+	descClusterRoleWildcardVerbs = prometheus.NewDesc(
+		"kube_clusterrole_wildcard_verbs",
+		"Whether a rule of the clusterrole grants all verbs.",
+		[]string{"clusterrole"}, nil,
+	)
+
+	descClusterRoleWildcardResources = prometheus.NewDesc(
+		"kube_clusterrole_wildcard_resources",
+		"Whether a rule of the clusterrole grants access to all resources.",
+		[]string{"clusterrole"}, nil,
+	)
+
+	descClusterRoleLabels = prometheus.NewDesc(
+		descClusterRoleLabelsName,
+		descClusterRoleLabelsHelp,
+		descClusterRoleLabelsDefaultLabels, nil,
+	)
+
+	descClusterRoleAnnotations = prometheus.NewDesc(
+		descClusterRoleAnnotationsName,
+		descClusterRoleAnnotationsHelp,
+		descClusterRoleAnnotationsDefaultLabels, nil,
+	)
+)
+
+type ClusterRoleLister func() ([]rbacv1.ClusterRole, error)
+
+func (l ClusterRoleLister) List() ([]rbacv1.ClusterRole, error) {
+	return l()
+}
+
+func RegisterClusterRoleCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	crinfs, err := newVersionedInformerList(kubeClient, "clusterroles", []string{v1.NamespaceAll}, &rbacv1.ClusterRole{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch clusterroles: %v", err)
+		return
+	}
+
+	clusterRoleLister := ClusterRoleLister(func() (clusterRoles []rbacv1.ClusterRole, err error) {
+		for _, crinf := range *crinfs {
+			for _, m := range crinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				clusterRoles = append(clusterRoles, *m.(*rbacv1.ClusterRole))
+			}
+		}
+		return clusterRoles, nil
+	})
+
+	crc := &clusterRoleCollector{store: clusterRoleLister, opts: opts}
+	registry.mustRegister("clusterrole", crc, crinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		crc.collectClusterRole(ch, *obj.(*rbacv1.ClusterRole))
+	}, opts)
+}
+
+type clusterRoleStore interface {
+	List() (clusterRoles []rbacv1.ClusterRole, err error)
+}
+
+// clusterRoleCollector collects metrics about all cluster roles in the cluster.
+type clusterRoleCollector struct {
+	store clusterRoleStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (crc *clusterRoleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descClusterRoleCreated
+	ch <- descClusterRoleRules
+	ch <- descClusterRoleWildcardVerbs
+	ch <- descClusterRoleWildcardResources
+	ch <- descClusterRoleLabels
+	ch <- descClusterRoleAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (crc *clusterRoleCollector) Collect(ch chan<- prometheus.Metric) {
+	clusterRoles, err := crc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "clusterrole"}).Inc()
+		glog.Errorf("listing clusterroles failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "clusterrole"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "clusterrole"}).Observe(float64(len(clusterRoles)))
+	for _, cr := range clusterRoles {
+		crc.collectClusterRole(ch, cr)
+	}
+
+	glog.V(4).Infof("collected %d clusterroles", len(clusterRoles))
+}
+
+func clusterRoleLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descClusterRoleLabelsName,
+		descClusterRoleLabelsHelp,
+		append(descClusterRoleLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func clusterRoleAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descClusterRoleAnnotationsName,
+		descClusterRoleAnnotationsHelp,
+		append(descClusterRoleAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (crc *clusterRoleCollector) collectClusterRole(ch chan<- prometheus.Metric, cr rbacv1.ClusterRole) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !crc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{cr.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	if !cr.CreationTimestamp.IsZero() {
+		addGauge(descClusterRoleCreated, float64(cr.CreationTimestamp.Unix()))
+	}
+	addGauge(descClusterRoleRules, float64(len(cr.Rules)))
+	wildcardVerbs, wildcardResources := policyRulesWildcards(cr.Rules)
+	addGauge(descClusterRoleWildcardVerbs, boolFloat64(wildcardVerbs))
+	addGauge(descClusterRoleWildcardResources, boolFloat64(wildcardResources))
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(crc.opts.allowedLabels("clusterroles", cr.Labels))
+	addGauge(clusterRoleLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := crc.opts.allowedAnnotations("clusterroles", cr.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(clusterRoleAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
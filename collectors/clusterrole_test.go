@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockClusterRoleStore struct {
+	list func() ([]rbacv1.ClusterRole, error)
+}
+
+func (cs mockClusterRoleStore) List() ([]rbacv1.ClusterRole, error) {
+	return cs.list()
+}
+
+func TestClusterRoleCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_clusterrole_created Unix creation timestamp
+		# TYPE kube_clusterrole_created gauge
+		# HELP kube_clusterrole_rules Number of policy rules of the clusterrole.
+		# TYPE kube_clusterrole_rules gauge
+		# HELP kube_clusterrole_wildcard_verbs Whether a rule of the clusterrole grants all verbs.
+		# TYPE kube_clusterrole_wildcard_verbs gauge
+		# HELP kube_clusterrole_wildcard_resources Whether a rule of the clusterrole grants access to all resources.
+		# TYPE kube_clusterrole_wildcard_resources gauge
+		# HELP kube_clusterrole_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_clusterrole_labels gauge
+		# HELP kube_clusterrole_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_clusterrole_annotations gauge
+	`
+	cases := []struct {
+		clusterRoles []rbacv1.ClusterRole
+		metrics      []string // which metrics should be checked
+		want         string
+	}{
+		{
+			clusterRoles: []rbacv1.ClusterRole{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "cluster-admin",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Labels: map[string]string{
+							"kubernetes.io/bootstrapping": "rbac-defaults",
+						},
+					},
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+						{NonResourceURLs: []string{"*"}, Verbs: []string{"*"}},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "node-reader",
+					},
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get", "list"}},
+					},
+				},
+			},
+			want: metadata + `
+				kube_clusterrole_created{clusterrole="cluster-admin"} 1.5e+09
+				kube_clusterrole_rules{clusterrole="cluster-admin"} 2
+				kube_clusterrole_rules{clusterrole="node-reader"} 1
+				kube_clusterrole_wildcard_verbs{clusterrole="cluster-admin"} 1
+				kube_clusterrole_wildcard_verbs{clusterrole="node-reader"} 0
+				kube_clusterrole_wildcard_resources{clusterrole="cluster-admin"} 1
+				kube_clusterrole_wildcard_resources{clusterrole="node-reader"} 0
+				kube_clusterrole_labels{clusterrole="cluster-admin",label_kubernetes_io_bootstrapping="rbac-defaults"} 1
+				kube_clusterrole_labels{clusterrole="node-reader"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		crc := &clusterRoleCollector{
+			store: &mockClusterRoleStore{
+				list: func() ([]rbacv1.ClusterRole, error) {
+					return c.clusterRoles, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(crc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descClusterRoleBindingLabelsName          = "kube_clusterrolebinding_labels"
+	descClusterRoleBindingLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descClusterRoleBindingLabelsDefaultLabels = []string{"clusterrolebinding"}
+
+	descClusterRoleBindingAnnotationsName          = "kube_clusterrolebinding_annotations"
+	descClusterRoleBindingAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descClusterRoleBindingAnnotationsDefaultLabels = []string{"clusterrolebinding"}
+
+	descClusterRoleBindingInfo = prometheus.NewDesc(
+		"kube_clusterrolebinding_info",
+		"Information about clusterrolebinding.",
+		[]string{"clusterrolebinding", "role_kind", "role_name"}, nil,
+	)
+
+	descClusterRoleBindingCreated = prometheus.NewDesc(
+		"kube_clusterrolebinding_created",
+		"Unix creation timestamp",
+		[]string{"clusterrolebinding"}, nil,
+	)
+
+	descClusterRoleBindingSubject = prometheus.NewDesc(
+		"kube_clusterrolebinding_subject",
+		"Subject the clusterrolebinding grants the referenced role to.",
+		[]string{"clusterrolebinding", "role_kind", "role_name", "subject_kind", "subject_name", "subject_namespace"}, nil,
+	)
+
+	descClusterRoleBindingLabels = prometheus.NewDesc(
+		descClusterRoleBindingLabelsName,
+		descClusterRoleBindingLabelsHelp,
+		descClusterRoleBindingLabelsDefaultLabels, nil,
+	)
+
+	descClusterRoleBindingAnnotations = prometheus.NewDesc(
+		descClusterRoleBindingAnnotationsName,
+		descClusterRoleBindingAnnotationsHelp,
+		descClusterRoleBindingAnnotationsDefaultLabels, nil,
+	)
+)
+
+type ClusterRoleBindingLister func() ([]rbacv1.ClusterRoleBinding, error)
+
+func (l ClusterRoleBindingLister) List() ([]rbacv1.ClusterRoleBinding, error) {
+	return l()
+}
+
+func RegisterClusterRoleBindingCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	crbinfs, err := newVersionedInformerList(kubeClient, "clusterrolebindings", []string{v1.NamespaceAll}, &rbacv1.ClusterRoleBinding{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch clusterrolebindings: %v", err)
+		return
+	}
+
+	clusterRoleBindingLister := ClusterRoleBindingLister(func() (clusterRoleBindings []rbacv1.ClusterRoleBinding, err error) {
+		for _, crbinf := range *crbinfs {
+			for _, m := range crbinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				clusterRoleBindings = append(clusterRoleBindings, *m.(*rbacv1.ClusterRoleBinding))
+			}
+		}
+		return clusterRoleBindings, nil
+	})
+
+	crbc := &clusterRoleBindingCollector{store: clusterRoleBindingLister, opts: opts}
+	registry.mustRegister("clusterrolebinding", crbc, crbinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		crbc.collectClusterRoleBinding(ch, *obj.(*rbacv1.ClusterRoleBinding))
+	}, opts)
+}
+
+type clusterRoleBindingStore interface {
+	List() (clusterRoleBindings []rbacv1.ClusterRoleBinding, err error)
+}
+
+// clusterRoleBindingCollector collects metrics about all cluster role bindings
+// in the cluster.
+type clusterRoleBindingCollector struct {
+	store clusterRoleBindingStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (crbc *clusterRoleBindingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descClusterRoleBindingInfo
+	ch <- descClusterRoleBindingCreated
+	ch <- descClusterRoleBindingSubject
+	ch <- descClusterRoleBindingLabels
+	ch <- descClusterRoleBindingAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (crbc *clusterRoleBindingCollector) Collect(ch chan<- prometheus.Metric) {
+	clusterRoleBindings, err := crbc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "clusterrolebinding"}).Inc()
+		glog.Errorf("listing clusterrolebindings failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "clusterrolebinding"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "clusterrolebinding"}).Observe(float64(len(clusterRoleBindings)))
+	for _, crb := range clusterRoleBindings {
+		crbc.collectClusterRoleBinding(ch, crb)
+	}
+
+	glog.V(4).Infof("collected %d clusterrolebindings", len(clusterRoleBindings))
+}
+
+func clusterRoleBindingLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descClusterRoleBindingLabelsName,
+		descClusterRoleBindingLabelsHelp,
+		append(descClusterRoleBindingLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func clusterRoleBindingAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descClusterRoleBindingAnnotationsName,
+		descClusterRoleBindingAnnotationsHelp,
+		append(descClusterRoleBindingAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (crbc *clusterRoleBindingCollector) collectClusterRoleBinding(ch chan<- prometheus.Metric, crb rbacv1.ClusterRoleBinding) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !crbc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{crb.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	addGauge(descClusterRoleBindingInfo, 1, crb.RoleRef.Kind, crb.RoleRef.Name)
+	if !crb.CreationTimestamp.IsZero() {
+		addGauge(descClusterRoleBindingCreated, float64(crb.CreationTimestamp.Unix()))
+	}
+	for _, s := range uniqueSubjects(crb.Subjects) {
+		addGauge(descClusterRoleBindingSubject, 1, crb.RoleRef.Kind, crb.RoleRef.Name, s.Kind, s.Name, s.Namespace)
+	}
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(crbc.opts.allowedLabels("clusterrolebindings", crb.Labels))
+	addGauge(clusterRoleBindingLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := crbc.opts.allowedAnnotations("clusterrolebindings", crb.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(clusterRoleBindingAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockClusterRoleBindingStore struct {
+	list func() ([]rbacv1.ClusterRoleBinding, error)
+}
+
+func (cs mockClusterRoleBindingStore) List() ([]rbacv1.ClusterRoleBinding, error) {
+	return cs.list()
+}
+
+func TestClusterRoleBindingCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_clusterrolebinding_info Information about clusterrolebinding.
+		# TYPE kube_clusterrolebinding_info gauge
+		# HELP kube_clusterrolebinding_created Unix creation timestamp
+		# TYPE kube_clusterrolebinding_created gauge
+		# HELP kube_clusterrolebinding_subject Subject the clusterrolebinding grants the referenced role to.
+		# TYPE kube_clusterrolebinding_subject gauge
+		# HELP kube_clusterrolebinding_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_clusterrolebinding_labels gauge
+		# HELP kube_clusterrolebinding_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_clusterrolebinding_annotations gauge
+	`
+	cases := []struct {
+		clusterRoleBindings []rbacv1.ClusterRoleBinding
+		metrics             []string // which metrics should be checked
+		want                string
+	}{
+		{
+			clusterRoleBindings: []rbacv1.ClusterRoleBinding{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "cluster-admin",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+					},
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+					Subjects: []rbacv1.Subject{
+						{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "system:masters"},
+						{Kind: rbacv1.ServiceAccountKind, Name: "tiller", Namespace: "kube-system"},
+					},
+				},
+			},
+			want: metadata + `
+				kube_clusterrolebinding_created{clusterrolebinding="cluster-admin"} 1.5e+09
+				kube_clusterrolebinding_info{clusterrolebinding="cluster-admin",role_kind="ClusterRole",role_name="cluster-admin"} 1
+				kube_clusterrolebinding_labels{clusterrolebinding="cluster-admin"} 1
+				kube_clusterrolebinding_subject{clusterrolebinding="cluster-admin",role_kind="ClusterRole",role_name="cluster-admin",subject_kind="Group",subject_name="system:masters",subject_namespace=""} 1
+				kube_clusterrolebinding_subject{clusterrolebinding="cluster-admin",role_kind="ClusterRole",role_name="cluster-admin",subject_kind="ServiceAccount",subject_name="tiller",subject_namespace="kube-system"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		crbc := &clusterRoleBindingCollector{
+			store: &mockClusterRoleBindingStore{
+				list: func() ([]rbacv1.ClusterRoleBinding, error) {
+					return c.clusterRoleBindings, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(crbc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
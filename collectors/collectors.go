@@ -17,6 +17,8 @@ limitations under the License.
 package collectors
 
 import (
+	"regexp"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -40,4 +42,137 @@ var (
 		},
 		[]string{"resource"},
 	)
+
+	CollectDurationMetric = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ksm_collect_duration_seconds",
+			Help:    "Duration of collecting the metrics of a resource per scrape",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"resource"},
+	)
+
+	FamilySeriesMetric = &familySeriesGauge{
+		desc: prometheus.NewDesc(
+			"ksm_metric_family_series",
+			"Number of series of a metric family in the last scrape",
+			[]string{"family"}, nil,
+		),
+	}
+
+	SeriesDroppedTotalMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ksm_metric_family_series_dropped_total",
+			Help: "Total series of a metric family dropped because the family reached the series limit",
+		},
+		[]string{"family"},
+	)
+
+	ShardOrdinalMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ksm_shard_ordinal",
+			Help: "Ordinal of the shard this instance exposes metrics for",
+		},
+	)
+
+	TotalShardsMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ksm_total_shards",
+			Help: "Number of shards the objects are distributed across",
+		},
+	)
+
+	CollectorEnabledMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ksm_collector_enabled",
+			Help: "Whether a configured collector is enabled, with the reason if it is not",
+		},
+		[]string{"collector", "reason"},
+	)
+
+	ConfigLastReloadSuccessfulMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ksm_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful",
+		},
+	)
+
+	ConfigLastReloadSuccessTimestampMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ksm_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		},
+	)
 )
+
+// familySeriesGauge exposes the number of series of each metric family.
+// The numbers of all families are replaced at once, so that concurrent
+// scrapes never see a partial set.
+type familySeriesGauge struct {
+	desc *prometheus.Desc
+
+	mtx    sync.RWMutex
+	series map[string]int
+}
+
+// set replaces the number of series of all metric families.
+func (g *familySeriesGauge) set(series map[string]int) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.series = series
+}
+
+// Describe implements the prometheus.Collector interface.
+func (g *familySeriesGauge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (g *familySeriesGauge) Collect(ch chan<- prometheus.Metric) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+	for name, n := range g.series {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, float64(n), name)
+	}
+}
+
+// Options holds the settings shared by all collectors.
+type Options struct {
+	// Shard is the ordinal of the shard this instance exposes metrics for,
+	// out of TotalShards instances watching the same objects.
+	Shard       int32
+	TotalShards int
+	// LabelSelectors and FieldSelectors restrict the objects watched for a
+	// resource, keyed by the plural resource name, e.g. "pods".
+	LabelSelectors map[string]string
+	FieldSelectors map[string]string
+	// MetricAllowList and MetricDenyList select the exposed metric families
+	// by name. Nil lists do not exclude any family.
+	MetricAllowList *regexp.Regexp
+	MetricDenyList  *regexp.Regexp
+	// LabelsAllowList holds the Kubernetes label keys exported on the
+	// kube_*_labels metrics, keyed by the plural resource name. "*" allows
+	// all keys. A nil map exports all labels of all resources.
+	LabelsAllowList map[string][]string
+	// AnnotationsAllowList holds the Kubernetes annotation keys exported on
+	// the kube_*_annotations metrics, keyed by the plural resource name. "*"
+	// allows all keys. Resources that are not listed export no annotations
+	// metric at all.
+	AnnotationsAllowList map[string][]string
+	// PageSize is the number of objects requested per page when listing,
+	// 500 if it is not set.
+	PageSize int64
+	// ListTimeout bounds each list request, it is not bounded if not set.
+	ListTimeout time.Duration
+	// MaxSeriesPerFamily limits the number of series of each metric family.
+	// Series of objects beyond the limit are dropped until the objects
+	// change again. There is no limit if it is not set.
+	MaxSeriesPerFamily int
+	// EventSeriesTTL is how long the events collector keeps a series
+	// without new events, one hour if it is not set.
+	EventSeriesTTL time.Duration
+	// APIResources holds the discovered versions of the served resources,
+	// to watch each resource in the newest served version. If it is nil,
+	// every resource is watched in the version its collector is built on.
+	APIResources APIResources
+}
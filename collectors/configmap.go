@@ -19,14 +19,16 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
+	descConfigMapAnnotationsName          = "kube_configmap_annotations"
+	descConfigMapAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descConfigMapAnnotationsDefaultLabels = []string{"namespace", "configmap"}
+
 	descConfigMapInfo = prometheus.NewDesc(
 		"kube_configmap_info",
 		"Information about configmap.",
@@ -44,6 +46,12 @@ var (
 		"Resource version representing a specific version of the configmap.",
 		[]string{"namespace", "configmap", "resource_version"}, nil,
 	)
+
+	descConfigMapAnnotations = prometheus.NewDesc(
+		descConfigMapAnnotationsName,
+		descConfigMapAnnotationsHelp,
+		descConfigMapAnnotationsDefaultLabels, nil,
+	)
 )
 
 type ConfigMapLister func() ([]v1.ConfigMap, error)
@@ -52,21 +60,39 @@ func (l ConfigMapLister) List() ([]v1.ConfigMap, error) {
 	return l()
 }
 
-func RegisterConfigMapCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterConfigMapCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect configmap with %s", client.APIVersion())
-	cmlw := cache.NewListWatchFromClient(client, "configmaps", namespace, fields.Everything())
-	cminf := cache.NewSharedInformer(cmlw, &v1.ConfigMap{}, resyncPeriod)
+	cminfs := NewSharedInformerList(client, "configmaps", namespaces, &v1.ConfigMap{}, opts)
+	cminfs.SetTransform(func(obj runtime.Object) {
+		stripConfigMap(obj.(*v1.ConfigMap), opts)
+	})
 
 	configMapLister := ConfigMapLister(func() (configMaps []v1.ConfigMap, err error) {
-		for _, m := range cminf.GetStore().List() {
-			configMaps = append(configMaps, *m.(*v1.ConfigMap))
+		for _, cminf := range *cminfs {
+			for _, m := range cminf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				configMaps = append(configMaps, *m.(*v1.ConfigMap))
+			}
 		}
 		return configMaps, nil
 	})
 
-	registry.MustRegister(&configMapCollector{store: configMapLister})
-	go cminf.Run(context.Background().Done())
+	cmc := &configMapCollector{store: configMapLister, opts: opts}
+	registry.mustRegister("configmap", cmc, cminfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		cmc.collectConfigMap(ch, *obj.(*v1.ConfigMap))
+	}, opts)
+}
+
+// stripConfigMap drops the data of the configmap, its labels, which are not
+// exported, and the annotations that are not exported, so that they are
+// never cached.
+func stripConfigMap(cm *v1.ConfigMap, opts *Options) {
+	cm.Data = nil
+	cm.Labels = nil
+	cm.Annotations, _ = opts.allowedAnnotations("configmaps", cm.Annotations)
 }
 
 type configMapStore interface {
@@ -76,6 +102,7 @@ type configMapStore interface {
 // configMapCollector collects metrics about all configMaps in the cluster.
 type configMapCollector struct {
 	store configMapStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -83,6 +110,7 @@ func (sc *configMapCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descConfigMapInfo
 	ch <- descConfigMapCreated
 	ch <- descConfigMapMetadataResourceVersion
+	ch <- descConfigMapAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -105,6 +133,9 @@ func (cmc *configMapCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (cmc *configMapCollector) collectConfigMap(ch chan<- prometheus.Metric, s v1.ConfigMap) {
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !cmc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{s.Namespace, s.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
 	}
@@ -118,4 +149,18 @@ func (cmc *configMapCollector) collectConfigMap(ch chan<- prometheus.Metric, s v
 	}
 
 	addGauge(descConfigMapMetadataResourceVersion, 1, string(s.ObjectMeta.ResourceVersion))
+
+	if annotations, ok := cmc.opts.allowedAnnotations("configmaps", s.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(configMapAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+func configMapAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descConfigMapAnnotationsName,
+		descConfigMapAnnotationsHelp,
+		append(descConfigMapAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
 }
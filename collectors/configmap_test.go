@@ -45,6 +45,8 @@ func TestConfigMapCollector(t *testing.T) {
 		# TYPE kube_configmap_created gauge
 		# HELP kube_configmap_metadata_resource_version Resource version representing a specific version of the configmap.
 		# TYPE kube_configmap_metadata_resource_version gauge
+		# HELP kube_configmap_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_configmap_annotations gauge
 	`
 	cases := []struct {
 		configMaps []v1.ConfigMap
@@ -23,13 +23,10 @@ import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron"
-	"golang.org/x/net/context"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -37,12 +34,22 @@ var (
 	descCronJobLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descCronJobLabelsDefaultLabels = []string{"namespace", "cronjob"}
 
+	descCronJobAnnotationsName          = "kube_cronjob_annotations"
+	descCronJobAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descCronJobAnnotationsDefaultLabels = []string{"namespace", "cronjob"}
+
 	descCronJobLabels = prometheus.NewDesc(
 		descCronJobLabelsName,
 		descCronJobLabelsHelp,
 		descCronJobLabelsDefaultLabels, nil,
 	)
 
+	descCronJobAnnotations = prometheus.NewDesc(
+		descCronJobAnnotationsName,
+		descCronJobAnnotationsHelp,
+		descCronJobAnnotationsDefaultLabels, nil,
+	)
+
 	descCronJobInfo = prometheus.NewDesc(
 		"kube_cronjob_info",
 		"Info about cronjob.",
@@ -86,21 +93,29 @@ func (l CronJobLister) List() ([]batchv1beta1.CronJob, error) {
 	return l()
 }
 
-func RegisterCronJobCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.BatchV1beta1().RESTClient()
-	glog.Infof("collect cronjob with %s", client.APIVersion())
-	cjlw := cache.NewListWatchFromClient(client, "cronjobs", namespace, fields.Everything())
-	cjinf := cache.NewSharedInformer(cjlw, &batchv1beta1.CronJob{}, resyncPeriod)
+func RegisterCronJobCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	cjinfs, err := newVersionedInformerList(kubeClient, "cronjobs", namespaces, &batchv1beta1.CronJob{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch cronjobs: %v", err)
+		return
+	}
 
 	cronJobLister := CronJobLister(func() (cronjobs []batchv1beta1.CronJob, err error) {
-		for _, c := range cjinf.GetStore().List() {
-			cronjobs = append(cronjobs, *(c.(*batchv1beta1.CronJob)))
+		for _, cjinf := range *cjinfs {
+			for _, c := range cjinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				cronjobs = append(cronjobs, *(c.(*batchv1beta1.CronJob)))
+			}
 		}
 		return cronjobs, nil
 	})
 
-	registry.MustRegister(&cronJobCollector{store: cronJobLister})
-	go cjinf.Run(context.Background().Done())
+	jc := &cronJobCollector{store: cronJobLister, opts: opts}
+	registry.mustRegister("cronjob", jc, cjinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		jc.collectCronJob(ch, *obj.(*batchv1beta1.CronJob))
+	}, opts)
 }
 
 type cronJobStore interface {
@@ -110,6 +125,7 @@ type cronJobStore interface {
 // cronJobCollector collects metrics about all cronjobs in the cluster.
 type cronJobCollector struct {
 	store cronJobStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -117,6 +133,7 @@ func (dc *cronJobCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descCronJobInfo
 	ch <- descCronJobCreated
 	ch <- descCronJobLabels
+	ch <- descCronJobAnnotations
 	ch <- descCronJobStatusActive
 	ch <- descCronJobStatusLastScheduleTime
 	ch <- descCronJobSpecSuspend
@@ -165,8 +182,20 @@ func cronJobLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func cronJobAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descCronJobAnnotationsName,
+		descCronJobAnnotationsHelp,
+		append(descCronJobAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (jc *cronJobCollector) collectCronJob(ch chan<- prometheus.Metric, j batchv1beta1.CronJob) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !jc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{j.Namespace, j.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -185,9 +214,14 @@ func (jc *cronJobCollector) collectCronJob(ch chan<- prometheus.Metric, j batchv
 
 	addGauge(descCronJobInfo, 1, j.Spec.Schedule, string(j.Spec.ConcurrencyPolicy))
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(j.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(jc.opts.allowedLabels("cronjobs", j.Labels))
 	addGauge(cronJobLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := jc.opts.allowedAnnotations("cronjobs", j.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(cronJobAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	if !j.CreationTimestamp.IsZero() {
 		addGauge(descCronJobCreated, float64(j.CreationTimestamp.Unix()))
 	}
@@ -63,6 +63,8 @@ func TestCronJobCollector(t *testing.T) {
 		# TYPE kube_cronjob_status_last_schedule_time gauge
 		# HELP kube_cronjob_next_schedule_time Next time the cronjob should be scheduled. The time after lastScheduleTime, or after the cron job's creation time if it's never been scheduled. Use this to determine if the job is delayed.
 		# TYPE kube_cronjob_next_schedule_time gauge
+		# HELP kube_cronjob_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_cronjob_annotations gauge
 	`
 	cases := []struct {
 		cronJobs []batchv1beta1.CronJob
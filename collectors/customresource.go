@@ -0,0 +1,444 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// CustomResourceConfig declares the metrics of a custom resource, so that
+// resources without a built-in collector can be collected.
+type CustomResourceConfig struct {
+	// Group, Version and Kind identify the custom resource.
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Resource is the plural resource name used in API paths and as the
+	// collector name. It defaults to the lower-cased plural of Kind.
+	Resource string `json:"resource"`
+	// ClusterScoped is set for resources that are not namespaced.
+	ClusterScoped bool `json:"clusterScoped"`
+	// MetricNamePrefix is prepended to the metric names, it defaults to
+	// "kube_" followed by the lower-cased Kind.
+	MetricNamePrefix string `json:"metricNamePrefix"`
+	// LabelsFromPath adds labels to all metrics, read from the fields at the
+	// given paths.
+	LabelsFromPath map[string]string      `json:"labelsFromPath"`
+	Metrics        []CustomResourceMetric `json:"metrics"`
+}
+
+// CustomResourceMetric declares a metric family of a custom resource. Field
+// paths are dot-separated, like "status.replicas", and numeric path
+// elements index lists.
+type CustomResourceMetric struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	// ValuePath is the path of the field holding the value. Numbers and
+	// booleans are used as they are, strings are parsed as numbers or
+	// RFC 3339 timestamps unless StateMap is set. Without ValuePath and
+	// ConditionsPath the value is 1.
+	ValuePath string `json:"valuePath"`
+	// StateMap maps the string values of the field at ValuePath to metric
+	// values. Objects with other values produce no series.
+	StateMap map[string]float64 `json:"stateMap"`
+	// ConditionsPath is the path of a list of conditions with type and
+	// status fields. Each condition produces a series per status, labeled
+	// with the condition type and "true", "false" or "unknown", like the
+	// condition metrics of the built-in collectors.
+	ConditionsPath string `json:"conditionsPath"`
+	// LabelsFromPath adds labels read from the fields at the given paths.
+	LabelsFromPath map[string]string `json:"labelsFromPath"`
+}
+
+// GroupVersionResource returns the resource the config declares metrics
+// for.
+func (c *CustomResourceConfig) GroupVersionResource() schema.GroupVersionResource {
+	gvr := schema.GroupVersionResource{Group: c.Group, Version: c.Version, Resource: c.Resource}
+	if gvr.Resource == "" {
+		gvr, _ = meta.UnsafeGuessKindToResource(schema.GroupVersionKind{Group: c.Group, Version: c.Version, Kind: c.Kind})
+	}
+	return gvr
+}
+
+// CollectorName returns the name of the collector of the custom resource,
+// its plural resource name qualified by the group, like
+// rollouts.argoproj.io, so that it neither collides with a built-in
+// collector nor with a resource of the same name in another group.
+func (c *CustomResourceConfig) CollectorName() string {
+	gr := c.GroupVersionResource().GroupResource()
+	return gr.String()
+}
+
+// Validate reports whether the config describes a resource and valid metric
+// families.
+func (c *CustomResourceConfig) Validate() error {
+	if c.Version == "" || c.Kind == "" {
+		return fmt.Errorf("version and kind of the custom resource must be set")
+	}
+	if err := validateLabelPaths(c.LabelsFromPath); err != nil {
+		return err
+	}
+	if len(c.Metrics) == 0 {
+		return fmt.Errorf("no metrics declared for %s", c.Kind)
+	}
+	names := map[string]bool{}
+	for _, m := range c.Metrics {
+		name := c.metricName(m)
+		if !model.IsValidMetricName(model.LabelValue(name)) {
+			return fmt.Errorf("invalid metric name %q", name)
+		}
+		if names[name] {
+			return fmt.Errorf("metric %q declared twice", name)
+		}
+		names[name] = true
+		if m.ValuePath != "" && m.ConditionsPath != "" {
+			return fmt.Errorf("metric %q sets both valuePath and conditionsPath", name)
+		}
+		if m.StateMap != nil && m.ValuePath == "" {
+			return fmt.Errorf("metric %q sets a stateMap without valuePath", name)
+		}
+		if err := validateLabelPaths(m.LabelsFromPath); err != nil {
+			return fmt.Errorf("metric %q: %v", name, err)
+		}
+		labels := c.labelNames(m)
+		seen := map[string]bool{}
+		for _, l := range labels {
+			if seen[l] {
+				return fmt.Errorf("metric %q has label %q twice", name, l)
+			}
+			seen[l] = true
+		}
+	}
+	return nil
+}
+
+func validateLabelPaths(labels map[string]string) error {
+	for name, path := range labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if path == "" {
+			return fmt.Errorf("no path set for label %q", name)
+		}
+	}
+	return nil
+}
+
+func (c *CustomResourceConfig) metricName(m CustomResourceMetric) string {
+	prefix := c.MetricNamePrefix
+	if prefix == "" {
+		prefix = "kube_" + strings.ToLower(c.Kind)
+	}
+	return prefix + "_" + m.Name
+}
+
+// labelNames returns the label names of the metric, in the order the label
+// values are produced by collectObject.
+func (c *CustomResourceConfig) labelNames(m CustomResourceMetric) []string {
+	labels := []string{}
+	if !c.ClusterScoped {
+		labels = append(labels, "namespace")
+	}
+	labels = append(labels, strings.ToLower(c.Kind))
+	labels = append(labels, sortedKeys(c.LabelsFromPath)...)
+	labels = append(labels, sortedKeys(m.LabelsFromPath)...)
+	if m.ConditionsPath != "" {
+		labels = append(labels, "condition", "status")
+	}
+	return labels
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RegisterCustomResourceCollector registers a collector for the custom
+// resource declared by crc. Objects are watched as unstructured objects,
+// with a REST client for the group version of the resource created from
+// config.
+func RegisterCustomResourceCollector(registry *Registry, config *rest.Config, namespaces []string, opts *Options, crc *CustomResourceConfig) error {
+	gvr := crc.GroupVersionResource()
+	client, err := newUnstructuredClient(config, gvr.GroupVersion())
+	if err != nil {
+		return err
+	}
+	glog.Infof("collect %s with %s", gvr.Resource, gvr.GroupVersion())
+
+	if crc.ClusterScoped {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	infs := NewSharedInformerList(client, gvr.Resource, namespaces, &unstructured.Unstructured{}, opts)
+	lister := customResourceLister(func() (objs []*unstructured.Unstructured, err error) {
+		for _, inf := range *infs {
+			for _, o := range inf.GetStore().List() {
+				if !opts.owns(o) {
+					continue
+				}
+				objs = append(objs, o.(*unstructured.Unstructured))
+			}
+		}
+		return objs, nil
+	})
+
+	c := newCustomResourceCollector(crc, lister, opts)
+	registry.mustRegister(c.resource, c, infs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		c.collectObject(ch, obj.(*unstructured.Unstructured))
+	}, opts)
+	return nil
+}
+
+// newUnstructuredClient creates a REST client for gv that decodes objects as
+// unstructured objects.
+func newUnstructuredClient(config *rest.Config, gv schema.GroupVersion) (*rest.RESTClient, error) {
+	conf := *config
+	conf.GroupVersion = &gv
+	conf.APIPath = "/apis"
+	if gv.Group == "" {
+		conf.APIPath = "/api"
+	}
+	conf.ContentType = runtime.ContentTypeJSON
+	conf.AcceptContentTypes = runtime.ContentTypeJSON
+	conf.NegotiatedSerializer = unstructuredNegotiatedSerializer{}
+	if conf.UserAgent == "" {
+		conf.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return rest.RESTClientFor(&conf)
+}
+
+// unstructuredNegotiatedSerializer decodes objects as unstructured objects.
+// Watch events are framed and decoded like those of the typed clients.
+type unstructuredNegotiatedSerializer struct{}
+
+// SupportedMediaTypes implements the runtime.NegotiatedSerializer interface.
+func (unstructuredNegotiatedSerializer) SupportedMediaTypes() []runtime.SerializerInfo {
+	info, _ := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), runtime.ContentTypeJSON)
+	return []runtime.SerializerInfo{{
+		MediaType:        runtime.ContentTypeJSON,
+		EncodesAsText:    true,
+		Serializer:       unstructured.UnstructuredJSONScheme,
+		StreamSerializer: info.StreamSerializer,
+	}}
+}
+
+// EncoderForVersion implements the runtime.NegotiatedSerializer interface.
+func (unstructuredNegotiatedSerializer) EncoderForVersion(encoder runtime.Encoder, gv runtime.GroupVersioner) runtime.Encoder {
+	return encoder
+}
+
+// DecoderToVersion implements the runtime.NegotiatedSerializer interface.
+func (unstructuredNegotiatedSerializer) DecoderToVersion(decoder runtime.Decoder, gv runtime.GroupVersioner) runtime.Decoder {
+	return decoder
+}
+
+type customResourceStore interface {
+	List() ([]*unstructured.Unstructured, error)
+}
+
+// customResourceCollector collects the metrics declared for a custom
+// resource.
+type customResourceCollector struct {
+	store  customResourceStore
+	opts   *Options
+	config *CustomResourceConfig
+	// resource is the plural resource name qualified by the group, like
+	// rollouts.argoproj.io. It is used in the scrape metrics and registered
+	// with the registry, so that a kind named like a built-in resource does
+	// not replace its collector.
+	resource string
+	descs    []*prometheus.Desc
+}
+
+type customResourceLister func() ([]*unstructured.Unstructured, error)
+
+func (l customResourceLister) List() ([]*unstructured.Unstructured, error) {
+	return l()
+}
+
+func newCustomResourceCollector(crc *CustomResourceConfig, store customResourceStore, opts *Options) *customResourceCollector {
+	c := &customResourceCollector{
+		store:    store,
+		opts:     opts,
+		config:   crc,
+		resource: crc.CollectorName(),
+	}
+	for _, m := range crc.Metrics {
+		help := m.Help
+		if help == "" {
+			help = fmt.Sprintf("%s of the %s.", m.Name, crc.Kind)
+		}
+		c.descs = append(c.descs, prometheus.NewDesc(crc.metricName(m), help, crc.labelNames(m), nil))
+	}
+	return c
+}
+
+// Describe implements the prometheus.Collector interface.
+func (cc *customResourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range cc.descs {
+		ch <- d
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (cc *customResourceCollector) Collect(ch chan<- prometheus.Metric) {
+	objs, err := cc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": cc.resource}).Inc()
+		glog.Errorf("listing %s failed: %s", cc.config.Kind, err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": cc.resource}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": cc.resource}).Observe(float64(len(objs)))
+	for _, o := range objs {
+		cc.collectObject(ch, o)
+	}
+
+	glog.V(4).Infof("collected %d %s", len(objs), cc.config.Kind)
+}
+
+func (cc *customResourceCollector) collectObject(ch chan<- prometheus.Metric, o *unstructured.Unstructured) {
+	lv := []string{}
+	if !cc.config.ClusterScoped {
+		lv = append(lv, o.GetNamespace())
+	}
+	lv = append(lv, o.GetName())
+	lv = append(lv, labelValuesFromPaths(o.Object, cc.config.LabelsFromPath)...)
+
+	for i, m := range cc.config.Metrics {
+		desc := cc.descs[i]
+		if !cc.opts.allows(desc) {
+			continue
+		}
+		mlv := append(append([]string{}, lv...), labelValuesFromPaths(o.Object, m.LabelsFromPath)...)
+
+		switch {
+		case m.ConditionsPath != "":
+			conditions, ok := fieldAtPath(o.Object, m.ConditionsPath).([]interface{})
+			if !ok {
+				continue
+			}
+			for _, c := range conditions {
+				cond, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				condType, _ := cond["type"].(string)
+				status, _ := cond["status"].(string)
+				if condType == "" {
+					continue
+				}
+				addConditionMetrics(ch, cc.opts, desc, v1.ConditionStatus(status), append(mlv, condType)...)
+			}
+		case m.ValuePath != "":
+			v, ok := metricValue(fieldAtPath(o.Object, m.ValuePath), m.StateMap)
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, mlv...)
+		default:
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, mlv...)
+		}
+	}
+}
+
+// labelValuesFromPaths returns the values of the fields at the paths of
+// labels, ordered by label name. Missing fields give empty values.
+func labelValuesFromPaths(obj map[string]interface{}, labels map[string]string) []string {
+	values := make([]string, 0, len(labels))
+	for _, name := range sortedKeys(labels) {
+		values = append(values, labelValue(fieldAtPath(obj, labels[name])))
+	}
+	return values
+}
+
+// fieldAtPath returns the field at the dot-separated path in obj, or nil if
+// there is none.
+func fieldAtPath(obj interface{}, path string) interface{} {
+	for _, elem := range strings.Split(path, ".") {
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			obj = o[elem]
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(o) {
+				return nil
+			}
+			obj = o[i]
+		default:
+			return nil
+		}
+	}
+	return obj
+}
+
+func labelValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// metricValue converts the field value v to a metric value.
+func metricValue(v interface{}, stateMap map[string]float64) (float64, bool) {
+	if stateMap != nil {
+		value, ok := stateMap[labelValue(v)]
+		return value, ok
+	}
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		return boolFloat64(v), true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return float64(t.Unix()), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+var rolloutConfig = CustomResourceConfig{
+	Group:          "argoproj.io",
+	Version:        "v1alpha1",
+	Kind:           "Rollout",
+	LabelsFromPath: map[string]string{"team": "metadata.labels.team"},
+	Metrics: []CustomResourceMetric{
+		{
+			Name:           "info",
+			Help:           "Information about the rollout.",
+			LabelsFromPath: map[string]string{"strategy_canary_steps": "spec.strategy.canary.steps.0.setWeight"},
+		},
+		{
+			Name:      "created",
+			Help:      "Unix creation timestamp.",
+			ValuePath: "metadata.creationTimestamp",
+		},
+		{
+			Name:      "spec_replicas",
+			Help:      "Number of desired pods for the rollout.",
+			ValuePath: "spec.replicas",
+		},
+		{
+			Name:      "status_phase",
+			Help:      "The phase of the rollout.",
+			ValuePath: "status.phase",
+			StateMap:  map[string]float64{"Healthy": 0, "Progressing": 1, "Paused": 2, "Degraded": 3},
+		},
+		{
+			Name:           "status_condition",
+			Help:           "The current status conditions of the rollout.",
+			ConditionsPath: "status.conditions",
+		},
+	},
+}
+
+type mockCustomResourceStore struct {
+	list func() ([]*unstructured.Unstructured, error)
+}
+
+func (s mockCustomResourceStore) List() ([]*unstructured.Unstructured, error) {
+	return s.list()
+}
+
+func TestCustomResourceCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_rollout_info Information about the rollout.
+		# TYPE kube_rollout_info gauge
+		# HELP kube_rollout_created Unix creation timestamp.
+		# TYPE kube_rollout_created gauge
+		# HELP kube_rollout_spec_replicas Number of desired pods for the rollout.
+		# TYPE kube_rollout_spec_replicas gauge
+		# HELP kube_rollout_status_phase The phase of the rollout.
+		# TYPE kube_rollout_status_phase gauge
+		# HELP kube_rollout_status_condition The current status conditions of the rollout.
+		# TYPE kube_rollout_status_condition gauge
+	`
+	cases := []struct {
+		objs    []*unstructured.Unstructured
+		metrics []string // which metrics should be checked
+		want    string
+	}{
+		{
+			objs: []*unstructured.Unstructured{
+				{
+					Object: map[string]interface{}{
+						"apiVersion": "argoproj.io/v1alpha1",
+						"kind":       "Rollout",
+						"metadata": map[string]interface{}{
+							"name":              "rollout1",
+							"namespace":         "ns1",
+							"creationTimestamp": "2018-05-01T10:00:00Z",
+							"labels":            map[string]interface{}{"team": "checkout"},
+						},
+						"spec": map[string]interface{}{
+							"replicas": int64(3),
+							"strategy": map[string]interface{}{
+								"canary": map[string]interface{}{
+									"steps": []interface{}{
+										map[string]interface{}{"setWeight": int64(20)},
+									},
+								},
+							},
+						},
+						"status": map[string]interface{}{
+							"phase": "Progressing",
+							"conditions": []interface{}{
+								map[string]interface{}{"type": "Available", "status": "True"},
+								map[string]interface{}{"type": "Paused", "status": "False"},
+							},
+						},
+					},
+				},
+				{
+					Object: map[string]interface{}{
+						"apiVersion": "argoproj.io/v1alpha1",
+						"kind":       "Rollout",
+						"metadata": map[string]interface{}{
+							"name":      "rollout2",
+							"namespace": "ns2",
+						},
+						"spec": map[string]interface{}{
+							"replicas": int64(1),
+						},
+						"status": map[string]interface{}{
+							"phase": "Unknown",
+						},
+					},
+				},
+			},
+			want: metadata + `
+				kube_rollout_info{namespace="ns1",rollout="rollout1",strategy_canary_steps="20",team="checkout"} 1
+				kube_rollout_info{namespace="ns2",rollout="rollout2",strategy_canary_steps="",team=""} 1
+				kube_rollout_created{namespace="ns1",rollout="rollout1",team="checkout"} 1.5251688e+09
+				kube_rollout_spec_replicas{namespace="ns1",rollout="rollout1",team="checkout"} 3
+				kube_rollout_spec_replicas{namespace="ns2",rollout="rollout2",team=""} 1
+				kube_rollout_status_phase{namespace="ns1",rollout="rollout1",team="checkout"} 1
+				kube_rollout_status_condition{condition="Available",namespace="ns1",rollout="rollout1",status="false",team="checkout"} 0
+				kube_rollout_status_condition{condition="Available",namespace="ns1",rollout="rollout1",status="true",team="checkout"} 1
+				kube_rollout_status_condition{condition="Available",namespace="ns1",rollout="rollout1",status="unknown",team="checkout"} 0
+				kube_rollout_status_condition{condition="Paused",namespace="ns1",rollout="rollout1",status="false",team="checkout"} 1
+				kube_rollout_status_condition{condition="Paused",namespace="ns1",rollout="rollout1",status="true",team="checkout"} 0
+				kube_rollout_status_condition{condition="Paused",namespace="ns1",rollout="rollout1",status="unknown",team="checkout"} 0
+			`,
+			metrics: []string{
+				"kube_rollout_info",
+				"kube_rollout_created",
+				"kube_rollout_spec_replicas",
+				"kube_rollout_status_phase",
+				"kube_rollout_status_condition",
+			},
+		},
+	}
+	for _, c := range cases {
+		crc := rolloutConfig
+		cc := newCustomResourceCollector(&crc, mockCustomResourceStore{
+			list: func() ([]*unstructured.Unstructured, error) {
+				return c.objs, nil
+			},
+		}, nil)
+		if err := gatherAndCompare(cc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
+
+func TestCustomResourceConfigValidate(t *testing.T) {
+	cases := []struct {
+		config CustomResourceConfig
+		valid  bool
+	}{
+		{config: rolloutConfig, valid: true},
+		{config: CustomResourceConfig{Version: "v1", Metrics: rolloutConfig.Metrics}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout"}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", Metrics: []CustomResourceMetric{{Name: "bad-name"}}}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", Metrics: []CustomResourceMetric{{Name: "a"}, {Name: "a"}}}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", Metrics: []CustomResourceMetric{{Name: "a", ValuePath: "a", ConditionsPath: "b"}}}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", Metrics: []CustomResourceMetric{{Name: "a", StateMap: map[string]float64{"a": 1}}}}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", Metrics: []CustomResourceMetric{{Name: "a", LabelsFromPath: map[string]string{"namespace": "metadata.namespace"}}}}},
+		{config: CustomResourceConfig{Version: "v1", Kind: "Rollout", LabelsFromPath: map[string]string{"a-b": "spec.a"}, Metrics: []CustomResourceMetric{{Name: "a"}}}},
+	}
+	for i, c := range cases {
+		if err := c.config.Validate(); (err == nil) != c.valid {
+			t.Errorf("case %d: expected valid %t, got error %v", i, c.valid, err)
+		}
+	}
+}
+
+func TestCustomResourceGroupVersionResource(t *testing.T) {
+	crc := CustomResourceConfig{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	want := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	if got := crc.GroupVersionResource(); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	crc.Resource = "rollouts2"
+	want.Resource = "rollouts2"
+	if got := crc.GroupVersionResource(); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestUnstructuredListWatch(t *testing.T) {
+	const rollout = `{"apiVersion":"argoproj.io/v1alpha1","kind":"Rollout","metadata":{"name":"rollout1","namespace":"ns1"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/argoproj.io/v1alpha1/namespaces/ns1/rollouts" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			fmt.Fprintf(w, `{"type":"ADDED","object":%s}`, rollout)
+			return
+		}
+		fmt.Fprintf(w, `{"apiVersion":"argoproj.io/v1alpha1","kind":"RolloutList","metadata":{"resourceVersion":"1"},"items":[%s]}`, rollout)
+	}))
+	defer server.Close()
+
+	client, err := newUnstructuredClient(&rest.Config{Host: server.URL}, schema.GroupVersion{Group: "argoproj.io", Version: "v1alpha1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw := newListWatch(client, "rollouts", "ns1", nil)
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing failed: %s", err)
+	}
+	items := list.(*unstructured.UnstructuredList).Items
+	if len(items) != 1 || items[0].GetName() != "rollout1" {
+		t.Errorf("unexpected list %v", items)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("watching failed: %s", err)
+	}
+	defer w.Stop()
+	e := <-w.ResultChan()
+	if e.Type != watch.Added {
+		t.Fatalf("expected an added event, got %v", e)
+	}
+	if o, ok := e.Object.(*unstructured.Unstructured); !ok || o.GetName() != "rollout1" {
+		t.Errorf("unexpected watched object %#v", e.Object)
+	}
+}
+
+func TestRegisterCustomResourceCollectorKeepsBuiltin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"apiVersion":"example.com/v1","kind":"PodList","metadata":{"resourceVersion":"1"},"items":[]}`)
+	}))
+	defer server.Close()
+
+	// A custom resource of kind Pod in another group must not replace the
+	// built-in pod collector.
+	crc := &CustomResourceConfig{
+		Group:            "example.com",
+		Version:          "v1",
+		Kind:             "Pod",
+		MetricNamePrefix: "example_pod",
+		Metrics:          []CustomResourceMetric{{Name: "info", Help: "Information about the pod."}},
+	}
+	pods := &SharedInformerList{newMonitoredInformer(fakeListWatch(nil), &v1.Pod{})}
+
+	r := NewRegistry()
+	r.Enable("pods", func(r *Registry) {
+		r.mustRegister("pod", &podCollector{}, pods, func(interface{}, chan<- prometheus.Metric) {}, nil)
+	})
+	var err error
+	r.Enable("examplepods", func(r *Registry) {
+		err = RegisterCustomResourceCollector(r, &rest.Config{Host: server.URL}, []string{metav1.NamespaceAll}, &Options{}, crc)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.enabled["examplepods"], []string{"pods.example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the custom resource to be registered as %v, got %v", want, got)
+	}
+	if len(r.resources) != 2 {
+		t.Errorf("expected 2 registered resources, got %d", len(r.resources))
+	}
+
+	r.Disable("examplepods")
+	if _, ok := r.resources["pod"]; !ok {
+		t.Errorf("disabling the custom resource collector removed the built-in pod collector")
+	}
+	if len(r.stores) != 1 {
+		t.Errorf("expected only the pod store to remain, got %d stores", len(r.stores))
+	}
+	r.Disable("pods")
+}
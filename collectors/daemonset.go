@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,6 +28,10 @@ var (
 	descDaemonSetLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descDaemonSetLabelsDefaultLabels = []string{"namespace", "daemonset"}
 
+	descDaemonSetAnnotationsName          = "kube_daemonset_annotations"
+	descDaemonSetAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descDaemonSetAnnotationsDefaultLabels = []string{"namespace", "daemonset"}
+
 	descDaemonSetCreated = prometheus.NewDesc(
 		"kube_daemonset_created",
 		"Unix creation timestamp",
@@ -81,6 +82,12 @@ var (
 		descDaemonSetLabelsHelp,
 		descDaemonSetLabelsDefaultLabels, nil,
 	)
+
+	descDaemonSetAnnotations = prometheus.NewDesc(
+		descDaemonSetAnnotationsName,
+		descDaemonSetAnnotationsHelp,
+		descDaemonSetAnnotationsDefaultLabels, nil,
+	)
 )
 
 type DaemonSetLister func() ([]v1beta1.DaemonSet, error)
@@ -89,21 +96,29 @@ func (l DaemonSetLister) List() ([]v1beta1.DaemonSet, error) {
 	return l()
 }
 
-func RegisterDaemonSetCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.ExtensionsV1beta1().RESTClient()
-	glog.Infof("collect daemonset with %s", client.APIVersion())
-	dslw := cache.NewListWatchFromClient(client, "daemonsets", namespace, fields.Everything())
-	dsinf := cache.NewSharedInformer(dslw, &v1beta1.DaemonSet{}, resyncPeriod)
+func RegisterDaemonSetCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	dsinfs, err := newVersionedInformerList(kubeClient, "daemonsets", namespaces, &v1beta1.DaemonSet{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch daemonsets: %v", err)
+		return
+	}
 
 	dsLister := DaemonSetLister(func() (daemonsets []v1beta1.DaemonSet, err error) {
-		for _, c := range dsinf.GetStore().List() {
-			daemonsets = append(daemonsets, *(c.(*v1beta1.DaemonSet)))
+		for _, dsinf := range *dsinfs {
+			for _, c := range dsinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				daemonsets = append(daemonsets, *(c.(*v1beta1.DaemonSet)))
+			}
 		}
 		return daemonsets, nil
 	})
 
-	registry.MustRegister(&daemonsetCollector{store: dsLister})
-	go dsinf.Run(context.Background().Done())
+	dc := &daemonsetCollector{store: dsLister, opts: opts}
+	registry.mustRegister("daemonset", dc, dsinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		dc.collectDaemonSet(ch, *obj.(*v1beta1.DaemonSet))
+	}, opts)
 }
 
 type daemonsetStore interface {
@@ -113,6 +128,7 @@ type daemonsetStore interface {
 // daemonsetCollector collects metrics about all daemonsets in the cluster.
 type daemonsetCollector struct {
 	store daemonsetStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -127,6 +143,7 @@ func (dc *daemonsetCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descDaemonSetUpdatedNumberScheduled
 	ch <- descDaemonSetMetadataGeneration
 	ch <- descDaemonSetLabels
+	ch <- descDaemonSetAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -156,8 +173,20 @@ func DaemonSetLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func DaemonSetAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descDaemonSetAnnotationsName,
+		descDaemonSetAnnotationsHelp,
+		append(descDaemonSetAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (dc *daemonsetCollector) collectDaemonSet(ch chan<- prometheus.Metric, d v1beta1.DaemonSet) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !dc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{d.Namespace, d.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -173,6 +202,11 @@ func (dc *daemonsetCollector) collectDaemonSet(ch chan<- prometheus.Metric, d v1
 	addGauge(descDaemonSetUpdatedNumberScheduled, float64(d.Status.UpdatedNumberScheduled))
 	addGauge(descDaemonSetMetadataGeneration, float64(d.ObjectMeta.Generation))
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(d.ObjectMeta.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(dc.opts.allowedLabels("daemonsets", d.ObjectMeta.Labels))
 	addGauge(DaemonSetLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := dc.opts.allowedAnnotations("daemonsets", d.ObjectMeta.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(DaemonSetAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 }
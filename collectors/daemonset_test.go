@@ -56,6 +56,8 @@ func TestDaemonSetCollector(t *testing.T) {
 		# TYPE kube_daemonset_updated_number_scheduled gauge
 		# HELP kube_daemonset_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE kube_daemonset_labels gauge
+		# HELP kube_daemonset_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_daemonset_annotations gauge
 `
 	cases := []struct {
 		dss  []v1beta1.DaemonSet
@@ -19,12 +19,10 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -32,6 +30,10 @@ var (
 	descDeploymentLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descDeploymentLabelsDefaultLabels = []string{"namespace", "deployment"}
 
+	descDeploymentAnnotationsName          = "kube_deployment_annotations"
+	descDeploymentAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descDeploymentAnnotationsDefaultLabels = []string{"namespace", "deployment"}
+
 	descDeploymentCreated = prometheus.NewDesc(
 		"kube_deployment_created",
 		"Unix creation timestamp",
@@ -100,6 +102,12 @@ var (
 		descDeploymentLabelsHelp,
 		descDeploymentLabelsDefaultLabels, nil,
 	)
+
+	descDeploymentAnnotations = prometheus.NewDesc(
+		descDeploymentAnnotationsName,
+		descDeploymentAnnotationsHelp,
+		descDeploymentAnnotationsDefaultLabels, nil,
+	)
 )
 
 type DeploymentLister func() ([]v1beta1.Deployment, error)
@@ -108,21 +116,45 @@ func (l DeploymentLister) List() ([]v1beta1.Deployment, error) {
 	return l()
 }
 
-func RegisterDeploymentCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.ExtensionsV1beta1().RESTClient()
-	glog.Infof("collect deployment with %s", client.APIVersion())
-	dlw := cache.NewListWatchFromClient(client, "deployments", namespace, fields.Everything())
-	dinf := cache.NewSharedInformer(dlw, &v1beta1.Deployment{}, resyncPeriod)
+func RegisterDeploymentCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	dinfs, err := newVersionedInformerList(kubeClient, "deployments", namespaces, &v1beta1.Deployment{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch deployments: %v", err)
+		return
+	}
+	dinfs.SetTransform(func(obj runtime.Object) {
+		stripDeployment(obj.(*v1beta1.Deployment), opts)
+	})
 
 	dplLister := DeploymentLister(func() (deployments []v1beta1.Deployment, err error) {
-		for _, c := range dinf.GetStore().List() {
-			deployments = append(deployments, *(c.(*v1beta1.Deployment)))
+		for _, dinf := range *dinfs {
+			for _, c := range dinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				deployments = append(deployments, *(c.(*v1beta1.Deployment)))
+			}
 		}
 		return deployments, nil
 	})
 
-	registry.MustRegister(&deploymentCollector{store: dplLister})
-	go dinf.Run(context.Background().Done())
+	dc := &deploymentCollector{store: dplLister, opts: opts}
+	registry.mustRegister("deployment", dc, dinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		dc.collectDeployment(ch, *obj.(*v1beta1.Deployment))
+	}, opts)
+}
+
+// stripDeployment drops the fields of the deployment the collector does not
+// read, like the pod template, so that they are never cached.
+func stripDeployment(d *v1beta1.Deployment, opts *Options) {
+	opts.stripMetadata("deployments", &d.ObjectMeta)
+
+	d.Spec = v1beta1.DeploymentSpec{
+		Replicas: d.Spec.Replicas,
+		Strategy: d.Spec.Strategy,
+		Paused:   d.Spec.Paused,
+	}
+	d.Status.Conditions = nil
 }
 
 type deploymentStore interface {
@@ -132,6 +164,7 @@ type deploymentStore interface {
 // deploymentCollector collects metrics about all deployments in the cluster.
 type deploymentCollector struct {
 	store deploymentStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -148,6 +181,7 @@ func (dc *deploymentCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descDeploymentSpecReplicas
 	ch <- descDeploymentMetadataGeneration
 	ch <- descDeploymentLabels
+	ch <- descDeploymentAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -177,13 +211,30 @@ func deploymentLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func deploymentAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descDeploymentAnnotationsName,
+		descDeploymentAnnotationsHelp,
+		append(descDeploymentAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (dc *deploymentCollector) collectDeployment(ch chan<- prometheus.Metric, d v1beta1.Deployment) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !dc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{d.Namespace, d.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(d.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(dc.opts.allowedLabels("deployments", d.Labels))
 	addGauge(deploymentLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := dc.opts.allowedAnnotations("deployments", d.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(deploymentAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 	if !d.CreationTimestamp.IsZero() {
 		addGauge(descDeploymentCreated, float64(d.CreationTimestamp.Unix()))
 	}
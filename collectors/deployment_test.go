@@ -29,6 +29,7 @@ import (
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
 
+	"k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -81,6 +82,8 @@ func TestDeploymentCollector(t *testing.T) {
 		# TYPE kube_deployment_spec_strategy_rollingupdate_max_surge gauge
 		# HELP kube_deployment_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE kube_deployment_labels gauge
+		# HELP kube_deployment_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_deployment_annotations gauge
 	`
 	cases := []struct {
 		depls []v1beta1.Deployment
@@ -333,3 +336,60 @@ func normalizeMetricFamilies(metricFamiliesByName map[string]*dto.MetricFamily)
 	}
 	return result
 }
+
+func TestStripDeploymentKeepsMetrics(t *testing.T) {
+	maxUnavailable := intstr.FromInt(1)
+	maxSurge := intstr.FromString("25%")
+	d := v1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "depl1",
+			Namespace:         "ns1",
+			UID:               "uid1",
+			Generation:        21,
+			CreationTimestamp: metav1.Unix(1500000000, 0),
+			Labels:            map[string]string{"app": "web"},
+			Annotations:       map[string]string{"deployment.kubernetes.io/revision": "3"},
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &depl1Replicas,
+			Paused:   true,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Strategy: v1beta1.DeploymentStrategy{
+				RollingUpdate: &v1beta1.RollingUpdateDeployment{MaxUnavailable: &maxUnavailable, MaxSurge: &maxSurge},
+			},
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "nginx"}}},
+			},
+		},
+		Status: v1beta1.DeploymentStatus{
+			Replicas:            15,
+			AvailableReplicas:   10,
+			UnavailableReplicas: 5,
+			UpdatedReplicas:     2,
+			ObservedGeneration:  111,
+			Conditions:          []v1beta1.DeploymentCondition{{Type: v1beta1.DeploymentAvailable, Status: v1.ConditionTrue, Message: "available"}},
+		},
+	}
+	opts := &Options{AnnotationsAllowList: map[string][]string{"deployments": {"owner"}}}
+	stripped := *d.DeepCopy()
+	stripDeployment(&stripped, opts)
+
+	want, err := collectText(&deploymentCollector{store: mockDeploymentStore{f: func() ([]v1beta1.Deployment, error) { return []v1beta1.Deployment{d}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectText(&deploymentCollector{store: mockDeploymentStore{f: func() ([]v1beta1.Deployment, error) { return []v1beta1.Deployment{stripped}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || !strings.Contains(want, "kube_deployment_spec_strategy_rollingupdate_max_surge{") {
+		t.Errorf("expected the metrics of the stripped deployment to equal\n%s\ngot\n%s", want, got)
+	}
+
+	if stripped.Spec.Template.Spec.Containers != nil || stripped.Spec.Selector != nil {
+		t.Errorf("expected the pod template and selector to be stripped, got %+v", stripped.Spec)
+	}
+	if len(stripped.Annotations) != 0 {
+		t.Errorf("expected annotations to be stripped, got %v", stripped.Annotations)
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	batchv2alpha1 "k8s.io/api/batch/v2alpha1"
+	"k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	rbacv1beta1 "k8s.io/api/rbac/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+)
+
+// APIResources holds the versions the API server serves each resource in,
+// as found by discovery.
+type APIResources map[schema.GroupResource]map[string]bool
+
+// DiscoverAPIResources asks the API server which resources it serves in
+// which group versions. If some groups could not be discovered, the
+// resources of all other groups are returned together with the error.
+func DiscoverAPIResources(client discovery.ServerResourcesInterface) (APIResources, error) {
+	lists, err := client.ServerResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+
+	resources := APIResources{}
+	for _, list := range lists {
+		gv, perr := schema.ParseGroupVersion(list.GroupVersion)
+		if perr != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			// Skip subresources like pods/status.
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			gr := gv.WithResource(r.Name).GroupResource()
+			if resources[gr] == nil {
+				resources[gr] = map[string]bool{}
+			}
+			resources[gr][gv.Version] = true
+		}
+	}
+	return resources, err
+}
+
+// Serves reports whether the API server serves the resource in gv.
+func (a APIResources) Serves(gv schema.GroupVersion, resource string) bool {
+	return a[gv.WithResource(resource).GroupResource()][gv.Version]
+}
+
+// apiVersion is a group version a resource can be watched in, together with
+// the client to watch it with.
+type apiVersion struct {
+	groupVersion schema.GroupVersion
+	client       func(kubernetes.Interface) cache.Getter
+}
+
+// apiVersions lists the group versions the resources of collectors outside
+// the core group can be watched in, newest first. Core resources are always
+// served in v1.
+var apiVersions = map[string][]apiVersion{
+	"clusterrolebindings": {
+		{rbacv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1().RESTClient() }},
+		{rbacv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1beta1().RESTClient() }},
+	},
+	"clusterroles": {
+		{rbacv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1().RESTClient() }},
+		{rbacv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1beta1().RESTClient() }},
+	},
+	"cronjobs": {
+		{batchv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.BatchV1beta1().RESTClient() }},
+		{batchv2alpha1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.BatchV2alpha1().RESTClient() }},
+	},
+	"daemonsets": {
+		{appsv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1().RESTClient() }},
+		{appsv1beta2.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta2().RESTClient() }},
+		{extensionsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.ExtensionsV1beta1().RESTClient() }},
+	},
+	"deployments": {
+		{appsv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1().RESTClient() }},
+		{appsv1beta2.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta2().RESTClient() }},
+		{appsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta1().RESTClient() }},
+		{extensionsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.ExtensionsV1beta1().RESTClient() }},
+	},
+	"horizontalpodautoscalers": {
+		{autoscalingv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AutoscalingV1().RESTClient() }},
+	},
+	"ingresses": {
+		{extensionsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.ExtensionsV1beta1().RESTClient() }},
+	},
+	"jobs": {
+		{batchv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.BatchV1().RESTClient() }},
+	},
+	"networkpolicies": {
+		{networkingv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.NetworkingV1().RESTClient() }},
+		{extensionsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.ExtensionsV1beta1().RESTClient() }},
+	},
+	"poddisruptionbudgets": {
+		{policyv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.PolicyV1beta1().RESTClient() }},
+	},
+	"replicasets": {
+		{appsv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1().RESTClient() }},
+		{appsv1beta2.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta2().RESTClient() }},
+		{extensionsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.ExtensionsV1beta1().RESTClient() }},
+	},
+	"rolebindings": {
+		{rbacv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1().RESTClient() }},
+		{rbacv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1beta1().RESTClient() }},
+	},
+	"roles": {
+		{rbacv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1().RESTClient() }},
+		{rbacv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.RbacV1beta1().RESTClient() }},
+	},
+	"statefulsets": {
+		{appsv1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1().RESTClient() }},
+		{appsv1beta2.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta2().RESTClient() }},
+		{appsv1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.AppsV1beta1().RESTClient() }},
+	},
+	"storageclasses": {
+		{storagev1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.StorageV1().RESTClient() }},
+		{storagev1beta1.SchemeGroupVersion, func(c kubernetes.Interface) cache.Getter { return c.StorageV1beta1().RESTClient() }},
+	},
+}
+
+// ServedVersion returns the newest group version the API server serves the
+// resource of the named collector in. It returns false if the resource is
+// not served in any version the collector can watch.
+func (a APIResources) ServedVersion(collector string) (schema.GroupVersion, bool) {
+	versions, ok := apiVersions[collector]
+	if !ok {
+		return v1.SchemeGroupVersion, true
+	}
+	for _, version := range versions {
+		if a.Serves(version.groupVersion, collector) {
+			return version.groupVersion, true
+		}
+	}
+	return schema.GroupVersion{}, false
+}
+
+// newVersionedInformerList creates an informer for the resource in each of
+// the given namespaces, like NewSharedInformerList. The resource is watched
+// in the newest group version served according to opts.APIResources, and
+// objects are converted to the group version of objType, so that collectors
+// only deal with a single version. Without discovery results, the group
+// version of objType is watched. It fails if there is no client for the
+// resource in the group version of objType.
+func newVersionedInformerList(kubeClient kubernetes.Interface, resource string, namespaces []string, objType runtime.Object, opts *Options) (*SharedInformerList, error) {
+	kind, err := objectKind(objType)
+	if err != nil {
+		return nil, err
+	}
+
+	version, ok := findAPIVersion(resource, kind.GroupVersion())
+	if !ok {
+		return nil, fmt.Errorf("no client for %s in %s", resource, kind.GroupVersion())
+	}
+	if opts != nil && opts.APIResources != nil {
+		if gv, ok := opts.APIResources.ServedVersion(resource); ok {
+			version, _ = findAPIVersion(resource, gv)
+		}
+	}
+	client := version.client(kubeClient)
+	glog.Infof("collect %s with %s", resource, version.groupVersion)
+
+	sinfs := SharedInformerList{}
+	for _, namespace := range namespaces {
+		var lw cache.ListerWatcher = newListWatch(client, resource, namespace, opts)
+		if version.groupVersion != kind.GroupVersion() {
+			lw = &convertingListWatch{ListerWatcher: lw, kind: kind}
+		}
+		sinfs = append(sinfs, newMonitoredInformer(lw, objType))
+	}
+	return &sinfs, nil
+}
+
+func findAPIVersion(resource string, gv schema.GroupVersion) (apiVersion, bool) {
+	for _, version := range apiVersions[resource] {
+		if version.groupVersion == gv {
+			return version, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+func objectKind(obj runtime.Object) (schema.GroupVersionKind, error) {
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	return kinds[0], nil
+}
+
+// convertingListWatch converts the objects it lists and watches to kind.
+// Objects are converted through their JSON representation, the fields
+// collectors use are the same in all versions of a resource. A watched
+// object that fails to convert ends the watch with an error event, so that
+// the reflector lists again instead of missing the change.
+type convertingListWatch struct {
+	cache.ListerWatcher
+	kind schema.GroupVersionKind
+}
+
+// List implements the cache.ListerWatcher interface.
+func (lw *convertingListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	list, err := lw.ListerWatcher.List(options)
+	if err != nil {
+		return nil, err
+	}
+	return convertObject(list, lw.kind.GroupVersion().WithKind(lw.kind.Kind+"List"))
+}
+
+// Watch implements the cache.ListerWatcher interface.
+func (lw *convertingListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := lw.ListerWatcher.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		if in.Type == watch.Error {
+			return in, true
+		}
+		obj, err := convertObject(in.Object, lw.kind)
+		if err != nil {
+			glog.Errorf("converting watched object to %s failed: %s", lw.kind, err)
+			return watch.Event{
+				Type: watch.Error,
+				Object: &metav1.Status{
+					Status:  metav1.StatusFailure,
+					Code:    http.StatusInternalServerError,
+					Reason:  metav1.StatusReasonInternalError,
+					Message: fmt.Sprintf("converting watched %s object to %s failed: %s", in.Type, lw.kind, err),
+				},
+			}, true
+		}
+		in.Object = obj
+		return in, true
+	}), nil
+}
+
+func convertObject(obj runtime.Object, kind schema.GroupVersionKind) (runtime.Object, error) {
+	out, err := scheme.Scheme.New(kind)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	out.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	return out, nil
+}
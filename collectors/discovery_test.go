@@ -0,0 +1,184 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeDiscovery struct {
+	discovery.ServerResourcesInterface
+	lists []*metav1.APIResourceList
+}
+
+func (d *fakeDiscovery) ServerResources() ([]*metav1.APIResourceList, error) {
+	return d.lists, nil
+}
+
+func TestAPIResourcesServedVersion(t *testing.T) {
+	resources, err := DiscoverAPIResources(&fakeDiscovery{lists: []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods"}, {Name: "pods/status"}},
+		},
+		{
+			GroupVersion: "apps/v1beta2",
+			APIResources: []metav1.APIResource{{Name: "deployments"}, {Name: "statefulsets"}},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{{Name: "deployments"}},
+		},
+		{
+			GroupVersion: "extensions/v1beta1",
+			APIResources: []metav1.APIResource{{Name: "deployments"}, {Name: "daemonsets"}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("discovering resources failed: %s", err)
+	}
+
+	cases := []struct {
+		collector string
+		want      schema.GroupVersion
+		served    bool
+	}{
+		{collector: "pods", want: schema.GroupVersion{Version: "v1"}, served: true},
+		{collector: "deployments", want: appsv1.SchemeGroupVersion, served: true},
+		{collector: "statefulsets", want: appsv1beta2.SchemeGroupVersion, served: true},
+		{collector: "daemonsets", want: v1beta1.SchemeGroupVersion, served: true},
+		{collector: "cronjobs", served: false},
+	}
+	for _, c := range cases {
+		got, served := resources.ServedVersion(c.collector)
+		if served != c.served || got != c.want {
+			t.Errorf("%s: got %s (served %t), want %s (served %t)", c.collector, got, served, c.want, c.served)
+		}
+	}
+}
+
+func TestConvertingListWatch(t *testing.T) {
+	replicas := int32(3)
+	deployment := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "depl1", Namespace: "ns1", ResourceVersion: "2"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: 2},
+	}
+	fw := watch.NewFake()
+	lw := &convertingListWatch{
+		ListerWatcher: &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return &appsv1.DeploymentList{
+					ListMeta: metav1.ListMeta{ResourceVersion: "2"},
+					Items:    []appsv1.Deployment{deployment},
+				}, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return fw, nil
+			},
+		},
+		kind: v1beta1.SchemeGroupVersion.WithKind("Deployment"),
+	}
+
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing failed: %s", err)
+	}
+	list, ok := obj.(*v1beta1.DeploymentList)
+	if !ok {
+		t.Fatalf("expected a *v1beta1.DeploymentList, got %T", obj)
+	}
+	if list.ResourceVersion != "2" || len(list.Items) != 1 {
+		t.Fatalf("unexpected converted list: %+v", list)
+	}
+	if d := list.Items[0]; d.Name != "depl1" || *d.Spec.Replicas != 3 || d.Status.AvailableReplicas != 2 {
+		t.Errorf("unexpected converted deployment: %+v", d)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("watching failed: %s", err)
+	}
+	defer w.Stop()
+	go fw.Add(&deployment)
+	e := <-w.ResultChan()
+	if d, ok := e.Object.(*v1beta1.Deployment); !ok || d.Name != "depl1" || *d.Spec.Replicas != 3 {
+		t.Errorf("unexpected converted watch event: %s %#v", e.Type, e.Object)
+	}
+
+	// An object failing to convert ends the watch with an error, so that the
+	// reflector lists again instead of missing the deletion.
+	invalid := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "depl1", "namespace": "ns1"},
+		"spec":     map[string]interface{}{"replicas": "three"},
+	}}
+	go fw.Delete(invalid)
+	e = <-w.ResultChan()
+	if status, ok := e.Object.(*metav1.Status); e.Type != watch.Error || !ok || status.Status != metav1.StatusFailure {
+		t.Errorf("expected an error event, got %s %#v", e.Type, e.Object)
+	}
+}
+
+func TestNewVersionedInformerList(t *testing.T) {
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatalf("creating client failed: %s", err)
+	}
+	served := APIResources{
+		{Group: "batch", Resource: "cronjobs"}: {"v2alpha1": true},
+	}
+	cases := []struct {
+		opts       *Options
+		converting bool
+	}{
+		{opts: nil, converting: false},
+		{opts: &Options{APIResources: served}, converting: true},
+	}
+	// Resources without a client in the group version of the object type
+	// are rejected.
+	if _, err := newVersionedInformerList(kubeClient, "cronjobs", []string{"ns1"}, &v1beta1.Deployment{}, nil); err == nil {
+		t.Error("expected an error for a resource without a client in the group version")
+	}
+
+	for _, c := range cases {
+		sinfs, err := newVersionedInformerList(kubeClient, "cronjobs", []string{"ns1", "ns2"}, &batchv1beta1.CronJob{}, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*sinfs) != 2 {
+			t.Fatalf("expected one informer per namespace, got %d", len(*sinfs))
+		}
+		_, converting := (*sinfs)[0].(*monitoredInformer).lw.ListerWatcher.(*convertingListWatch)
+		if converting != c.converting {
+			t.Errorf("converting list watch: got %t, want %t", converting, c.converting)
+		}
+	}
+}
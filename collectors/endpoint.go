@@ -17,15 +17,12 @@ limitations under the License.
 package collectors
 
 import (
-	"golang.org/x/net/context"
-
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -33,6 +30,10 @@ var (
 	descEndpointLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descEndpointLabelsDefaultLabels = []string{"namespace", "endpoint"}
 
+	descEndpointAnnotationsName          = "kube_endpoint_annotations"
+	descEndpointAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descEndpointAnnotationsDefaultLabels = []string{"namespace", "endpoint"}
+
 	descEndpointInfo = prometheus.NewDesc(
 		"kube_endpoint_info",
 		"Information about endpoint.",
@@ -51,6 +52,12 @@ var (
 		descEndpointLabelsDefaultLabels, nil,
 	)
 
+	descEndpointAnnotations = prometheus.NewDesc(
+		descEndpointAnnotationsName,
+		descEndpointAnnotationsHelp,
+		descEndpointAnnotationsDefaultLabels, nil,
+	)
+
 	descEndpointAddressAvailable = prometheus.NewDesc(
 		"kube_endpoint_address_available",
 		"Number of addresses available in endpoint.",
@@ -68,21 +75,45 @@ func (l EndpointLister) List() ([]v1.Endpoints, error) {
 	return l()
 }
 
-func RegisterEndpointCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterEndpointCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect endpoint with %s", client.APIVersion())
-	slw := cache.NewListWatchFromClient(client, "endpoints", namespace, fields.Everything())
-	sinf := cache.NewSharedInformer(slw, &v1.Endpoints{}, resyncPeriod)
+	sinfs := NewSharedInformerList(client, "endpoints", namespaces, &v1.Endpoints{}, opts)
+	sinfs.SetTransform(func(obj runtime.Object) {
+		stripEndpoints(obj.(*v1.Endpoints), opts)
+	})
 
 	endpointLister := EndpointLister(func() (endpoints []v1.Endpoints, err error) {
-		for _, m := range sinf.GetStore().List() {
-			endpoints = append(endpoints, *m.(*v1.Endpoints))
+		for _, sinf := range *sinfs {
+			for _, m := range sinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				endpoints = append(endpoints, *m.(*v1.Endpoints))
+			}
 		}
 		return endpoints, nil
 	})
 
-	registry.MustRegister(&endpointCollector{store: endpointLister})
-	go sinf.Run(context.Background().Done())
+	ec := &endpointCollector{store: endpointLister, opts: opts}
+	registry.mustRegister("endpoint", ec, sinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		ec.collectEndpoints(ch, *obj.(*v1.Endpoints))
+	}, opts)
+}
+
+// stripEndpoints drops the fields of the endpoints the collector does not
+// read. Only the number of addresses and ports is kept, not the addresses
+// and ports themselves.
+func stripEndpoints(e *v1.Endpoints, opts *Options) {
+	opts.stripMetadata("endpoints", &e.ObjectMeta)
+
+	for i, s := range e.Subsets {
+		e.Subsets[i] = v1.EndpointSubset{
+			Addresses:         make([]v1.EndpointAddress, len(s.Addresses)),
+			NotReadyAddresses: make([]v1.EndpointAddress, len(s.NotReadyAddresses)),
+			Ports:             make([]v1.EndpointPort, len(s.Ports)),
+		}
+	}
 }
 
 type endpointStore interface {
@@ -92,12 +123,14 @@ type endpointStore interface {
 // endpointCollector collects metrics about all endpoints in the cluster.
 type endpointCollector struct {
 	store endpointStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
 func (pc *endpointCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descEndpointInfo
 	ch <- descEndpointLabels
+	ch <- descEndpointAnnotations
 	ch <- descEndpointCreated
 	ch <- descEndpointAddressAvailable
 	ch <- descEndpointAddressNotReady
@@ -123,6 +156,9 @@ func (ec *endpointCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (ec *endpointCollector) collectEndpoints(ch chan<- prometheus.Metric, e v1.Endpoints) {
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !ec.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{e.Namespace, e.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
 	}
@@ -134,9 +170,14 @@ func (ec *endpointCollector) collectEndpoints(ch chan<- prometheus.Metric, e v1.
 	if !e.CreationTimestamp.IsZero() {
 		addGauge(descEndpointCreated, float64(e.CreationTimestamp.Unix()))
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(e.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(ec.opts.allowedLabels("endpoints", e.Labels))
 	addGauge(endpointLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := ec.opts.allowedAnnotations("endpoints", e.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(endpointAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	var available int
 	for _, s := range e.Subsets {
 		available += len(s.Addresses) * len(s.Ports)
@@ -158,3 +199,12 @@ func endpointLabelsDesc(labelKeys []string) *prometheus.Desc {
 		nil,
 	)
 }
+
+func endpointAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descEndpointAnnotationsName,
+		descEndpointAnnotationsHelp,
+		append(descEndpointAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
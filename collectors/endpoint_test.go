@@ -17,6 +17,7 @@ limitations under the License.
 package collectors
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -46,6 +47,8 @@ func TestEndpointCollector(t *testing.T) {
 		# TYPE kube_endpoint_info gauge
 		# HELP kube_endpoint_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE kube_endpoint_labels gauge
+		# HELP kube_endpoint_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_endpoint_annotations gauge
 	`
 	cases := []struct {
 		endpoints []v1.Endpoints
@@ -117,3 +120,51 @@ func TestEndpointCollector(t *testing.T) {
 		}
 	}
 }
+
+func TestStripEndpointsKeepsMetrics(t *testing.T) {
+	endpoints := v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "endpoint1",
+			Namespace:         "ns1",
+			UID:               "uid1",
+			CreationTimestamp: metav1.Unix(1500000000, 0),
+			Labels:            map[string]string{"app": "web"},
+		},
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses: []v1.EndpointAddress{
+					{IP: "10.0.0.1", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "pod1"}},
+					{IP: "10.0.0.2", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "pod2"}},
+				},
+				NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.3"}},
+				Ports:             []v1.EndpointPort{{Name: "http", Port: 8080}, {Name: "https", Port: 8443}},
+			},
+			{
+				Addresses: []v1.EndpointAddress{{IP: "10.0.1.1"}},
+				Ports:     []v1.EndpointPort{{Port: 9090}},
+			},
+		},
+	}
+	opts := &Options{LabelsAllowList: map[string][]string{}}
+	stripped := *endpoints.DeepCopy()
+	stripEndpoints(&stripped, opts)
+
+	want, err := collectText(&endpointCollector{store: mockEndpointStore{list: func() ([]v1.Endpoints, error) { return []v1.Endpoints{endpoints}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectText(&endpointCollector{store: mockEndpointStore{list: func() ([]v1.Endpoints, error) { return []v1.Endpoints{stripped}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || !strings.Contains(want, "kube_endpoint_address_available{") {
+		t.Errorf("expected the metrics of the stripped endpoints to equal\n%s\ngot\n%s", want, got)
+	}
+
+	if a := stripped.Subsets[0].Addresses[0]; a.IP != "" || a.TargetRef != nil {
+		t.Errorf("expected the addresses to be stripped, got %+v", a)
+	}
+	if len(stripped.Labels) != 0 {
+		t.Errorf("expected labels to be stripped, got %v", stripped.Labels)
+	}
+}
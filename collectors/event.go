@@ -0,0 +1,302 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultEventSeriesTTL is how long event series are kept without new
+// events unless Options.EventSeriesTTL is set.
+const defaultEventSeriesTTL = time.Hour
+
+var (
+	descEventTotal = prometheus.NewDesc(
+		"kube_event_total",
+		"Number of times events of the given type and reason were reported for objects of the given kind.",
+		[]string{"namespace", "involved_object_kind", "type", "reason"}, nil,
+	)
+)
+
+// RegisterEventCollector registers a collector counting the events in the
+// given namespaces. Events are not exposed one by one, they are counted in
+// series keyed by namespace, involved object kind, type and reason.
+func RegisterEventCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	client := kubeClient.CoreV1().RESTClient()
+	glog.Infof("collect event with %s", client.APIVersion())
+	sinfs := NewSharedInformerList(client, "events", namespaces, &v1.Event{}, opts)
+	sinfs.SetTransform(func(obj runtime.Object) {
+		stripEvent(obj.(*v1.Event))
+	})
+
+	ttl := defaultEventSeriesTTL
+	if opts != nil && opts.EventSeriesTTL > 0 {
+		ttl = opts.EventSeriesTTL
+	}
+	ec := &eventCollector{opts: opts}
+	einfs := SharedInformerList{}
+	for _, sinf := range *sinfs {
+		ei := newEventInformer(sinf, ttl)
+		ec.counters = append(ec.counters, ei.counter)
+		einfs = append(einfs, ei)
+	}
+
+	registry.mustRegister("event", ec, &einfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		ec.collectEventSeries(ch, obj.(*eventSeries))
+	}, opts)
+}
+
+// eventSeries is the count of the events with the same namespace, involved
+// object kind, type and reason. Its UID is derived from these, so that the
+// metrics store can keep series like it keeps objects.
+type eventSeries struct {
+	metav1.ObjectMeta
+	kind      string
+	eventType string
+	reason    string
+	count     float64
+	lastSeen  time.Time
+}
+
+// eventCounter counts the events of one informer into series. It passes the
+// series on to its handlers instead of the events.
+type eventCounter struct {
+	ttl time.Duration
+
+	mtx      sync.Mutex
+	series   map[types.UID]*eventSeries
+	counts   map[types.UID]int32
+	handlers []cache.ResourceEventHandler
+}
+
+// eventInformer is a cache.SharedInformer whose handlers are passed event
+// series instead of events.
+type eventInformer struct {
+	cache.SharedInformer
+	counter *eventCounter
+}
+
+func newEventInformer(sinf cache.SharedInformer, ttl time.Duration) *eventInformer {
+	ec := &eventCounter{
+		ttl:    ttl,
+		series: map[types.UID]*eventSeries{},
+		counts: map[types.UID]int32{},
+	}
+	sinf.AddEventHandler(ec)
+	return &eventInformer{SharedInformer: sinf, counter: ec}
+}
+
+// AddEventHandler implements the cache.SharedInformer interface.
+func (ei *eventInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	ei.counter.mtx.Lock()
+	defer ei.counter.mtx.Unlock()
+	ei.counter.handlers = append(ei.counter.handlers, handler)
+}
+
+// Run implements the cache.SharedInformer interface. Series without new
+// events for longer than the TTL are removed until stopCh is closed.
+func (ei *eventInformer) Run(stopCh <-chan struct{}) {
+	go wait.Until(func() { ei.counter.expire(time.Now()) }, ei.counter.ttl/4, stopCh)
+	ei.SharedInformer.Run(stopCh)
+}
+
+// failingFor returns for how long listing or watching events has been
+// failing.
+func (ei *eventInformer) failingFor() time.Duration {
+	if mi, ok := ei.SharedInformer.(*monitoredInformer); ok {
+		return mi.failingFor()
+	}
+	return 0
+}
+
+// OnAdd implements the cache.ResourceEventHandler interface.
+func (c *eventCounter) OnAdd(obj interface{}) {
+	c.count(obj.(*v1.Event))
+}
+
+// OnUpdate implements the cache.ResourceEventHandler interface.
+func (c *eventCounter) OnUpdate(oldObj, newObj interface{}) {
+	c.count(newObj.(*v1.Event))
+}
+
+// OnDelete implements the cache.ResourceEventHandler interface. Deleting an
+// event does not change the counts.
+func (c *eventCounter) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	e, ok := obj.(*v1.Event)
+	if !ok {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.counts, e.UID)
+}
+
+// stripEvent drops the fields of the event the counter does not read, like
+// the message and the labels and annotations, which are not exported.
+func stripEvent(e *v1.Event) {
+	*e = v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            e.Name,
+			Namespace:       e.Namespace,
+			UID:             e.UID,
+			ResourceVersion: e.ResourceVersion,
+		},
+		InvolvedObject: v1.ObjectReference{Kind: e.InvolvedObject.Kind},
+		Reason:         e.Reason,
+		Type:           e.Type,
+		Count:          e.Count,
+		Series:         e.Series,
+	}
+}
+
+// count adds the occurrences of e that were not counted yet to its series.
+// Updates of an event raise its count, only the difference to the count
+// seen last is added.
+func (c *eventCounter) count(e *v1.Event) {
+	n := e.Count
+	if e.Series != nil && e.Series.Count > n {
+		n = e.Series.Count
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delta := n - c.counts[e.UID]
+	if delta < 0 {
+		// The count was reset, count the event anew.
+		delta = n
+	}
+	c.counts[e.UID] = n
+	if delta == 0 {
+		return
+	}
+
+	now := time.Now()
+	labelValues := []string{e.Namespace, e.InvolvedObject.Kind, e.Type, e.Reason}
+	uid := types.UID(seriesKey(labelValues))
+	s, ok := c.series[uid]
+	if !ok {
+		s = &eventSeries{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              strings.Join(labelValues, "/"),
+				Namespace:         e.Namespace,
+				UID:               uid,
+				CreationTimestamp: metav1.NewTime(now),
+			},
+			kind:      e.InvolvedObject.Kind,
+			eventType: e.Type,
+			reason:    e.Reason,
+		}
+		c.series[uid] = s
+	}
+	s.count += float64(delta)
+	s.lastSeen = now
+
+	series := *s
+	for _, h := range c.handlers {
+		if ok {
+			h.OnUpdate(&series, &series)
+		} else {
+			h.OnAdd(&series)
+		}
+	}
+}
+
+// expire removes the series that did not see new events for longer than
+// the TTL.
+func (c *eventCounter) expire(now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for uid, s := range c.series {
+		if now.Sub(s.lastSeen) <= c.ttl {
+			continue
+		}
+		delete(c.series, uid)
+		for _, h := range c.handlers {
+			h.OnDelete(s)
+		}
+	}
+}
+
+// list returns copies of all series.
+func (c *eventCounter) list() []eventSeries {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	series := make([]eventSeries, 0, len(c.series))
+	for _, s := range c.series {
+		series = append(series, *s)
+	}
+	return series
+}
+
+// eventCollector collects the counts of the events in the cluster.
+type eventCollector struct {
+	counters []*eventCounter
+	opts     *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (ec *eventCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descEventTotal
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ec *eventCollector) Collect(ch chan<- prometheus.Metric) {
+	series := []eventSeries{}
+	for _, c := range ec.counters {
+		for _, s := range c.list() {
+			if ec.opts.owns(&s) {
+				series = append(series, s)
+			}
+		}
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "event"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "event"}).Observe(float64(len(series)))
+	for i := range series {
+		ec.collectEventSeries(ch, &series[i])
+	}
+
+	glog.V(4).Infof("collected %d event series", len(series))
+}
+
+func (ec *eventCollector) collectEventSeries(ch chan<- prometheus.Metric, s *eventSeries) {
+	if !ec.opts.allows(descEventTotal) {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(descEventTotal, prometheus.CounterValue, s.count, s.Namespace, s.kind, s.eventType, s.reason)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testEvent(uid, namespace, kind, eventType, reason string, count int32) *v1.Event {
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      uid,
+			Namespace: namespace,
+			UID:       types.UID(uid),
+		},
+		InvolvedObject: v1.ObjectReference{Kind: kind},
+		Type:           eventType,
+		Reason:         reason,
+		Count:          count,
+	}
+}
+
+func TestEventCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_event_total Number of times events of the given type and reason were reported for objects of the given kind.
+		# TYPE kube_event_total counter
+	`
+	ei := newEventInformer(cache.NewSharedInformer(&cache.ListWatch{}, &v1.Event{}, 0), time.Hour)
+	c := ei.counter
+	// The first event is updated twice, only the increase is counted.
+	c.OnAdd(testEvent("e1", "ns1", "Pod", "Warning", "BackOff", 1))
+	c.OnUpdate(nil, testEvent("e1", "ns1", "Pod", "Warning", "BackOff", 4))
+	c.OnUpdate(nil, testEvent("e1", "ns1", "Pod", "Warning", "BackOff", 4))
+	c.OnAdd(testEvent("e2", "ns1", "Pod", "Warning", "BackOff", 2))
+	c.OnAdd(testEvent("e3", "ns1", "Pod", "Warning", "FailedScheduling", 0))
+	c.OnAdd(testEvent("e4", "ns2", "Node", "Normal", "Evicted", 1))
+	// Deleting an event keeps its count.
+	c.OnDelete(testEvent("e4", "ns2", "Node", "Normal", "Evicted", 1))
+
+	ec := &eventCollector{counters: []*eventCounter{c}}
+	want := metadata + `
+		kube_event_total{involved_object_kind="Node",namespace="ns2",reason="Evicted",type="Normal"} 1
+		kube_event_total{involved_object_kind="Pod",namespace="ns1",reason="BackOff",type="Warning"} 6
+		kube_event_total{involved_object_kind="Pod",namespace="ns1",reason="FailedScheduling",type="Warning"} 1
+	`
+	if err := gatherAndCompare(ec, want, nil); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+
+	// Series without new events for longer than the TTL expire.
+	for _, s := range c.series {
+		s.lastSeen = time.Now().Add(-2 * time.Hour)
+	}
+	c.OnAdd(testEvent("e5", "ns2", "Node", "Normal", "Evicted", 1))
+	c.expire(time.Now())
+	want = metadata + `
+		kube_event_total{involved_object_kind="Node",namespace="ns2",reason="Evicted",type="Normal"} 2
+	`
+	if err := gatherAndCompare(ec, want, nil); err != nil {
+		t.Errorf("unexpected collecting result after expiry:\n%s", err)
+	}
+}
+
+func TestEventCollectorSeparateSeries(t *testing.T) {
+	const metadata = `
+		# HELP kube_event_total Number of times events of the given type and reason were reported for objects of the given kind.
+		# TYPE kube_event_total counter
+	`
+	ei := newEventInformer(cache.NewSharedInformer(&cache.ListWatch{}, &v1.Event{}, 0), time.Hour)
+	c := ei.counter
+	// Label values containing slashes do not make series share a count.
+	c.OnAdd(testEvent("e1", "ns1", "Pod", "Warning", "Back/Off", 1))
+	c.OnAdd(testEvent("e2", "ns1", "Pod", "Warning/Back", "Off", 2))
+	c.OnAdd(testEvent("e3", "ns1/Pod", "Warning", "Back", "Off", 3))
+
+	ec := &eventCollector{counters: []*eventCounter{c}}
+	want := metadata + `
+		kube_event_total{involved_object_kind="Pod",namespace="ns1",reason="Back/Off",type="Warning"} 1
+		kube_event_total{involved_object_kind="Pod",namespace="ns1",reason="Off",type="Warning/Back"} 2
+		kube_event_total{involved_object_kind="Warning",namespace="ns1/Pod",reason="Off",type="Back"} 3
+	`
+	if err := gatherAndCompare(ec, want, nil); err != nil {
+		t.Errorf("unexpected collecting result:\n%s", err)
+	}
+}
+
+func TestEventCollectorStore(t *testing.T) {
+	fw := watch.NewFake()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &v1.EventList{
+				ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+				Items:    []v1.Event{*testEvent("e1", "ns1", "Pod", "Warning", "FailedMount", 3)},
+			}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fw, nil
+		},
+		DisableChunking: true,
+	}
+	ei := newEventInformer(newMonitoredInformer(lw, &v1.Event{}), time.Hour)
+	ec := &eventCollector{counters: []*eventCounter{ei.counter}}
+	r := NewRegistry()
+	r.mustRegister("event", ec, &SharedInformerList{ei}, func(obj interface{}, ch chan<- prometheus.Metric) {
+		ec.collectEventSeries(ch, obj.(*eventSeries))
+	}, nil)
+
+	fw.Modify(testEvent("e1", "ns1", "Pod", "Warning", "FailedMount", 5))
+	want := `kube_event_total{involved_object_kind="Pod",namespace="ns1",reason="FailedMount",type="Warning"} 5`
+	var got string
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		text, err := writeAllText(r)
+		got = text
+		return strings.Contains(text, want), err
+	})
+	if err != nil {
+		t.Errorf("expected store output to contain %q, got:\n%s", want, got)
+	}
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// fileExtensions are the extensions of the files read from directories.
+var fileExtensions = []string{".json", ".yaml", ".yml"}
+
+// FileStore holds objects read from manifests or kubectl dumps. It answers
+// the list and watch requests of the collectors like an API server holding
+// the same objects would, so that the collectors compute their metrics
+// without a cluster.
+type FileStore struct {
+	// objects holds the objects by resource. Resources are matched by name
+	// only, so that objects dumped in one group version are served in
+	// whichever version a collector watches.
+	objects map[string][]*unstructured.Unstructured
+}
+
+// NewFileStore reads the objects of the given files and directories.
+// Directories are read recursively, taking the files with a .json, .yaml or
+// .yml extension. Files hold one or more YAML or JSON documents, each an
+// object or a List of objects like kubectl get -o json writes.
+func NewFileStore(paths []string) (*FileStore, error) {
+	s := &FileStore{objects: map[string][]*unstructured.Unstructured{}}
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (file != path && !hasFileExtension(file)) {
+				return nil
+			}
+			return s.readFile(file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func hasFileExtension(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range fileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FileStore) readFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var doc map[string]interface{}
+		if err := d.Decode(&doc); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("decoding %s failed: %v", file, err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: doc}
+		if !u.IsList() {
+			if err := s.add(u); err != nil {
+				return fmt.Errorf("reading %s failed: %v", file, err)
+			}
+			continue
+		}
+		err := u.EachListItem(func(obj runtime.Object) error {
+			return s.add(obj.(*unstructured.Unstructured))
+		})
+		if err != nil {
+			return fmt.Errorf("reading %s failed: %v", file, err)
+		}
+	}
+}
+
+func (s *FileStore) add(u *unstructured.Unstructured) error {
+	gvk := u.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return fmt.Errorf("object %s/%s has no apiVersion or kind", u.GetNamespace(), u.GetName())
+	}
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	s.objects[resource.Resource] = append(s.objects[resource.Resource], u)
+	return nil
+}
+
+// Len returns the number of objects of the store.
+func (s *FileStore) Len() int {
+	n := 0
+	for _, objs := range s.objects {
+		n += len(objs)
+	}
+	return n
+}
+
+// RESTConfig returns a config for clients whose requests are answered by
+// the store. Listing returns the stored objects, watches never report any
+// changes. Discovery is not served.
+func (s *FileStore) RESTConfig() *rest.Config {
+	return &rest.Config{Host: "http://offline", Transport: s}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (s *FileStore) RoundTrip(req *http.Request) (*http.Response, error) {
+	gv, namespace, resource, ok := parseResourcePath(req.URL.Path)
+	if req.Method != http.MethodGet || !ok {
+		return statusResponse(req, http.StatusNotFound, "the server could not find the requested resource"), nil
+	}
+
+	query := req.URL.Query()
+	labelSelector, err := labels.Parse(query.Get("labelSelector"))
+	if err != nil {
+		return statusResponse(req, http.StatusBadRequest, err.Error()), nil
+	}
+	fieldSelector, err := fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		return statusResponse(req, http.StatusBadRequest, err.Error()), nil
+	}
+	for _, r := range fieldSelector.Requirements() {
+		if !selectable(resource, r.Field) {
+			return statusResponse(req, http.StatusBadRequest, fmt.Sprintf("field label not supported: %s", r.Field)), nil
+		}
+	}
+
+	if query.Get("watch") == "true" {
+		// Nothing ever changes, the watch stays open until it is stopped.
+		body, _ := io.Pipe()
+		return response(req, http.StatusOK, body), nil
+	}
+
+	items := []interface{}{}
+	kind := listItemKind(gv, resource)
+	for _, obj := range s.objects[resource] {
+		if namespace != "" && obj.GetNamespace() != namespace {
+			continue
+		}
+		if !labelSelector.Matches(labels.Set(obj.GetLabels())) || !fieldSelector.Matches(objectFields(resource, obj)) {
+			continue
+		}
+		item := obj.DeepCopy()
+		item.SetAPIVersion(gv.String())
+		kind = item.GetKind()
+		items = append(items, item.Object)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"apiVersion": gv.String(),
+		"kind":       kind + "List",
+		"metadata":   map[string]interface{}{"resourceVersion": "1"},
+		"items":      items,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response(req, http.StatusOK, ioutil.NopCloser(bytes.NewReader(data))), nil
+}
+
+// selectableField is a field the API server supports in field selectors.
+type selectableField struct {
+	// path is the dot-separated path of the field in the object.
+	path string
+	// missing is the value of the field if it is not set.
+	missing string
+}
+
+// selectableFields holds the fields supported in field selectors by
+// resource, besides metadata.name and metadata.namespace which every
+// resource supports.
+var selectableFields = map[string]map[string]selectableField{
+	"events": {
+		"involvedObject.kind":            {path: "involvedObject.kind"},
+		"involvedObject.namespace":       {path: "involvedObject.namespace"},
+		"involvedObject.name":            {path: "involvedObject.name"},
+		"involvedObject.uid":             {path: "involvedObject.uid"},
+		"involvedObject.apiVersion":      {path: "involvedObject.apiVersion"},
+		"involvedObject.resourceVersion": {path: "involvedObject.resourceVersion"},
+		"involvedObject.fieldPath":       {path: "involvedObject.fieldPath"},
+		"reason":                         {path: "reason"},
+		"source":                         {path: "source.component"},
+		"type":                           {path: "type"},
+	},
+	"jobs": {
+		"status.successful": {path: "status.succeeded", missing: "0"},
+	},
+	"namespaces": {
+		"status.phase": {path: "status.phase"},
+	},
+	"nodes": {
+		"spec.unschedulable": {path: "spec.unschedulable", missing: "false"},
+	},
+	"pods": {
+		"spec.nodeName":           {path: "spec.nodeName"},
+		"spec.restartPolicy":      {path: "spec.restartPolicy"},
+		"spec.schedulerName":      {path: "spec.schedulerName"},
+		"spec.serviceAccountName": {path: "spec.serviceAccountName"},
+		"status.phase":            {path: "status.phase"},
+		"status.podIP":            {path: "status.podIP"},
+	},
+	"replicationcontrollers": {
+		"status.replicas": {path: "status.replicas", missing: "0"},
+	},
+	"secrets": {
+		"type": {path: "type"},
+	},
+}
+
+// selectable reports whether field selectors of resource support field.
+func selectable(resource, field string) bool {
+	if field == "metadata.name" || field == "metadata.namespace" {
+		return true
+	}
+	_, ok := selectableFields[resource][field]
+	return ok
+}
+
+// objectFields returns the values of the fields of obj that field selectors
+// of resource support.
+func objectFields(resource string, obj *unstructured.Unstructured) fields.Set {
+	set := fields.Set{"metadata.name": obj.GetName(), "metadata.namespace": obj.GetNamespace()}
+	for field, f := range selectableFields[resource] {
+		v := fieldAtPath(obj.Object, f.path)
+		if v == nil {
+			set[field] = f.missing
+			continue
+		}
+		set[field] = fmt.Sprint(v)
+	}
+	return set
+}
+
+// parseResourcePath splits the path of a request for a collection into its
+// group version, namespace and resource.
+func parseResourcePath(path string) (schema.GroupVersion, string, string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var gv schema.GroupVersion
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		gv, parts = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		gv, parts = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return gv, "", "", false
+	}
+
+	switch {
+	case len(parts) == 1:
+		return gv, "", parts[0], true
+	case len(parts) == 3 && parts[0] == "namespaces":
+		return gv, parts[1], parts[2], true
+	}
+	return gv, "", "", false
+}
+
+// listItemKind returns the kind of the resource in gv, as registered with
+// the client scheme. It is used to name empty lists, and falls back to the
+// generic List kind for unknown resources.
+func listItemKind(gv schema.GroupVersion, resource string) string {
+	for kind := range scheme.Scheme.KnownTypes(gv) {
+		if strings.HasSuffix(kind, "List") {
+			continue
+		}
+		if r, _ := meta.UnsafeGuessKindToResource(gv.WithKind(kind)); r.Resource == resource {
+			return kind
+		}
+	}
+	return ""
+}
+
+func statusResponse(req *http.Request, code int, message string) *http.Response {
+	data, _ := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Status",
+		"status":     "Failure",
+		"message":    message,
+		"code":       code,
+	})
+	return response(req, code, ioutil.NopCloser(bytes.NewReader(data)))
+}
+
+func response(req *http.Request, code int, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       body,
+		Request:    req,
+	}
+}
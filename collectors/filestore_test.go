@@ -0,0 +1,249 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	fileStoreManifests = `
+apiVersion: v1
+kind: Node
+metadata:
+  name: node1
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+  namespace: ns1
+  labels:
+    app: web
+spec:
+  nodeName: node1
+`
+	fileStoreDump = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod2", "namespace": "ns2"}},
+    {"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "depl1", "namespace": "ns1"}, "spec": {"replicas": 3}}
+  ]
+}`
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"manifests.yaml":  fileStoreManifests,
+		"dumps/all.json":  fileStoreDump,
+		"dumps/README.md": "not a manifest",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := NewFileStore([]string{dir})
+	if err != nil {
+		t.Fatalf("reading files failed: %s", err)
+	}
+	if store.Len() != 4 {
+		t.Errorf("expected 4 objects, got %d", store.Len())
+	}
+	kubeClient, err := kubernetes.NewForConfig(store.RESTConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{LabelSelectors: map[string]string{"pods": "app=web"}}
+	r := NewRegistry()
+	for _, register := range []RegisterFunc{RegisterPodCollector, RegisterNodeCollector, RegisterDeploymentCollector, RegisterJobCollector} {
+		register(r, kubeClient, []string{"ns1"}, opts)
+	}
+	defer func() {
+		for _, name := range r.Enabled() {
+			r.Disable(name)
+		}
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(r.Unsynced()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("caches did not sync: %v", r.Unsynced())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	got, err := gatherText(r)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	for _, want := range []string{
+		`kube_node_info{`,
+		`kube_pod_info{created_by_kind="<none>",created_by_name="<none>",host_ip="",namespace="ns1",node="node1",pod="pod1",pod_ip=""} 1`,
+		`kube_deployment_spec_replicas{deployment="depl1",namespace="ns1"} 3`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "pod2") {
+		t.Errorf("expected pod2 in namespace ns2 to be filtered out, got:\n%s", got)
+	}
+}
+
+func TestFileStoreRejectsObjectsWithoutKind(t *testing.T) {
+	f, err := ioutil.TempFile("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`{"metadata": {"name": "pod1"}}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := NewFileStore([]string{f.Name()}); err == nil {
+		t.Error("expected an error for an object without kind")
+	}
+}
+
+func TestFileStoreFieldSelectors(t *testing.T) {
+	f, err := ioutil.TempFile("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+  namespace: ns1
+spec:
+  nodeName: node1
+status:
+  phase: Running
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod2
+  namespace: ns1
+status:
+  phase: Succeeded
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node1
+spec:
+  unschedulable: true
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: node2
+`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	store, err := NewFileStore([]string{f.Name()})
+	if err != nil {
+		t.Fatalf("reading files failed: %s", err)
+	}
+	kubeClient, err := kubernetes.NewForConfig(store.RESTConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		resource string
+		selector string
+		want     []string
+		err      bool
+	}{
+		{resource: "pods", selector: "status.phase!=Succeeded", want: []string{"pod1"}},
+		{resource: "pods", selector: "spec.nodeName=node1", want: []string{"pod1"}},
+		{resource: "pods", selector: "spec.nodeName=", want: []string{"pod2"}},
+		{resource: "pods", selector: "metadata.name=pod2,metadata.namespace=ns1", want: []string{"pod2"}},
+		{resource: "nodes", selector: "spec.unschedulable=false", want: []string{"node2"}},
+		{resource: "nodes", selector: "spec.unschedulable!=false", want: []string{"node1"}},
+		// Unsupported fields are rejected like the API server does,
+		// instead of matching no or all objects.
+		{resource: "pods", selector: "spec.hostname=pod1", err: true},
+		{resource: "nodes", selector: "status.phase=Running", err: true},
+	}
+
+	for _, c := range cases {
+		opts := metav1.ListOptions{FieldSelector: c.selector}
+		var got []string
+		if c.resource == "pods" {
+			var pods *v1.PodList
+			pods, err = kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(opts)
+			if err == nil {
+				for _, p := range pods.Items {
+					got = append(got, p.Name)
+				}
+			}
+		} else {
+			var nodes *v1.NodeList
+			nodes, err = kubeClient.CoreV1().Nodes().List(opts)
+			if err == nil {
+				for _, n := range nodes.Items {
+					got = append(got, n.Name)
+				}
+			}
+		}
+		if c.err {
+			if err == nil {
+				t.Errorf("%s with %q: expected an error", c.resource, c.selector)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s with %q: unexpected error: %s", c.resource, c.selector, err)
+			continue
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s with %q: expected %v, got %v", c.resource, c.selector, c.want, got)
+		}
+	}
+}
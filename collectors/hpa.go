@@ -17,15 +17,10 @@ limitations under the License.
 package collectors
 
 import (
-	"context"
-
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	autoscaling "k8s.io/api/autoscaling/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -33,6 +28,10 @@ var (
 	descHorizontalPodAutoscalerLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descHorizontalPodAutoscalerLabelsDefaultLabels = []string{"namespace", "hpa"}
 
+	descHorizontalPodAutoscalerAnnotationsName          = "kube_hpa_annotations"
+	descHorizontalPodAutoscalerAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descHorizontalPodAutoscalerAnnotationsDefaultLabels = []string{"namespace", "hpa"}
+
 	descHorizontalPodAutoscalerMetadataGeneration = prometheus.NewDesc(
 		"kube_hpa_metadata_generation",
 		"The generation observed by the HorizontalPodAutoscaler controller.",
@@ -63,6 +62,12 @@ var (
 		descHorizontalPodAutoscalerLabelsHelp,
 		descHorizontalPodAutoscalerLabelsDefaultLabels, nil,
 	)
+
+	descHorizontalPodAutoscalerAnnotations = prometheus.NewDesc(
+		descHorizontalPodAutoscalerAnnotationsName,
+		descHorizontalPodAutoscalerAnnotationsHelp,
+		descHorizontalPodAutoscalerAnnotationsDefaultLabels, nil,
+	)
 )
 
 type HPALister func() (autoscaling.HorizontalPodAutoscalerList, error)
@@ -71,21 +76,29 @@ func (l HPALister) List() (autoscaling.HorizontalPodAutoscalerList, error) {
 	return l()
 }
 
-func RegisterHorizontalPodAutoScalerCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.Autoscaling().RESTClient()
-	glog.Infof("collect hpa with %s", client.APIVersion())
-	hpalw := cache.NewListWatchFromClient(client, "horizontalpodautoscalers", metav1.NamespaceAll, fields.Everything())
-	hpainf := cache.NewSharedInformer(hpalw, &autoscaling.HorizontalPodAutoscaler{}, resyncPeriod)
+func RegisterHorizontalPodAutoScalerCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	hpainfs, err := newVersionedInformerList(kubeClient, "horizontalpodautoscalers", namespaces, &autoscaling.HorizontalPodAutoscaler{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch horizontalpodautoscalers: %v", err)
+		return
+	}
 
 	hpaLister := HPALister(func() (hpas autoscaling.HorizontalPodAutoscalerList, err error) {
-		for _, h := range hpainf.GetStore().List() {
-			hpas.Items = append(hpas.Items, *(h.(*autoscaling.HorizontalPodAutoscaler)))
+		for _, hpainf := range *hpainfs {
+			for _, h := range hpainf.GetStore().List() {
+				if !opts.owns(h) {
+					continue
+				}
+				hpas.Items = append(hpas.Items, *(h.(*autoscaling.HorizontalPodAutoscaler)))
+			}
 		}
 		return hpas, nil
 	})
 
-	registry.MustRegister(&hpaCollector{store: hpaLister})
-	go hpainf.Run(context.Background().Done())
+	hc := &hpaCollector{store: hpaLister, opts: opts}
+	registry.mustRegister("horizontalpodautoscaler", hc, hpainfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		hc.collectHPA(ch, *obj.(*autoscaling.HorizontalPodAutoscaler))
+	}, opts)
 }
 
 type hpaStore interface {
@@ -95,6 +108,7 @@ type hpaStore interface {
 // hpaCollector collects metrics about all Horizontal Pod Austoscalers in the cluster.
 type hpaCollector struct {
 	store hpaStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -105,6 +119,7 @@ func (hc *hpaCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descHorizontalPodAutoscalerStatusCurrentReplicas
 	ch <- descHorizontalPodAutoscalerStatusDesiredReplicas
 	ch <- descHorizontalPodAutoscalerLabels
+	ch <- descHorizontalPodAutoscalerAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -134,13 +149,30 @@ func hpaLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func hpaAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descHorizontalPodAutoscalerAnnotationsName,
+		descHorizontalPodAutoscalerAnnotationsHelp,
+		append(descHorizontalPodAutoscalerAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (hc *hpaCollector) collectHPA(ch chan<- prometheus.Metric, h autoscaling.HorizontalPodAutoscaler) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !hc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{h.Namespace, h.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(h.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(hc.opts.allowedLabels("horizontalpodautoscalers", h.Labels))
 	addGauge(hpaLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := hc.opts.allowedAnnotations("horizontalpodautoscalers", h.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(hpaAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 	addGauge(descHorizontalPodAutoscalerMetadataGeneration, float64(h.ObjectMeta.Generation))
 	addGauge(descHorizontalPodAutoscalerSpecMaxReplicas, float64(h.Spec.MaxReplicas))
 	addGauge(descHorizontalPodAutoscalerSpecMinReplicas, float64(*h.Spec.MinReplicas))
@@ -49,6 +49,8 @@ func TestHPACollector(t *testing.T) {
 		# TYPE kube_hpa_status_current_replicas gauge
 		# HELP kube_hpa_status_desired_replicas Desired number of replicas of pods managed by this autoscaler.
 		# TYPE kube_hpa_status_desired_replicas gauge
+		# HELP kube_hpa_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_hpa_annotations gauge
 	`
 	cases := []struct {
 		hpas    []autoscaling.HorizontalPodAutoscaler
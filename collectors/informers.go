@@ -0,0 +1,262 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
+)
+
+// defaultPageSize is the number of objects listed per request unless
+// Options.PageSize is set.
+const defaultPageSize = 500
+
+// TransformFunc modifies an object before it enters the informer cache,
+// typically to drop the fields its collector does not read.
+type TransformFunc func(obj runtime.Object)
+
+// SharedInformerList holds the informers watching one resource, one
+// informer per watched namespace.
+type SharedInformerList []cache.SharedInformer
+
+// NewSharedInformerList creates an informer for the resource in each of the
+// given namespaces. Cluster-scoped resources are passed
+// []string{metav1.NamespaceAll}.
+func NewSharedInformerList(client cache.Getter, resource string, namespaces []string, objType runtime.Object, opts *Options) *SharedInformerList {
+	sinfs := SharedInformerList{}
+	for _, namespace := range namespaces {
+		slw := newListWatch(client, resource, namespace, opts)
+		sinfs = append(sinfs, newMonitoredInformer(slw, objType))
+	}
+	return &sinfs
+}
+
+// newListWatch creates a cache.ListWatch for the resource in the namespace.
+// The label and field selectors configured for the resource are passed to
+// the apiserver, so objects not matching them never enter the cache. Lists
+// of the latest resource version are requested in pages of Options.PageSize
+// objects, each page request taking at most Options.ListTimeout. Watches are
+// not bounded by the timeout.
+func newListWatch(client cache.Getter, resource, namespace string, opts *Options) *cache.ListWatch {
+	labelSelector, fieldSelector := opts.selectors(resource)
+	pageSize, timeout := opts.listSettings()
+	listPage := func(options metav1.ListOptions) (runtime.Object, error) {
+		options.LabelSelector = labelSelector
+		options.FieldSelector = fieldSelector
+		req := client.Get().
+			Namespace(namespace).
+			Resource(resource).
+			VersionedParams(&options, metav1.ParameterCodec)
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			req = req.Timeout(timeout).Context(ctx)
+		}
+		return req.Do().Get()
+	}
+	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
+		// The reflector lists at resource version "0", which the apiserver
+		// serves from its watch cache in a single response. Only lists of
+		// the latest version, read from etcd, are paginated.
+		if options.ResourceVersion != "" {
+			return listPage(options)
+		}
+		p := pager.New(pager.SimplePageFunc(listPage))
+		p.PageSize = pageSize
+		return p.List(context.Background(), options)
+	}
+	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
+		options.Watch = true
+		options.LabelSelector = labelSelector
+		options.FieldSelector = fieldSelector
+		return client.Get().
+			Namespace(namespace).
+			Resource(resource).
+			VersionedParams(&options, metav1.ParameterCodec).
+			Watch()
+	}
+	// listFunc paginates lists of the latest version itself with the
+	// configured page size.
+	return &cache.ListWatch{ListFunc: listFunc, WatchFunc: watchFunc, DisableChunking: true}
+}
+
+// selectors returns the label and field selectors configured for the
+// resource. Empty selectors match all objects.
+func (o *Options) selectors(resource string) (string, string) {
+	if o == nil {
+		return "", fields.Everything().String()
+	}
+	fieldSelector := o.FieldSelectors[resource]
+	if fieldSelector == "" {
+		fieldSelector = fields.Everything().String()
+	}
+	return o.LabelSelectors[resource], fieldSelector
+}
+
+// listSettings returns the page size and the timeout of list requests.
+func (o *Options) listSettings() (int64, time.Duration) {
+	if o == nil {
+		return defaultPageSize, 0
+	}
+	if o.PageSize == 0 {
+		return defaultPageSize, o.ListTimeout
+	}
+	return o.PageSize, o.ListTimeout
+}
+
+// AddEventHandler adds handler to all informers of the list.
+func (sil SharedInformerList) AddEventHandler(handler cache.ResourceEventHandler) {
+	for _, sinf := range sil {
+		sinf.AddEventHandler(handler)
+	}
+}
+
+// SetTransform makes the informers of the list apply transform to every
+// listed and watched object before caching it. It must be called before the
+// informers are run.
+func (sil SharedInformerList) SetTransform(transform TransformFunc) {
+	for _, sinf := range sil {
+		if mi, ok := sinf.(*monitoredInformer); ok {
+			mi.lw.transform = transform
+		}
+	}
+}
+
+// Run starts all informers of the list. They stop once stopCh is closed.
+func (sil SharedInformerList) Run(stopCh <-chan struct{}) {
+	for _, sinf := range sil {
+		go sinf.Run(stopCh)
+	}
+}
+
+// HasSynced reports whether all informers of the list have synced.
+func (sil SharedInformerList) HasSynced() bool {
+	for _, sinf := range sil {
+		if !sinf.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// failingFor returns for how long listing or watching the resource has been
+// failing without interruption for any of the informers of the list.
+func (sil SharedInformerList) failingFor() time.Duration {
+	var longest time.Duration
+	for _, sinf := range sil {
+		mi, ok := sinf.(interface {
+			failingFor() time.Duration
+		})
+		if !ok {
+			continue
+		}
+		if d := mi.failingFor(); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// monitoredInformer is a cache.SharedInformer whose list and watch calls are
+// monitored for failures.
+type monitoredInformer struct {
+	cache.SharedInformer
+	lw *monitoredListWatch
+}
+
+// failingFor returns for how long listing or watching has been failing.
+func (mi *monitoredInformer) failingFor() time.Duration {
+	return mi.lw.failingFor()
+}
+
+func newMonitoredInformer(lw cache.ListerWatcher, objType runtime.Object) *monitoredInformer {
+	mlw := &monitoredListWatch{ListerWatcher: lw}
+	return &monitoredInformer{
+		SharedInformer: cache.NewSharedInformer(mlw, objType, resyncPeriod),
+		lw:             mlw,
+	}
+}
+
+// monitoredListWatch records since when the calls of the wrapped
+// cache.ListerWatcher have been failing. A successful call resets it. If a
+// transform is set, it is applied to the listed and watched objects.
+type monitoredListWatch struct {
+	cache.ListerWatcher
+	transform TransformFunc
+
+	mtx          sync.Mutex
+	failingSince time.Time
+}
+
+// List implements the cache.ListerWatcher interface.
+func (lw *monitoredListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	obj, err := lw.ListerWatcher.List(options)
+	lw.observe(err)
+	if err != nil || lw.transform == nil {
+		return obj, err
+	}
+	err = meta.EachListItem(obj, func(item runtime.Object) error {
+		lw.transform(item)
+		return nil
+	})
+	return obj, err
+}
+
+// Watch implements the cache.ListerWatcher interface.
+func (lw *monitoredListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := lw.ListerWatcher.Watch(options)
+	lw.observe(err)
+	if err != nil || lw.transform == nil {
+		return w, err
+	}
+	return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+		if in.Type != watch.Error {
+			lw.transform(in.Object)
+		}
+		return in, true
+	}), nil
+}
+
+func (lw *monitoredListWatch) observe(err error) {
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+
+	if err == nil {
+		lw.failingSince = time.Time{}
+	} else if lw.failingSince.IsZero() {
+		lw.failingSince = time.Now()
+	}
+}
+
+func (lw *monitoredListWatch) failingFor() time.Duration {
+	lw.mtx.Lock()
+	defer lw.mtx.Unlock()
+
+	if lw.failingSince.IsZero() {
+		return 0
+	}
+	return time.Since(lw.failingSince)
+}
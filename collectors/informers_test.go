@@ -0,0 +1,298 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func fakeListWatch(listErr error) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			if listErr != nil {
+				return nil, listErr
+			}
+			return &v1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+		DisableChunking: true,
+	}
+}
+
+func TestRegistryReadiness(t *testing.T) {
+	healthy := &SharedInformerList{newMonitoredInformer(fakeListWatch(nil), &v1.Pod{})}
+	failing := &SharedInformerList{newMonitoredInformer(fakeListWatch(errors.New("forbidden")), &v1.Node{})}
+
+	if healthy.HasSynced() || failing.HasSynced() {
+		t.Errorf("informers report to be synced before they were started")
+	}
+
+	// Registering starts the informers.
+	r := NewRegistry()
+	r.mustRegister("pod", &podCollector{}, healthy, func(interface{}, chan<- prometheus.Metric) {}, nil)
+	r.mustRegister("node", &nodeCollector{}, failing, func(interface{}, chan<- prometheus.Metric) {}, nil)
+
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return healthy.HasSynced() && failing.failingFor() > 0, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for informers failed: %s", err)
+	}
+
+	if got, want := r.Unsynced(), []string{"node"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unsynced resources: got %v, want %v", got, want)
+	}
+	if got, want := r.FailingWatches(0), []string{"node"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failing watches: got %v, want %v", got, want)
+	}
+	if got := r.FailingWatches(time.Hour); len(got) != 0 {
+		t.Errorf("failing watches with one hour timeout: got %v, want none", got)
+	}
+}
+
+func TestNewListWatchSelectors(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[]}`))
+	}))
+	defer srv.Close()
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("creating client failed: %s", err)
+	}
+	client := kubeClient.CoreV1().RESTClient()
+
+	cases := []struct {
+		opts          *Options
+		labelSelector string
+		fieldSelector string
+	}{
+		{
+			opts: nil,
+		},
+		{
+			opts: &Options{
+				LabelSelectors: map[string]string{"pods": "app!=batch", "nodes": "pool=a"},
+				FieldSelectors: map[string]string{"pods": "status.phase!=Succeeded"},
+			},
+			labelSelector: "app!=batch",
+			fieldSelector: "status.phase!=Succeeded",
+		},
+		{
+			opts: &Options{
+				LabelSelectors: map[string]string{"nodes": "pool=a"},
+			},
+		},
+	}
+	for _, c := range cases {
+		lw := newListWatch(client, "pods", "default", c.opts)
+		if _, err := lw.ListFunc(metav1.ListOptions{}); err != nil {
+			t.Fatalf("listing failed: %s", err)
+		}
+		if got := query.Get("labelSelector"); got != c.labelSelector {
+			t.Errorf("label selector for %+v: got %q, want %q", c.opts, got, c.labelSelector)
+		}
+		if got := query.Get("fieldSelector"); got != c.fieldSelector {
+			t.Errorf("field selector for %+v: got %q, want %q", c.opts, got, c.fieldSelector)
+		}
+	}
+}
+
+func TestRegistryEnableDisable(t *testing.T) {
+	pods := &SharedInformerList{newMonitoredInformer(fakeListWatch(nil), &v1.Pod{})}
+	nodes := &SharedInformerList{newMonitoredInformer(fakeListWatch(errors.New("forbidden")), &v1.Node{})}
+
+	r := NewRegistry()
+	var podStore *MetricsStore
+	r.Enable("pods", func(r *Registry) {
+		podStore = r.mustRegister("pod", &podCollector{}, pods, func(interface{}, chan<- prometheus.Metric) {}, nil)
+	})
+	r.Enable("nodes", func(r *Registry) {
+		r.mustRegister("node", &nodeCollector{}, nodes, func(interface{}, chan<- prometheus.Metric) {}, nil)
+	})
+	if got, want := r.Enabled(), []string{"nodes", "pods"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("enabled collectors: got %v, want %v", got, want)
+	}
+
+	r.Disable("nodes")
+	r.Disable("services")
+	if got, want := r.Enabled(), []string{"pods"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("enabled collectors after disabling: got %v, want %v", got, want)
+	}
+	if got := r.FailingWatches(0); len(got) != 0 {
+		t.Errorf("failing watches of disabled collectors: got %v, want none", got)
+	}
+	if len(r.stores) != 1 || r.stores[0] != podStore {
+		t.Errorf("expected only the pod store to remain, got %d stores", len(r.stores))
+	}
+
+	// Enabling again after disabling registers the collector anew.
+	nodes = &SharedInformerList{newMonitoredInformer(fakeListWatch(nil), &v1.Node{})}
+	r.Enable("nodes", func(r *Registry) {
+		r.mustRegister("node", &nodeCollector{}, nodes, func(interface{}, chan<- prometheus.Metric) {}, nil)
+	})
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(r.Unsynced()) == 0, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for informers failed: %s", err)
+	}
+	if len(r.stores) != 2 {
+		t.Errorf("expected 2 stores after enabling again, got %d", len(r.stores))
+	}
+}
+
+func TestSharedInformerListTransform(t *testing.T) {
+	secret := func(name string) v1.Secret {
+		return v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					"team": "payments",
+					"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"password":"c2VjcmV0"}}`,
+				},
+			},
+			Data: map[string][]byte{"password": []byte("secret")},
+			Type: v1.SecretTypeOpaque,
+		}
+	}
+	fw := watch.NewFake()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &v1.SecretList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}, Items: []v1.Secret{secret("listed")}}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fw, nil
+		},
+		DisableChunking: true,
+	}
+	opts := &Options{AnnotationsAllowList: map[string][]string{"secrets": {"team"}}}
+	sinfs := SharedInformerList{newMonitoredInformer(lw, &v1.Secret{})}
+	sinfs.SetTransform(func(obj runtime.Object) {
+		stripSecret(obj.(*v1.Secret), opts)
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	sinfs.Run(stopCh)
+	watched := secret("watched")
+	fw.Add(&watched)
+
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return len(sinfs[0].GetStore().List()) == 2, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for informers failed: %s", err)
+	}
+	for _, obj := range sinfs[0].GetStore().List() {
+		s := obj.(*v1.Secret)
+		if s.Data != nil {
+			t.Errorf("secret %s: expected data to be stripped, got %v", s.Name, s.Data)
+		}
+		if want := map[string]string{"team": "payments"}; !reflect.DeepEqual(s.Annotations, want) {
+			t.Errorf("secret %s: expected annotations %v, got %v", s.Name, want, s.Annotations)
+		}
+		if s.Type != v1.SecretTypeOpaque {
+			t.Errorf("secret %s: expected type to be kept, got %q", s.Name, s.Type)
+		}
+	}
+}
+
+func TestNewListWatchPagination(t *testing.T) {
+	var queries []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("continue") == "" {
+			w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"1","continue":"page2"},"items":[{"metadata":{"name":"pod1"}},{"metadata":{"name":"pod2"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"1"},"items":[{"metadata":{"name":"pod3"}}]}`))
+	}))
+	defer srv.Close()
+
+	kubeClient, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("creating client failed: %s", err)
+	}
+	lw := newListWatch(kubeClient.CoreV1().RESTClient(), "pods", "default", &Options{PageSize: 2, ListTimeout: time.Minute})
+
+	list, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing failed: %s", err)
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		t.Fatalf("extracting list items failed: %s", err)
+	}
+	if len(items) != 3 {
+		t.Errorf("expected 3 pods, got %d", len(items))
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(queries))
+	}
+	for i, query := range queries {
+		if got := query.Get("limit"); got != "2" {
+			t.Errorf("request %d: expected limit 2, got %q", i, got)
+		}
+		if got := query.Get("timeout"); got != "1m0s" {
+			t.Errorf("request %d: expected timeout 1m0s, got %q", i, got)
+		}
+	}
+	if got := queries[1].Get("continue"); got != "page2" {
+		t.Errorf("expected continue page2, got %q", got)
+	}
+
+	// The lists of the reflector at resource version "0" are served from the
+	// watch cache of the apiserver in a single request.
+	queries = nil
+	if _, err := lw.List(metav1.ListOptions{ResourceVersion: "0"}); err != nil {
+		t.Fatalf("listing at resource version 0 failed: %s", err)
+	}
+	if len(queries) != 1 {
+		t.Fatalf("expected 1 request at resource version 0, got %d", len(queries))
+	}
+	if got := queries[0].Get("resourceVersion"); got != "0" {
+		t.Errorf("expected resource version 0, got %q", got)
+	}
+	if got := queries[0].Get("limit"); got != "" {
+		t.Errorf("expected no limit at resource version 0, got %q", got)
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descIngressLabelsName          = "kube_ingress_labels"
+	descIngressLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descIngressLabelsDefaultLabels = []string{"namespace", "ingress"}
+
+	descIngressAnnotationsName          = "kube_ingress_annotations"
+	descIngressAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descIngressAnnotationsDefaultLabels = []string{"namespace", "ingress"}
+
+	descIngressInfo = prometheus.NewDesc(
+		"kube_ingress_info",
+		"Information about ingress.",
+		[]string{"namespace", "ingress"}, nil,
+	)
+
+	descIngressCreated = prometheus.NewDesc(
+		"kube_ingress_created",
+		"Unix creation timestamp",
+		[]string{"namespace", "ingress"}, nil,
+	)
+
+	descIngressPath = prometheus.NewDesc(
+		"kube_ingress_path",
+		"Ingress host, paths and backend service information. The default backend has an empty host and path.",
+		[]string{"namespace", "ingress", "host", "path", "service_name", "service_port"}, nil,
+	)
+
+	descIngressStatusLoadBalancerIngress = prometheus.NewDesc(
+		"kube_ingress_status_load_balancer_ingress",
+		"Load balancer ingress point of the ingress.",
+		[]string{"namespace", "ingress", "ip", "hostname"}, nil,
+	)
+
+	descIngressTLS = prometheus.NewDesc(
+		"kube_ingress_tls",
+		"Ingress TLS host and secret information.",
+		[]string{"namespace", "ingress", "tls_host", "secret"}, nil,
+	)
+
+	descIngressLabels = prometheus.NewDesc(
+		descIngressLabelsName,
+		descIngressLabelsHelp,
+		descIngressLabelsDefaultLabels, nil,
+	)
+
+	descIngressAnnotations = prometheus.NewDesc(
+		descIngressAnnotationsName,
+		descIngressAnnotationsHelp,
+		descIngressAnnotationsDefaultLabels, nil,
+	)
+)
+
+type IngressLister func() ([]v1beta1.Ingress, error)
+
+func (l IngressLister) List() ([]v1beta1.Ingress, error) {
+	return l()
+}
+
+func RegisterIngressCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	iinfs, err := newVersionedInformerList(kubeClient, "ingresses", namespaces, &v1beta1.Ingress{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch ingresses: %v", err)
+		return
+	}
+
+	ingressLister := IngressLister(func() (ingresses []v1beta1.Ingress, err error) {
+		for _, iinf := range *iinfs {
+			for _, m := range iinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				ingresses = append(ingresses, *m.(*v1beta1.Ingress))
+			}
+		}
+		return ingresses, nil
+	})
+
+	ic := &ingressCollector{store: ingressLister, opts: opts}
+	registry.mustRegister("ingress", ic, iinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		ic.collectIngress(ch, *obj.(*v1beta1.Ingress))
+	}, opts)
+}
+
+type ingressStore interface {
+	List() (ingresses []v1beta1.Ingress, err error)
+}
+
+// ingressCollector collects metrics about all ingresses in the cluster.
+type ingressCollector struct {
+	store ingressStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (ic *ingressCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descIngressInfo
+	ch <- descIngressLabels
+	ch <- descIngressAnnotations
+	ch <- descIngressCreated
+	ch <- descIngressPath
+	ch <- descIngressStatusLoadBalancerIngress
+	ch <- descIngressTLS
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ic *ingressCollector) Collect(ch chan<- prometheus.Metric) {
+	ingresses, err := ic.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "ingress"}).Inc()
+		glog.Errorf("listing ingresses failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "ingress"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "ingress"}).Observe(float64(len(ingresses)))
+	for _, i := range ingresses {
+		ic.collectIngress(ch, i)
+	}
+	glog.V(4).Infof("collected %d ingresses", len(ingresses))
+}
+
+func ingressLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descIngressLabelsName,
+		descIngressLabelsHelp,
+		append(descIngressLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func ingressAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descIngressAnnotationsName,
+		descIngressAnnotationsHelp,
+		append(descIngressAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (ic *ingressCollector) collectIngress(ch chan<- prometheus.Metric, i v1beta1.Ingress) {
+	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !ic.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{i.Namespace, i.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
+	}
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		addConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	addGauge(descIngressInfo, 1)
+	if !i.CreationTimestamp.IsZero() {
+		addGauge(descIngressCreated, float64(i.CreationTimestamp.Unix()))
+	}
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(ic.opts.allowedLabels("ingresses", i.Labels))
+	addGauge(ingressLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := ic.opts.allowedAnnotations("ingresses", i.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(ingressAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
+	for _, p := range ingressPaths(i) {
+		addGauge(descIngressPath, 1, p.host, p.path, p.serviceName, p.servicePort)
+	}
+
+	for _, lb := range i.Status.LoadBalancer.Ingress {
+		addGauge(descIngressStatusLoadBalancerIngress, 1, lb.IP, lb.Hostname)
+	}
+
+	for _, t := range ingressTLSHosts(i) {
+		addGauge(descIngressTLS, 1, t.host, t.secret)
+	}
+}
+
+// ingressPath is the host, path and backend service of a kube_ingress_path
+// series.
+type ingressPath struct {
+	host, path, serviceName, servicePort string
+}
+
+// ingressPaths returns the paths of the ingress, the default backend first,
+// without the repeated ones, which would otherwise be reported as duplicate
+// series.
+func ingressPaths(i v1beta1.Ingress) []ingressPath {
+	var paths []ingressPath
+	if b := i.Spec.Backend; b != nil {
+		paths = append(paths, ingressPath{serviceName: b.ServiceName, servicePort: b.ServicePort.String()})
+	}
+	for _, rule := range i.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			paths = append(paths, ingressPath{
+				host:        rule.Host,
+				path:        path.Path,
+				serviceName: path.Backend.ServiceName,
+				servicePort: path.Backend.ServicePort.String(),
+			})
+		}
+	}
+
+	seen := map[ingressPath]bool{}
+	unique := make([]ingressPath, 0, len(paths))
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+// ingressTLSHost is the host and secret of a kube_ingress_tls series.
+type ingressTLSHost struct {
+	host, secret string
+}
+
+// ingressTLSHosts returns the TLS hosts of the ingress without the repeated
+// ones, which would otherwise be reported as duplicate series.
+func ingressTLSHosts(i v1beta1.Ingress) []ingressTLSHost {
+	seen := map[ingressTLSHost]bool{}
+	var unique []ingressTLSHost
+	for _, tls := range i.Spec.TLS {
+		for _, host := range tls.Hosts {
+			t := ingressTLSHost{host: host, secret: tls.SecretName}
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type mockIngressStore struct {
+	list func() ([]v1beta1.Ingress, error)
+}
+
+func (is mockIngressStore) List() ([]v1beta1.Ingress, error) {
+	return is.list()
+}
+
+func TestIngressCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_ingress_info Information about ingress.
+		# TYPE kube_ingress_info gauge
+		# HELP kube_ingress_created Unix creation timestamp
+		# TYPE kube_ingress_created gauge
+		# HELP kube_ingress_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_ingress_labels gauge
+		# HELP kube_ingress_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_ingress_annotations gauge
+		# HELP kube_ingress_path Ingress host, paths and backend service information. The default backend has an empty host and path.
+		# TYPE kube_ingress_path gauge
+		# HELP kube_ingress_status_load_balancer_ingress Load balancer ingress point of the ingress.
+		# TYPE kube_ingress_status_load_balancer_ingress gauge
+		# HELP kube_ingress_tls Ingress TLS host and secret information.
+		# TYPE kube_ingress_tls gauge
+	`
+	cases := []struct {
+		ingresses []v1beta1.Ingress
+		metrics   []string // which metrics should be checked
+		want      string
+	}{
+		{
+			ingresses: []v1beta1.Ingress{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "ingress1",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Namespace:         "ns1",
+						Labels: map[string]string{
+							"app": "example1",
+						},
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: &v1beta1.IngressBackend{
+							ServiceName: "default-backend",
+							ServicePort: intstr.FromInt(80),
+						},
+						Rules: []v1beta1.IngressRule{
+							{
+								Host: "example.com",
+								IngressRuleValue: v1beta1.IngressRuleValue{
+									HTTP: &v1beta1.HTTPIngressRuleValue{
+										Paths: []v1beta1.HTTPIngressPath{
+											{
+												Path:    "/api",
+												Backend: v1beta1.IngressBackend{ServiceName: "api", ServicePort: intstr.FromString("http")},
+											},
+											{
+												Path:    "/",
+												Backend: v1beta1.IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(8080)},
+											},
+										},
+									},
+								},
+							},
+							{
+								Host: "no-paths.example.com",
+							},
+						},
+						TLS: []v1beta1.IngressTLS{
+							{
+								Hosts:      []string{"example.com", "www.example.com"},
+								SecretName: "example-tls",
+							},
+						},
+					},
+					Status: v1beta1.IngressStatus{
+						LoadBalancer: v1.LoadBalancerStatus{
+							Ingress: []v1.LoadBalancerIngress{
+								{IP: "1.2.3.4"},
+								{Hostname: "lb.example.com"},
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "ingress2",
+						Namespace: "ns2",
+					},
+				},
+			},
+			want: metadata + `
+				kube_ingress_created{ingress="ingress1",namespace="ns1"} 1.5e+09
+				kube_ingress_info{ingress="ingress1",namespace="ns1"} 1
+				kube_ingress_info{ingress="ingress2",namespace="ns2"} 1
+				kube_ingress_labels{ingress="ingress1",label_app="example1",namespace="ns1"} 1
+				kube_ingress_labels{ingress="ingress2",namespace="ns2"} 1
+				kube_ingress_path{host="",ingress="ingress1",namespace="ns1",path="",service_name="default-backend",service_port="80"} 1
+				kube_ingress_path{host="example.com",ingress="ingress1",namespace="ns1",path="/",service_name="web",service_port="8080"} 1
+				kube_ingress_path{host="example.com",ingress="ingress1",namespace="ns1",path="/api",service_name="api",service_port="http"} 1
+				kube_ingress_status_load_balancer_ingress{hostname="",ingress="ingress1",ip="1.2.3.4",namespace="ns1"} 1
+				kube_ingress_status_load_balancer_ingress{hostname="lb.example.com",ingress="ingress1",ip="",namespace="ns1"} 1
+				kube_ingress_tls{ingress="ingress1",namespace="ns1",secret="example-tls",tls_host="example.com"} 1
+				kube_ingress_tls{ingress="ingress1",namespace="ns1",secret="example-tls",tls_host="www.example.com"} 1
+			`,
+		},
+		// Repeated paths and TLS hosts are reported once.
+		{
+			ingresses: []v1beta1.Ingress{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "ingress3",
+						Namespace: "ns3",
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: &v1beta1.IngressBackend{
+							ServiceName: "web",
+							ServicePort: intstr.FromInt(80),
+						},
+						Rules: []v1beta1.IngressRule{
+							{
+								IngressRuleValue: v1beta1.IngressRuleValue{
+									HTTP: &v1beta1.HTTPIngressRuleValue{
+										Paths: []v1beta1.HTTPIngressPath{
+											{Backend: v1beta1.IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(80)}},
+										},
+									},
+								},
+							},
+							{
+								Host: "example.com",
+								IngressRuleValue: v1beta1.IngressRuleValue{
+									HTTP: &v1beta1.HTTPIngressRuleValue{
+										Paths: []v1beta1.HTTPIngressPath{
+											{Path: "/api", Backend: v1beta1.IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt(8080)}},
+										},
+									},
+								},
+							},
+							{
+								Host: "example.com",
+								IngressRuleValue: v1beta1.IngressRuleValue{
+									HTTP: &v1beta1.HTTPIngressRuleValue{
+										Paths: []v1beta1.HTTPIngressPath{
+											{Path: "/api", Backend: v1beta1.IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt(8080)}},
+										},
+									},
+								},
+							},
+						},
+						TLS: []v1beta1.IngressTLS{
+							{
+								Hosts:      []string{"example.com", "example.com"},
+								SecretName: "example-tls",
+							},
+							{
+								Hosts:      []string{"example.com"},
+								SecretName: "example-tls",
+							},
+							{
+								Hosts:      []string{"example.com"},
+								SecretName: "other-tls",
+							},
+						},
+					},
+				},
+			},
+			metrics: []string{"kube_ingress_path", "kube_ingress_tls"},
+			want: `
+				# HELP kube_ingress_path Ingress host, paths and backend service information. The default backend has an empty host and path.
+				# TYPE kube_ingress_path gauge
+				# HELP kube_ingress_tls Ingress TLS host and secret information.
+				# TYPE kube_ingress_tls gauge
+				kube_ingress_path{host="",ingress="ingress3",namespace="ns3",path="",service_name="web",service_port="80"} 1
+				kube_ingress_path{host="example.com",ingress="ingress3",namespace="ns3",path="/api",service_name="api",service_port="8080"} 1
+				kube_ingress_tls{ingress="ingress3",namespace="ns3",secret="example-tls",tls_host="example.com"} 1
+				kube_ingress_tls{ingress="ingress3",namespace="ns3",secret="other-tls",tls_host="example.com"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		ic := &ingressCollector{
+			store: &mockIngressStore{
+				list: func() ([]v1beta1.Ingress, error) {
+					return c.ingresses, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(ic, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
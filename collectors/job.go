@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	v1batch "k8s.io/api/batch/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,12 +28,22 @@ var (
 	descJobLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descJobLabelsDefaultLabels = []string{"namespace", "job"}
 
+	descJobAnnotationsName          = "kube_job_annotations"
+	descJobAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descJobAnnotationsDefaultLabels = []string{"namespace", "job"}
+
 	descJobLabels = prometheus.NewDesc(
 		descJobLabelsName,
 		descJobLabelsHelp,
 		descJobLabelsDefaultLabels, nil,
 	)
 
+	descJobAnnotations = prometheus.NewDesc(
+		descJobAnnotationsName,
+		descJobAnnotationsHelp,
+		descJobAnnotationsDefaultLabels, nil,
+	)
+
 	descJobInfo = prometheus.NewDesc(
 		"kube_job_info",
 		"Information about job.",
@@ -105,21 +112,29 @@ func (l JobLister) List() ([]v1batch.Job, error) {
 	return l()
 }
 
-func RegisterJobCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.BatchV1().RESTClient()
-	glog.Infof("collect job with %s", client.APIVersion())
-	jlw := cache.NewListWatchFromClient(client, "jobs", namespace, fields.Everything())
-	jinf := cache.NewSharedInformer(jlw, &v1batch.Job{}, resyncPeriod)
+func RegisterJobCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	jinfs, err := newVersionedInformerList(kubeClient, "jobs", namespaces, &v1batch.Job{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch jobs: %v", err)
+		return
+	}
 
 	jobLister := JobLister(func() (jobs []v1batch.Job, err error) {
-		for _, c := range jinf.GetStore().List() {
-			jobs = append(jobs, *(c.(*v1batch.Job)))
+		for _, jinf := range *jinfs {
+			for _, c := range jinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				jobs = append(jobs, *(c.(*v1batch.Job)))
+			}
 		}
 		return jobs, nil
 	})
 
-	registry.MustRegister(&jobCollector{store: jobLister})
-	go jinf.Run(context.Background().Done())
+	jc := &jobCollector{store: jobLister, opts: opts}
+	registry.mustRegister("job", jc, jinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		jc.collectJob(ch, *obj.(*v1batch.Job))
+	}, opts)
 }
 
 type jobStore interface {
@@ -129,6 +144,7 @@ type jobStore interface {
 // jobCollector collects metrics about all jobs in the cluster.
 type jobCollector struct {
 	store jobStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -136,6 +152,7 @@ func (dc *jobCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descJobInfo
 	ch <- descJobCreated
 	ch <- descJobLabels
+	ch <- descJobAnnotations
 	ch <- descJobSpecParallelism
 	ch <- descJobSpecCompletions
 	ch <- descJobSpecActiveDeadlineSeconds
@@ -175,21 +192,41 @@ func jobLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func jobAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descJobAnnotationsName,
+		descJobAnnotationsHelp,
+		append(descJobAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (jc *jobCollector) collectJob(ch chan<- prometheus.Metric, j v1batch.Job) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !jc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{j.Namespace, j.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
 	addCounter := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !jc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{j.Namespace, j.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, lv...)
 	}
 
 	addGauge(descJobInfo, 1)
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(j.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(jc.opts.allowedLabels("jobs", j.Labels))
 	addGauge(jobLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := jc.opts.allowedAnnotations("jobs", j.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(jobAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	if j.Spec.Parallelism != nil {
 		addGauge(descJobSpecParallelism, float64(*j.Spec.Parallelism))
 	}
@@ -220,9 +257,9 @@ func (jc *jobCollector) collectJob(ch chan<- prometheus.Metric, j v1batch.Job) {
 	for _, c := range j.Status.Conditions {
 		switch c.Type {
 		case v1batch.JobComplete:
-			addConditionMetrics(ch, descJobConditionComplete, c.Status, j.Namespace, j.Name)
+			addConditionMetrics(ch, jc.opts, descJobConditionComplete, c.Status, j.Namespace, j.Name)
 		case v1batch.JobFailed:
-			addConditionMetrics(ch, descJobConditionFailed, c.Status, j.Namespace, j.Name)
+			addConditionMetrics(ch, jc.opts, descJobConditionFailed, c.Status, j.Namespace, j.Name)
 		}
 	}
 }
@@ -78,6 +78,8 @@ func TestJobCollector(t *testing.T) {
 		# TYPE kube_job_status_start_time counter
 		# HELP kube_job_status_succeeded The number of pods which reached Phase Succeeded.
 		# TYPE kube_job_status_succeeded gauge
+		# HELP kube_job_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_job_annotations gauge
 	`
 	cases := []struct {
 		jobs []v1batch.Job
@@ -19,14 +19,15 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
+	descLimitRangeAnnotationsName          = "kube_limitrange_annotations"
+	descLimitRangeAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descLimitRangeAnnotationsDefaultLabels = []string{"limitrange", "namespace"}
+
 	descLimitRange = prometheus.NewDesc(
 		"kube_limitrange",
 		"Information about limit range.",
@@ -44,6 +45,12 @@ var (
 		"Unix creation timestamp",
 		[]string{"limitrange", "namespace"}, nil,
 	)
+
+	descLimitRangeAnnotations = prometheus.NewDesc(
+		descLimitRangeAnnotationsName,
+		descLimitRangeAnnotationsHelp,
+		descLimitRangeAnnotationsDefaultLabels, nil,
+	)
 )
 
 type LimitRangeLister func() (v1.LimitRangeList, error)
@@ -52,21 +59,27 @@ func (l LimitRangeLister) List() (v1.LimitRangeList, error) {
 	return l()
 }
 
-func RegisterLimitRangeCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterLimitRangeCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect limitrange with %s", client.APIVersion())
-	rqlw := cache.NewListWatchFromClient(client, "limitranges", namespace, fields.Everything())
-	rqinf := cache.NewSharedInformer(rqlw, &v1.LimitRange{}, resyncPeriod)
+	rqinfs := NewSharedInformerList(client, "limitranges", namespaces, &v1.LimitRange{}, opts)
 
 	limitRangeLister := LimitRangeLister(func() (ranges v1.LimitRangeList, err error) {
-		for _, rq := range rqinf.GetStore().List() {
-			ranges.Items = append(ranges.Items, *(rq.(*v1.LimitRange)))
+		for _, rqinf := range *rqinfs {
+			for _, rq := range rqinf.GetStore().List() {
+				if !opts.owns(rq) {
+					continue
+				}
+				ranges.Items = append(ranges.Items, *(rq.(*v1.LimitRange)))
+			}
 		}
 		return ranges, nil
 	})
 
-	registry.MustRegister(&limitRangeCollector{store: limitRangeLister})
-	go rqinf.Run(context.Background().Done())
+	lrc := &limitRangeCollector{store: limitRangeLister, opts: opts}
+	registry.mustRegister("limitrange", lrc, rqinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		lrc.collectLimitRange(ch, *obj.(*v1.LimitRange))
+	}, opts)
 }
 
 type limitRangeStore interface {
@@ -76,12 +89,14 @@ type limitRangeStore interface {
 // limitRangeCollector collects metrics about all limit ranges in the cluster.
 type limitRangeCollector struct {
 	store limitRangeStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
 func (lrc *limitRangeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descLimitRange
 	ch <- descLimitRangeCreated
+	ch <- descLimitRangeAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -104,6 +119,9 @@ func (lrc *limitRangeCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (lrc *limitRangeCollector) collectLimitRange(ch chan<- prometheus.Metric, rq v1.LimitRange) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !lrc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{rq.Name, rq.Namespace}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -135,4 +153,17 @@ func (lrc *limitRangeCollector) collectLimitRange(ch chan<- prometheus.Metric, r
 
 	}
 
+	if annotations, ok := lrc.opts.allowedAnnotations("limitranges", rq.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(limitRangeAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+func limitRangeAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descLimitRangeAnnotationsName,
+		descLimitRangeAnnotationsHelp,
+		append(descLimitRangeAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
 }
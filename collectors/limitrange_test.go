@@ -43,6 +43,8 @@ func TestLimitRangeollector(t *testing.T) {
 	# TYPE kube_limitrange_created gauge
 	# HELP kube_limitrange Information about limit range.
 	# TYPE kube_limitrange gauge
+	# HELP kube_limitrange_annotations Kubernetes annotations converted to Prometheus labels.
+	# TYPE kube_limitrange_annotations gauge
 	`
 	cases := []struct {
 		ranges  []v1.LimitRange
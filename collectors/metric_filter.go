@@ -0,0 +1,155 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// filtersMetrics reports whether any metric families are excluded.
+func (o *Options) filtersMetrics() bool {
+	return o != nil && (o.MetricAllowList != nil || o.MetricDenyList != nil)
+}
+
+// allowsMetric reports whether the metric family with the given name is
+// exposed. A family is exposed if it matches the allow list, or no allow list
+// is set, and it does not match the deny list.
+func (o *Options) allowsMetric(name string) bool {
+	if o == nil {
+		return true
+	}
+	if o.MetricAllowList != nil && !o.MetricAllowList.MatchString(name) {
+		return false
+	}
+	return o.MetricDenyList == nil || !o.MetricDenyList.MatchString(name)
+}
+
+// allows reports whether metrics with desc are exposed. Collectors check it
+// before generating a metric, so that the metrics of excluded families are
+// never generated.
+func (o *Options) allows(desc *prometheus.Desc) bool {
+	if !o.filtersMetrics() {
+		return true
+	}
+	if name, ok := descNames.Load(desc); ok {
+		return o.allowsMetric(name.(string))
+	}
+	return o.allowsMetric(descName(desc))
+}
+
+// descNames caches the names of the descriptors described by registered
+// collectors, to not format a descriptor for every generated metric.
+// Descriptors created while collecting, like those of the *_labels families,
+// are not cached.
+var descNames sync.Map
+
+// metricFilter hides the descriptors of excluded families. The descriptors
+// of a collector are checked once, when the filter is created.
+type metricFilter struct {
+	opts    *Options
+	descs   map[*prometheus.Desc]bool
+	allowed []string
+	denied  []string
+}
+
+func newMetricFilter(c prometheus.Collector, opts *Options) *metricFilter {
+	f := &metricFilter{
+		opts:  opts,
+		descs: map[*prometheus.Desc]bool{},
+	}
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	seen := map[string]bool{}
+	for desc := range ch {
+		name := descName(desc)
+		descNames.Store(desc, name)
+		allowed := opts.allowsMetric(name)
+		f.descs[desc] = allowed
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if allowed {
+			f.allowed = append(f.allowed, name)
+		} else {
+			f.denied = append(f.denied, name)
+		}
+	}
+	sort.Strings(f.allowed)
+	sort.Strings(f.denied)
+	return f
+}
+
+// allows reports whether metrics with the given descriptor are exposed.
+// Descriptors created while collecting, like those of the *_labels families,
+// are checked by name.
+func (f *metricFilter) allows(desc *prometheus.Desc) bool {
+	if allowed, ok := f.descs[desc]; ok {
+		return allowed
+	}
+	return f.opts.allowsMetric(descName(desc))
+}
+
+// filteredCollector is a prometheus.Collector exposing only the metric
+// families of the wrapped collector that are not excluded. The collectors of
+// this package skip excluded families themselves; the metrics of other
+// collectors are dropped after they are collected.
+type filteredCollector struct {
+	collector prometheus.Collector
+	filter    *metricFilter
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *filteredCollector) Describe(ch chan<- *prometheus.Desc) {
+	for desc, allowed := range c.filter.descs {
+		if allowed {
+			ch <- desc
+		}
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *filteredCollector) Collect(ch chan<- prometheus.Metric) {
+	in := make(chan prometheus.Metric)
+	go func() {
+		c.collector.Collect(in)
+		close(in)
+	}()
+	for m := range in {
+		if c.filter.allows(m.Desc()) {
+			ch <- m
+		}
+	}
+}
+
+// descName returns the fully-qualified name of desc, which prometheus.Desc
+// only exposes through its String method.
+func descName(desc *prometheus.Desc) string {
+	s := strings.TrimPrefix(desc.String(), `Desc{fqName: "`)
+	if i := strings.IndexByte(s, '"'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
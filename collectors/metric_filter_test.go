@@ -0,0 +1,162 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetricFilter(t *testing.T) {
+	services := []v1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-service1",
+				Namespace: "default",
+				UID:       "uid-service1",
+				Labels: map[string]string{
+					"app": "example1",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "1.2.3.4",
+				Type:      v1.ServiceTypeClusterIP,
+			},
+		},
+	}
+
+	cases := []struct {
+		opts *Options
+		want string
+	}{
+		{
+			opts: &Options{
+				MetricDenyList: regexp.MustCompile(`^(?:kube_service_spec_type|kube_service_created)$`),
+			},
+			want: `
+				# HELP kube_service_info Information about service.
+				# TYPE kube_service_info gauge
+				kube_service_info{cluster_ip="1.2.3.4",namespace="default",service="test-service1"} 1
+				# HELP kube_service_labels Kubernetes labels converted to Prometheus labels.
+				# TYPE kube_service_labels gauge
+				kube_service_labels{label_app="example1",namespace="default",service="test-service1"} 1
+			`,
+		},
+		{
+			opts: &Options{
+				MetricAllowList: regexp.MustCompile(`^(?:kube_service_.*)$`),
+				MetricDenyList:  regexp.MustCompile(`^(?:kube_service_(info|spec_type))$`),
+			},
+			want: `
+				# HELP kube_service_labels Kubernetes labels converted to Prometheus labels.
+				# TYPE kube_service_labels gauge
+				kube_service_labels{label_app="example1",namespace="default",service="test-service1"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		sc := &serviceCollector{
+			store: &mockServiceStore{
+				list: func() ([]v1.Service, error) {
+					return services, nil
+				},
+			},
+			opts: c.opts,
+		}
+		f := newMetricFilter(sc, c.opts)
+		if err := gatherAndCompare(&filteredCollector{collector: sc, filter: f}, c.want, nil); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+
+		r := NewRegistry()
+		s := r.mustRegister("service", sc, &SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {
+			sc.collectService(ch, *obj.(*v1.Service))
+		}, c.opts)
+		for i := range services {
+			s.OnAdd(&services[i])
+		}
+		got, err := writeAllText(r)
+		if err != nil {
+			t.Fatalf("writing metrics failed: %s", err)
+		}
+		if want := removeUnusedWhitespace(c.want); strings.TrimSpace(got) != strings.TrimSpace(want) {
+			t.Errorf("unexpected store output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+		}
+
+		// Excluded families are not generated at all.
+		ch := make(chan prometheus.Metric, 100)
+		sc.collectService(ch, services[0])
+		close(ch)
+		for m := range ch {
+			if name := descName(m.Desc()); !c.opts.allowsMetric(name) {
+				t.Errorf("expected no metrics of the excluded family %s to be generated", name)
+			}
+		}
+	}
+}
+
+func TestMetricFilterExternalCollector(t *testing.T) {
+	// Collectors built elsewhere do not check the options, their metrics of
+	// excluded families are dropped by the store.
+	services := []v1.Service{{ObjectMeta: metav1.ObjectMeta{Name: "test-service1", Namespace: "default", UID: "uid-service1"}}}
+	sc := &serviceCollector{store: &mockServiceStore{list: func() ([]v1.Service, error) { return services, nil }}}
+	opts := &Options{MetricAllowList: regexp.MustCompile(`^(?:kube_service_info)$`)}
+
+	r := NewRegistry()
+	s := r.mustRegister("service", sc, &SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {
+		sc.collectService(ch, *obj.(*v1.Service))
+	}, opts)
+	s.OnAdd(&services[0])
+
+	want := `# HELP kube_service_info Information about service.
+# TYPE kube_service_info gauge
+kube_service_info{cluster_ip="",namespace="default",service="test-service1"} 1
+`
+	for _, output := range []func() (string, error){
+		func() (string, error) { return writeAllText(r) },
+		func() (string, error) { return gatherText(r) },
+	} {
+		got, err := output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("unexpected output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+		}
+	}
+}
+
+func TestMetricFilterExcludesCollector(t *testing.T) {
+	sc := &serviceCollector{}
+	opts := &Options{MetricAllowList: regexp.MustCompile(`^(?:kube_pod_.*)$`)}
+
+	r := NewRegistry()
+	s := r.mustRegister("service", sc, &SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {
+		sc.collectService(ch, *obj.(*v1.Service))
+	}, opts)
+	if s != nil {
+		t.Errorf("expected no store for a collector whose metric families are all excluded")
+	}
+	if len(r.stores) != 0 {
+		t.Errorf("expected no registered stores, got %d", len(r.stores))
+	}
+}
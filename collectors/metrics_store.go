@@ -0,0 +1,551 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// metricsGenerator sends the metrics of a single object to ch.
+type metricsGenerator func(obj interface{}, ch chan<- prometheus.Metric)
+
+// MetricsStore keeps the metrics of all objects of one resource rendered in
+// the text exposition format. It implements cache.ResourceEventHandler, so
+// that the metrics of an object are only generated when the object changes
+// instead of on every scrape.
+type MetricsStore struct {
+	resource string
+	opts     *Options
+	generate metricsGenerator
+	// unwatched is set for stores of collectors that do not watch any
+	// objects. They are rendered again on every scrape.
+	unwatched bool
+
+	// renderMtx guards renderer and the object it is currently rendering.
+	renderMtx sync.Mutex
+	renderer  *prometheus.Registry
+	rendering interface{}
+
+	mtx sync.RWMutex
+	// headers holds the HELP and TYPE lines of each metric family.
+	headers map[string][]byte
+	// metadata holds the help text and type of each metric family.
+	metadata map[string]familyMetadata
+	// families holds the rendered series of each metric family by object.
+	families map[string]map[types.UID][]renderedSeries
+	// objects holds the names of the metric families of each object.
+	objects map[types.UID][]string
+	// series holds the number of series of each metric family.
+	series map[string]int
+	// dropped holds the number of series of each metric family dropped
+	// because of the series limit, by object.
+	dropped map[string]map[types.UID]int
+}
+
+// renderedSeries is a single sample line of the text exposition format,
+// together with its label values to order it the way prometheus.Registry
+// orders the metrics of a family.
+type renderedSeries struct {
+	labelValues []string
+	text        []byte
+	// created is the creation timestamp of the object in seconds, zero if
+	// it is not known.
+	created int64
+}
+
+type familyMetadata struct {
+	help string
+	typ  dto.MetricType
+}
+
+// storeCollector lets the renderer registry collect the object currently
+// being rendered by a MetricsStore.
+type storeCollector struct {
+	describe func(ch chan<- *prometheus.Desc)
+	store    *MetricsStore
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.store.generate(c.store.rendering, ch)
+}
+
+func newMetricsStore(resource string, c prometheus.Collector, generate metricsGenerator, opts *Options) *MetricsStore {
+	s := &MetricsStore{
+		resource: resource,
+		opts:     opts,
+		generate: generate,
+		renderer: prometheus.NewRegistry(),
+		headers:  map[string][]byte{},
+		metadata: map[string]familyMetadata{},
+		families: map[string]map[types.UID][]renderedSeries{},
+		objects:  map[types.UID][]string{},
+		series:   map[string]int{},
+		dropped:  map[string]map[types.UID]int{},
+	}
+	s.renderer.MustRegister(&storeCollector{describe: c.Describe, store: s})
+	return s
+}
+
+// OnAdd implements the cache.ResourceEventHandler interface.
+func (s *MetricsStore) OnAdd(obj interface{}) {
+	s.update(obj)
+}
+
+// OnUpdate implements the cache.ResourceEventHandler interface.
+func (s *MetricsStore) OnUpdate(oldObj, newObj interface{}) {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err == nil {
+		newMeta, err := meta.Accessor(newObj)
+		// Periodic resyncs deliver unchanged objects, there is nothing to
+		// render again for them.
+		if err == nil && oldMeta.GetResourceVersion() != "" && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+			return
+		}
+	}
+	s.update(newObj)
+}
+
+// OnDelete implements the cache.ResourceEventHandler interface.
+func (s *MetricsStore) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		glog.Errorf("deleting %s metrics failed: %s", s.resource, err)
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.delete(m.GetUID())
+	for _, objects := range s.dropped {
+		delete(objects, m.GetUID())
+	}
+}
+
+func (s *MetricsStore) update(obj interface{}) {
+	if !s.opts.owns(obj) {
+		return
+	}
+	s.updateObject(obj)
+}
+
+// unwatchedObject stands in for the objects of collectors that do not watch
+// any, so that their metrics are kept like those of a single object.
+var unwatchedObject = &metav1.ObjectMeta{UID: "unwatched"}
+
+// refresh renders the metrics of an unwatched store again. Unlike objects,
+// which belong to a single shard, the metrics are served by every shard.
+func (s *MetricsStore) refresh() {
+	if s.unwatched {
+		s.updateObject(unwatchedObject)
+	}
+}
+
+func (s *MetricsStore) updateObject(obj interface{}) {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		glog.Errorf("updating %s metrics failed: %s", s.resource, err)
+		return
+	}
+
+	families, err := s.render(obj)
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": s.resource}).Inc()
+		glog.Errorf("rendering metrics of %s %s/%s failed: %s", s.resource, m.GetNamespace(), m.GetName(), err)
+		return
+	}
+
+	var created int64
+	if t := m.GetCreationTimestamp(); !t.IsZero() {
+		created = t.Unix()
+	}
+
+	uid := m.GetUID()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.delete(uid)
+	names := make([]string, 0, len(families))
+	dropped := map[string]int{}
+	for _, f := range families {
+		if _, ok := s.headers[f.name]; !ok {
+			s.headers[f.name] = f.header
+			s.metadata[f.name] = f.metadata
+		}
+		if limit := s.opts.maxSeriesPerFamily(); limit > 0 && s.series[f.name]+len(f.series) > limit {
+			keep := limit - s.series[f.name]
+			if keep < 0 {
+				keep = 0
+			}
+			dropped[f.name] = len(f.series) - keep
+			glog.V(2).Infof("Dropping %d series of %s %s/%s, %s reached the limit of %d series", len(f.series)-keep, s.resource, m.GetNamespace(), m.GetName(), f.name, limit)
+			f.series = f.series[:keep]
+		}
+		for i := range f.series {
+			f.series[i].created = created
+		}
+		s.series[f.name] += len(f.series)
+		if _, ok := s.families[f.name]; !ok {
+			s.families[f.name] = map[types.UID][]renderedSeries{}
+		}
+		s.families[f.name][uid] = f.series
+		names = append(names, f.name)
+	}
+	s.objects[uid] = names
+	s.countDropped(uid, dropped)
+}
+
+// countDropped records the number of series of each metric family dropped
+// for the object with the given UID. Only series that were not dropped on a
+// previous update of the object are counted, so that each dropped series is
+// counted once. The caller must hold s.mtx.
+func (s *MetricsStore) countDropped(uid types.UID, dropped map[string]int) {
+	for name, objects := range s.dropped {
+		if _, ok := dropped[name]; !ok {
+			delete(objects, uid)
+		}
+	}
+	for name, n := range dropped {
+		if _, ok := s.dropped[name]; !ok {
+			s.dropped[name] = map[types.UID]int{}
+		}
+		if previous := s.dropped[name][uid]; n > previous {
+			SeriesDroppedTotalMetric.WithLabelValues(name).Add(float64(n - previous))
+		}
+		s.dropped[name][uid] = n
+	}
+}
+
+// delete removes the metrics of the object with the given UID. The caller
+// must hold s.mtx.
+func (s *MetricsStore) delete(uid types.UID) {
+	for _, name := range s.objects[uid] {
+		s.series[name] -= len(s.families[name][uid])
+		delete(s.families[name], uid)
+	}
+	delete(s.objects, uid)
+}
+
+type renderedFamily struct {
+	name     string
+	header   []byte
+	metadata familyMetadata
+	series   []renderedSeries
+}
+
+// render generates the metrics of obj and encodes every series on its own.
+func (s *MetricsStore) render(obj interface{}) ([]renderedFamily, error) {
+	s.renderMtx.Lock()
+	s.rendering = obj
+	mfs, err := s.renderer.Gather()
+	s.rendering = nil
+	s.renderMtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	families := make([]renderedFamily, 0, len(mfs))
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		// Collectors not built on Options.allows may still generate the
+		// metrics of excluded families.
+		if !s.opts.allowsMetric(mf.GetName()) {
+			continue
+		}
+		f := renderedFamily{
+			name:     mf.GetName(),
+			metadata: familyMetadata{help: mf.GetHelp(), typ: mf.GetType()},
+			series:   make([]renderedSeries, 0, len(mf.Metric)),
+		}
+		for _, m := range mf.Metric {
+			buf.Reset()
+			_, err := expfmt.MetricFamilyToText(&buf, &dto.MetricFamily{
+				Name:   mf.Name,
+				Help:   mf.Help,
+				Type:   mf.Type,
+				Metric: []*dto.Metric{m},
+			})
+			if err != nil {
+				return nil, err
+			}
+			text := buf.Bytes()
+			n := headerLen(text)
+			if f.header == nil {
+				f.header = append([]byte(nil), text[:n]...)
+			}
+			labelValues := make([]string, len(m.Label))
+			for i, l := range m.Label {
+				labelValues[i] = l.GetValue()
+			}
+			f.series = append(f.series, renderedSeries{
+				labelValues: labelValues,
+				text:        append([]byte(nil), text[n:]...),
+			})
+		}
+		families = append(families, f)
+	}
+	return families, nil
+}
+
+// headerLen returns the length of the leading comment lines of text.
+func headerLen(text []byte) int {
+	n := 0
+	for n < len(text) && text[n] == '#' {
+		i := bytes.IndexByte(text[n:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		n += i + 1
+	}
+	return n
+}
+
+// familyNames returns the names of all metric families that currently have
+// at least one series.
+func (s *MetricsStore) familyNames() []string {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	names := make([]string, 0, len(s.families))
+	for name, objects := range s.families {
+		if len(objects) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// familySeries returns the number of series of the named metric family.
+func (s *MetricsStore) familySeries(name string) int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.series[name]
+}
+
+// addFamilySeries adds the number of series of each metric family of the
+// store to series.
+func (s *MetricsStore) addFamilySeries(series map[string]int) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	for name, n := range s.series {
+		if n > 0 {
+			series[name] += n
+		}
+	}
+}
+
+// keptSeries returns the series kept of each metric family that reached the
+// series limit, by their label values joined with seriesKey. Families below
+// the limit are not included.
+func (s *MetricsStore) keptSeries() map[string]map[string]bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	kept := map[string]map[string]bool{}
+	for name, objects := range s.dropped {
+		if len(objects) == 0 {
+			continue
+		}
+		keys := map[string]bool{}
+		for _, ss := range s.families[name] {
+			for _, se := range ss {
+				keys[seriesKey(se.labelValues)] = true
+			}
+		}
+		kept[name] = keys
+	}
+	return kept
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+// cappedCollector is a prometheus.Collector exposing only the series of the
+// wrapped collector that its metrics store kept under the series limit, so
+// that collecting and gathering yields the same series as the store output.
+type cappedCollector struct {
+	collector prometheus.Collector
+	store     *MetricsStore
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *cappedCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.collector.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *cappedCollector) Collect(ch chan<- prometheus.Metric) {
+	kept := c.store.keptSeries()
+	if len(kept) == 0 {
+		c.collector.Collect(ch)
+		return
+	}
+
+	in := make(chan prometheus.Metric)
+	go func() {
+		c.collector.Collect(in)
+		close(in)
+	}()
+	var pb dto.Metric
+	for m := range in {
+		if keys, ok := kept[descName(m.Desc())]; ok {
+			pb.Reset()
+			// Metrics failing to write are passed on for the registry to
+			// report.
+			if err := m.Write(&pb); err == nil {
+				labelValues := make([]string, len(pb.Label))
+				for i, l := range pb.Label {
+					labelValues[i] = l.GetValue()
+				}
+				if !keys[seriesKey(labelValues)] {
+					continue
+				}
+			}
+		}
+		ch <- m
+	}
+}
+
+// observe updates the self metrics of the store, as collectors do on every
+// scrape.
+func (s *MetricsStore) observe() {
+	s.mtx.RLock()
+	n := len(s.objects)
+	s.mtx.RUnlock()
+
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": s.resource}).Add(0)
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": s.resource}).Observe(float64(n))
+}
+
+// writeFamily writes the metric family with the given name to w, with its
+// series in the same order prometheus.Registry would gather them.
+func (s *MetricsStore) writeFamily(w io.Writer, name string) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	series := s.sortedSeries(name)
+	if len(series) == 0 {
+		return nil
+	}
+	if _, err := w.Write(s.headers[name]); err != nil {
+		return err
+	}
+	for _, se := range series {
+		if _, err := w.Write(se.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedSeries returns the series of the named metric family in the order
+// prometheus.Registry would gather them. The caller must hold s.mtx.
+//
+// Like prometheus.Registry, series with the same label values as a previous
+// series of the family are dropped and counted as scrape error. They occur
+// when different objects, like a deleted pod and the pod replacing it, yield
+// the same series.
+func (s *MetricsStore) sortedSeries(name string) []*renderedSeries {
+	objects := s.families[name]
+	series := make([]*renderedSeries, 0, len(objects))
+	for _, ss := range objects {
+		for i := range ss {
+			series = append(series, &ss[i])
+		}
+	}
+	sort.Sort(seriesSorter(series))
+
+	unique := series[:0]
+	for _, se := range series {
+		if n := len(unique); n > 0 && equalLabelValues(unique[n-1].labelValues, se.labelValues) {
+			continue
+		}
+		unique = append(unique, se)
+	}
+	if dropped := len(series) - len(unique); dropped > 0 {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": s.resource}).Inc()
+		glog.V(2).Infof("Dropped %d duplicate series of %s", dropped, name)
+	}
+	return unique
+}
+
+func equalLabelValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// seriesSorter orders series like the metricSorter of prometheus.Registry.
+type seriesSorter []*renderedSeries
+
+func (s seriesSorter) Len() int {
+	return len(s)
+}
+
+func (s seriesSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s seriesSorter) Less(i, j int) bool {
+	if len(s[i].labelValues) != len(s[j].labelValues) {
+		return len(s[i].labelValues) < len(s[j].labelValues)
+	}
+	for n, vi := range s[i].labelValues {
+		if vj := s[j].labelValues[n]; vi != vj {
+			return vi < vj
+		}
+	}
+	// Duplicate series are ordered by their text, so that the same one is
+	// kept on every scrape.
+	return bytes.Compare(s[i].text, s[j].text) < 0
+}
+
+// maxSeriesPerFamily returns the series limit of each metric family, zero
+// if there is none.
+func (o *Options) maxSeriesPerFamily() int {
+	if o == nil {
+		return 0
+	}
+	return o.MaxSeriesPerFamily
+}
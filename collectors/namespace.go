@@ -19,12 +19,9 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -73,21 +70,27 @@ func (l NamespaceLister) List() ([]v1.Namespace, error) {
 }
 
 // RegisterNamespaceCollector registry namespace collector
-func RegisterNamespaceCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterNamespaceCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect namespace with %s", client.APIVersion())
-	nslw := cache.NewListWatchFromClient(client, "namespaces", metav1.NamespaceAll, fields.Everything())
-	nsinf := cache.NewSharedInformer(nslw, &v1.Namespace{}, resyncPeriod)
+	nsinfs := NewSharedInformerList(client, "namespaces", []string{metav1.NamespaceAll}, &v1.Namespace{}, opts)
 
 	namespaceLister := NamespaceLister(func() (namespaces []v1.Namespace, err error) {
-		for _, ns := range nsinf.GetStore().List() {
-			namespaces = append(namespaces, *(ns.(*v1.Namespace)))
+		for _, nsinf := range *nsinfs {
+			for _, ns := range nsinf.GetStore().List() {
+				if !opts.owns(ns) {
+					continue
+				}
+				namespaces = append(namespaces, *(ns.(*v1.Namespace)))
+			}
 		}
 		return namespaces, nil
 	})
 
-	registry.MustRegister(&namespaceCollector{store: namespaceLister})
-	go nsinf.Run(context.Background().Done())
+	nsc := &namespaceCollector{store: namespaceLister, opts: opts}
+	registry.mustRegister("namespace", nsc, nsinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		nsc.collectNamespace(ch, *obj.(*v1.Namespace))
+	}, opts)
 }
 
 type namespaceStore interface {
@@ -97,6 +100,7 @@ type namespaceStore interface {
 // namespaceCollector collects metrics about all namespace in the cluster.
 type namespaceCollector struct {
 	store namespaceStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -127,6 +131,9 @@ func (nsc *namespaceCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (nsc *namespaceCollector) collectNamespace(ch chan<- prometheus.Metric, ns v1.Namespace) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !nsc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{ns.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -138,11 +145,13 @@ func (nsc *namespaceCollector) collectNamespace(ch chan<- prometheus.Metric, ns
 		addGauge(descNamespaceCreated, float64(ns.CreationTimestamp.Unix()))
 	}
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(ns.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(nsc.opts.allowedLabels("namespaces", ns.Labels))
 	addGauge(namespaceLabelsDesc(labelKeys), 1, labelValues...)
 
-	annnotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(ns.Annotations)
-	addGauge(namespaceAnnotationsDesc(annnotationKeys), 1, annotationValues...)
+	if annotations, ok := nsc.opts.allowedAnnotations("namespaces", ns.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(namespaceAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 }
 
 func namespaceLabelsDesc(labelKeys []string) *prometheus.Desc {
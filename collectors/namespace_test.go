@@ -48,10 +48,12 @@ func TestNamespaceCollector(t *testing.T) {
 
 	cases := []struct {
 		ns      []v1.Namespace
+		opts    *Options
 		metrics []string // which metrics should be checked
 		want    string
 	}{
 		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"namespaces": {"*"}}},
 			ns: []v1.Namespace{
 				{
 					ObjectMeta: metav1.ObjectMeta{
@@ -133,12 +135,47 @@ func TestNamespaceCollector(t *testing.T) {
 				kube_namespace_status_phase{namespace="nsTerminateTest",phase="Terminating"} 1
 			`,
 		},
+		{
+			ns: []v1.Namespace{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "ns1",
+						Annotations: map[string]string{
+							"app": "example1",
+						},
+					},
+				},
+			},
+			want: metadata + `
+				kube_namespace_labels{namespace="ns1"} 1
+			`,
+			metrics: []string{"kube_namespace_labels", "kube_namespace_annotations"},
+		},
+		{
+			opts: &Options{AnnotationsAllowList: map[string][]string{"namespaces": {"owner"}}},
+			ns: []v1.Namespace{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "ns1",
+						Annotations: map[string]string{
+							"app":   "example1",
+							"owner": "team-a",
+						},
+					},
+				},
+			},
+			want: metadata + `
+				kube_namespace_annotations{annotation_owner="team-a",namespace="ns1"} 1
+			`,
+			metrics: []string{"kube_namespace_annotations"},
+		},
 	}
 	for _, c := range cases {
 		nsc := &namespaceCollector{
 			store: mockNamespaceStore{
 				list: func() ([]v1.Namespace, error) { return c.ns, nil },
 			},
+			opts: c.opts,
 		}
 		if err := gatherAndCompare(nsc, c.want, c.metrics); err != nil {
 			t.Errorf("unexpected collecting result:\n%s", err)
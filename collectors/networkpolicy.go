@@ -0,0 +1,219 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descNetworkPolicyLabelsName          = "kube_networkpolicy_labels"
+	descNetworkPolicyLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descNetworkPolicyLabelsDefaultLabels = []string{"namespace", "networkpolicy"}
+
+	descNetworkPolicyAnnotationsName          = "kube_networkpolicy_annotations"
+	descNetworkPolicyAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descNetworkPolicyAnnotationsDefaultLabels = []string{"namespace", "networkpolicy"}
+
+	descNetworkPolicyInfo = prometheus.NewDesc(
+		"kube_networkpolicy_info",
+		"Information about networkpolicy. An empty pod selector selects all pods of the namespace.",
+		[]string{"namespace", "networkpolicy", "pod_selector"}, nil,
+	)
+
+	descNetworkPolicyCreated = prometheus.NewDesc(
+		"kube_networkpolicy_created",
+		"Unix creation timestamp",
+		[]string{"namespace", "networkpolicy"}, nil,
+	)
+
+	descNetworkPolicySpecIngressRules = prometheus.NewDesc(
+		"kube_networkpolicy_spec_ingress_rules",
+		"Number of ingress rules of the networkpolicy.",
+		[]string{"namespace", "networkpolicy"}, nil,
+	)
+
+	descNetworkPolicySpecEgressRules = prometheus.NewDesc(
+		"kube_networkpolicy_spec_egress_rules",
+		"Number of egress rules of the networkpolicy.",
+		[]string{"namespace", "networkpolicy"}, nil,
+	)
+
+	descNetworkPolicySpecPolicyTypes = prometheus.NewDesc(
+		"kube_networkpolicy_spec_policy_types",
+		"Policy types the networkpolicy applies to.",
+		[]string{"namespace", "networkpolicy", "type"}, nil,
+	)
+
+	descNetworkPolicyLabels = prometheus.NewDesc(
+		descNetworkPolicyLabelsName,
+		descNetworkPolicyLabelsHelp,
+		descNetworkPolicyLabelsDefaultLabels, nil,
+	)
+
+	descNetworkPolicyAnnotations = prometheus.NewDesc(
+		descNetworkPolicyAnnotationsName,
+		descNetworkPolicyAnnotationsHelp,
+		descNetworkPolicyAnnotationsDefaultLabels, nil,
+	)
+)
+
+type NetworkPolicyLister func() ([]networkingv1.NetworkPolicy, error)
+
+func (l NetworkPolicyLister) List() ([]networkingv1.NetworkPolicy, error) {
+	return l()
+}
+
+func RegisterNetworkPolicyCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	npinfs, err := newVersionedInformerList(kubeClient, "networkpolicies", namespaces, &networkingv1.NetworkPolicy{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch networkpolicies: %v", err)
+		return
+	}
+
+	networkPolicyLister := NetworkPolicyLister(func() (nps []networkingv1.NetworkPolicy, err error) {
+		for _, npinf := range *npinfs {
+			for _, m := range npinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				nps = append(nps, *m.(*networkingv1.NetworkPolicy))
+			}
+		}
+		return nps, nil
+	})
+
+	npc := &networkPolicyCollector{store: networkPolicyLister, opts: opts}
+	registry.mustRegister("networkpolicy", npc, npinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		npc.collectNetworkPolicy(ch, *obj.(*networkingv1.NetworkPolicy))
+	}, opts)
+}
+
+type networkPolicyStore interface {
+	List() (networkPolicies []networkingv1.NetworkPolicy, err error)
+}
+
+// networkPolicyCollector collects metrics about all network policies in the
+// cluster.
+type networkPolicyCollector struct {
+	store networkPolicyStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (npc *networkPolicyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descNetworkPolicyInfo
+	ch <- descNetworkPolicyCreated
+	ch <- descNetworkPolicySpecIngressRules
+	ch <- descNetworkPolicySpecEgressRules
+	ch <- descNetworkPolicySpecPolicyTypes
+	ch <- descNetworkPolicyLabels
+	ch <- descNetworkPolicyAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (npc *networkPolicyCollector) Collect(ch chan<- prometheus.Metric) {
+	nps, err := npc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "networkpolicy"}).Inc()
+		glog.Errorf("listing network policies failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "networkpolicy"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "networkpolicy"}).Observe(float64(len(nps)))
+	for _, np := range nps {
+		npc.collectNetworkPolicy(ch, np)
+	}
+
+	glog.V(4).Infof("collected %d networkpolicies", len(nps))
+}
+
+func networkPolicyLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descNetworkPolicyLabelsName,
+		descNetworkPolicyLabelsHelp,
+		append(descNetworkPolicyLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func networkPolicyAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descNetworkPolicyAnnotationsName,
+		descNetworkPolicyAnnotationsHelp,
+		append(descNetworkPolicyAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (npc *networkPolicyCollector) collectNetworkPolicy(ch chan<- prometheus.Metric, np networkingv1.NetworkPolicy) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !npc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{np.Namespace, np.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	addGauge(descNetworkPolicyInfo, 1, podSelectorString(np.Spec.PodSelector))
+	if !np.CreationTimestamp.IsZero() {
+		addGauge(descNetworkPolicyCreated, float64(np.CreationTimestamp.Unix()))
+	}
+	addGauge(descNetworkPolicySpecIngressRules, float64(len(np.Spec.Ingress)))
+	addGauge(descNetworkPolicySpecEgressRules, float64(len(np.Spec.Egress)))
+	for _, t := range networkPolicyTypes(np.Spec) {
+		addGauge(descNetworkPolicySpecPolicyTypes, 1, string(t))
+	}
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(npc.opts.allowedLabels("networkpolicies", np.Labels))
+	addGauge(networkPolicyLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := npc.opts.allowedAnnotations("networkpolicies", np.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(networkPolicyAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+// podSelectorString flattens a pod selector into the selector syntax of
+// kubectl, like "app=web,tier in (backend)". The empty selector, selecting
+// all pods, is the empty string.
+func podSelectorString(selector metav1.LabelSelector) string {
+	s, err := metav1.LabelSelectorAsSelector(&selector)
+	if err != nil {
+		return "<error>"
+	}
+	return s.String()
+}
+
+// networkPolicyTypes returns the policy types of spec. Without explicit
+// types, a policy applies to ingress, and to egress if it has egress rules,
+// like the API server defaults them.
+func networkPolicyTypes(spec networkingv1.NetworkPolicySpec) []networkingv1.PolicyType {
+	if len(spec.PolicyTypes) > 0 {
+		return spec.PolicyTypes
+	}
+	types := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if len(spec.Egress) > 0 {
+		types = append(types, networkingv1.PolicyTypeEgress)
+	}
+	return types
+}
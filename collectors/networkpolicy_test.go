@@ -0,0 +1,120 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockNetworkPolicyStore struct {
+	list func() ([]networkingv1.NetworkPolicy, error)
+}
+
+func (ns mockNetworkPolicyStore) List() ([]networkingv1.NetworkPolicy, error) {
+	return ns.list()
+}
+
+func TestNetworkPolicyCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_networkpolicy_info Information about networkpolicy. An empty pod selector selects all pods of the namespace.
+		# TYPE kube_networkpolicy_info gauge
+		# HELP kube_networkpolicy_created Unix creation timestamp
+		# TYPE kube_networkpolicy_created gauge
+		# HELP kube_networkpolicy_spec_ingress_rules Number of ingress rules of the networkpolicy.
+		# TYPE kube_networkpolicy_spec_ingress_rules gauge
+		# HELP kube_networkpolicy_spec_egress_rules Number of egress rules of the networkpolicy.
+		# TYPE kube_networkpolicy_spec_egress_rules gauge
+		# HELP kube_networkpolicy_spec_policy_types Policy types the networkpolicy applies to.
+		# TYPE kube_networkpolicy_spec_policy_types gauge
+		# HELP kube_networkpolicy_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_networkpolicy_labels gauge
+		# HELP kube_networkpolicy_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_networkpolicy_annotations gauge
+	`
+	cases := []struct {
+		policies []networkingv1.NetworkPolicy
+		metrics  []string // which metrics should be checked
+		want     string
+	}{
+		{
+			policies: []networkingv1.NetworkPolicy{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "default-deny",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Namespace:         "ns1",
+						Labels: map[string]string{
+							"team": "security",
+						},
+					},
+					Spec: networkingv1.NetworkPolicySpec{
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "allow-web",
+						Namespace: "ns1",
+					},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: metav1.LabelSelector{
+							MatchLabels: map[string]string{"app": "web"},
+							MatchExpressions: []metav1.LabelSelectorRequirement{
+								{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"backend", "frontend"}},
+							},
+						},
+						Ingress: []networkingv1.NetworkPolicyIngressRule{{}, {}},
+						Egress:  []networkingv1.NetworkPolicyEgressRule{{}},
+					},
+				},
+			},
+			want: metadata + `
+				kube_networkpolicy_created{namespace="ns1",networkpolicy="default-deny"} 1.5e+09
+				kube_networkpolicy_info{namespace="ns1",networkpolicy="allow-web",pod_selector="app=web,tier in (backend,frontend)"} 1
+				kube_networkpolicy_info{namespace="ns1",networkpolicy="default-deny",pod_selector=""} 1
+				kube_networkpolicy_labels{label_team="security",namespace="ns1",networkpolicy="default-deny"} 1
+				kube_networkpolicy_labels{namespace="ns1",networkpolicy="allow-web"} 1
+				kube_networkpolicy_spec_egress_rules{namespace="ns1",networkpolicy="allow-web"} 1
+				kube_networkpolicy_spec_egress_rules{namespace="ns1",networkpolicy="default-deny"} 0
+				kube_networkpolicy_spec_ingress_rules{namespace="ns1",networkpolicy="allow-web"} 2
+				kube_networkpolicy_spec_ingress_rules{namespace="ns1",networkpolicy="default-deny"} 0
+				kube_networkpolicy_spec_policy_types{namespace="ns1",networkpolicy="allow-web",type="Egress"} 1
+				kube_networkpolicy_spec_policy_types{namespace="ns1",networkpolicy="allow-web",type="Ingress"} 1
+				kube_networkpolicy_spec_policy_types{namespace="ns1",networkpolicy="default-deny",type="Egress"} 1
+				kube_networkpolicy_spec_policy_types{namespace="ns1",networkpolicy="default-deny",type="Ingress"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		npc := &networkPolicyCollector{
+			store: &mockNetworkPolicyStore{
+				list: func() ([]networkingv1.NetworkPolicy, error) {
+					return c.policies, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(npc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
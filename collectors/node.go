@@ -19,12 +19,10 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -32,6 +30,10 @@ var (
 	descNodeLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descNodeLabelsDefaultLabels = []string{"node"}
 
+	descNodeAnnotationsName          = "kube_node_annotations"
+	descNodeAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descNodeAnnotationsDefaultLabels = []string{"node"}
+
 	descNodeInfo = prometheus.NewDesc(
 		"kube_node_info",
 		"Information about a cluster node.",
@@ -58,6 +60,12 @@ var (
 		descNodeLabelsDefaultLabels, nil,
 	)
 
+	descNodeAnnotations = prometheus.NewDesc(
+		descNodeAnnotationsName,
+		descNodeAnnotationsHelp,
+		descNodeAnnotationsDefaultLabels, nil,
+	)
+
 	descNodeSpecUnschedulable = prometheus.NewDesc(
 		"kube_node_spec_unschedulable",
 		"Whether a node can schedule new pods.",
@@ -125,21 +133,55 @@ func (l NodeLister) List() (v1.NodeList, error) {
 	return l()
 }
 
-func RegisterNodeCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterNodeCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect node with %s", client.APIVersion())
-	nlw := cache.NewListWatchFromClient(client, "nodes", metav1.NamespaceAll, fields.Everything())
-	ninf := cache.NewSharedInformer(nlw, &v1.Node{}, resyncPeriod)
+	ninfs := NewSharedInformerList(client, "nodes", []string{metav1.NamespaceAll}, &v1.Node{}, opts)
+	ninfs.SetTransform(func(obj runtime.Object) {
+		stripNode(obj.(*v1.Node), opts)
+	})
 
 	nodeLister := NodeLister(func() (machines v1.NodeList, err error) {
-		for _, m := range ninf.GetStore().List() {
-			machines.Items = append(machines.Items, *(m.(*v1.Node)))
+		for _, ninf := range *ninfs {
+			for _, m := range ninf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				machines.Items = append(machines.Items, *(m.(*v1.Node)))
+			}
 		}
 		return machines, nil
 	})
 
-	registry.MustRegister(&nodeCollector{store: nodeLister})
-	go ninf.Run(context.Background().Done())
+	nc := &nodeCollector{store: nodeLister, opts: opts}
+	registry.mustRegister("node", nc, ninfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		nc.collectNode(ch, *obj.(*v1.Node))
+	}, opts)
+}
+
+// stripNode drops the fields of the node the collector does not read, like
+// the images and volumes on the node, so that they are never cached.
+func stripNode(n *v1.Node, opts *Options) {
+	opts.stripMetadata("nodes", &n.ObjectMeta)
+
+	n.Spec = v1.NodeSpec{ProviderID: n.Spec.ProviderID, Unschedulable: n.Spec.Unschedulable}
+	conditions := make([]v1.NodeCondition, len(n.Status.Conditions))
+	for i, c := range n.Status.Conditions {
+		conditions[i] = v1.NodeCondition{Type: c.Type, Status: c.Status}
+	}
+	n.Status = v1.NodeStatus{
+		Capacity:    n.Status.Capacity,
+		Allocatable: n.Status.Allocatable,
+		Phase:       n.Status.Phase,
+		Conditions:  conditions,
+		NodeInfo: v1.NodeSystemInfo{
+			KernelVersion:           n.Status.NodeInfo.KernelVersion,
+			OSImage:                 n.Status.NodeInfo.OSImage,
+			ContainerRuntimeVersion: n.Status.NodeInfo.ContainerRuntimeVersion,
+			KubeletVersion:          n.Status.NodeInfo.KubeletVersion,
+			KubeProxyVersion:        n.Status.NodeInfo.KubeProxyVersion,
+		},
+	}
 }
 
 type nodeStore interface {
@@ -149,6 +191,7 @@ type nodeStore interface {
 // nodeCollector collects metrics about all nodes in the cluster.
 type nodeCollector struct {
 	store nodeStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -156,6 +199,7 @@ func (nc *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descNodeInfo
 	ch <- descNodeCreated
 	ch <- descNodeLabels
+	ch <- descNodeAnnotations
 	ch <- descNodeSpecUnschedulable
 	ch <- descNodeStatusCondition
 	ch <- descNodeStatusPhase
@@ -196,8 +240,20 @@ func nodeLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func nodeAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descNodeAnnotationsName,
+		descNodeAnnotationsHelp,
+		append(descNodeAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !nc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{n.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -214,9 +270,14 @@ func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 	if !n.CreationTimestamp.IsZero() {
 		addGauge(descNodeCreated, float64(n.CreationTimestamp.Unix()))
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(n.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(nc.opts.allowedLabels("nodes", n.Labels))
 	addGauge(nodeLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := nc.opts.allowedAnnotations("nodes", n.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(nodeAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	addGauge(descNodeSpecUnschedulable, boolFloat64(n.Spec.Unschedulable))
 
 	// Collect node conditions and while default to false.
@@ -225,7 +286,7 @@ func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 		// Third party plugin may report customized condition for cluster node
 		// (e.g. node-problem-detector), and Kubernetes may add new core
 		// conditions in future.
-		addConditionMetrics(ch, descNodeStatusCondition, c.Status, n.Name, string(c.Type))
+		addConditionMetrics(ch, nc.opts, descNodeStatusCondition, c.Status, n.Name, string(c.Type))
 	}
 
 	// Set current phase to 1, others to 0 if it is set.
@@ -253,9 +314,12 @@ func (nc *nodeCollector) collectNode(ch chan<- prometheus.Metric, n v1.Node) {
 }
 
 // addConditionMetrics generates one metric for each possible node condition
-// status. For this function to work properly, the last label in the metric
-// description must be the condition.
-func addConditionMetrics(ch chan<- prometheus.Metric, desc *prometheus.Desc, cs v1.ConditionStatus, lv ...string) {
+// status, unless opts exclude the metric family. For this function to work
+// properly, the last label in the metric description must be the condition.
+func addConditionMetrics(ch chan<- prometheus.Metric, opts *Options, desc *prometheus.Desc, cs v1.ConditionStatus, lv ...string) {
+	if !opts.allows(desc) {
+		return
+	}
 	ch <- prometheus.MustNewConstMetric(
 		desc, prometheus.GaugeValue, boolFloat64(cs == v1.ConditionTrue),
 		append(lv, "true")...,
@@ -17,6 +17,8 @@ limitations under the License.
 package collectors
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,6 +67,8 @@ func TestNodeCollector(t *testing.T) {
 		# HELP kube_node_status_allocatable_memory_bytes The memory resources of a node that are available for scheduling.
 		# HELP kube_node_status_condition The condition of a cluster node.
 		# TYPE kube_node_status_condition gauge
+		# HELP kube_node_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_node_annotations gauge
 	`
 	cases := []struct {
 		nodes   []v1.Node
@@ -277,3 +281,66 @@ func TestNodeCollector(t *testing.T) {
 		}
 	}
 }
+
+func TestStripNodeKeepsMetrics(t *testing.T) {
+	node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "node1",
+			UID:               "uid1",
+			ResourceVersion:   "42",
+			CreationTimestamp: metav1.Unix(1500000000, 0),
+			Labels:            map[string]string{"zone": "a", "kubernetes.io/hostname": "node1"},
+			Annotations:       map[string]string{"node.alpha.kubernetes.io/ttl": "0"},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID:    "provider://i-uniqueid",
+			PodCIDR:       "10.0.0.0/24",
+			Unschedulable: true,
+			Taints:        []v1.Taint{{Key: "dedicated", Effect: v1.TaintEffectNoSchedule}},
+		},
+		Status: v1.NodeStatus{
+			Phase:       v1.NodeRunning,
+			Capacity:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourcePods: resource.MustParse("110")},
+			Allocatable: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1G")},
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeReady, Status: v1.ConditionTrue, Message: "kubelet is posting ready status"},
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse, Reason: "KubeletHasSufficientMemory"},
+			},
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			NodeInfo: v1.NodeSystemInfo{
+				KernelVersion:           "kernel",
+				OSImage:                 "osimage",
+				ContainerRuntimeVersion: "rkt",
+				KubeletVersion:          "kubelet",
+				KubeProxyVersion:        "kubeproxy",
+				MachineID:               "machine1",
+			},
+			Images: []v1.ContainerImage{{Names: []string{"k8s.gcr.io/hyperkube1"}, SizeBytes: 1 << 30}},
+		},
+	}
+	opts := &Options{LabelsAllowList: map[string][]string{"nodes": {"zone"}}}
+	stripped := *node.DeepCopy()
+	stripNode(&stripped, opts)
+
+	want, err := collectText(&nodeCollector{store: mockNodeStore{list: func() (v1.NodeList, error) { return v1.NodeList{Items: []v1.Node{node}}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectText(&nodeCollector{store: mockNodeStore{list: func() (v1.NodeList, error) { return v1.NodeList{Items: []v1.Node{stripped}}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || !strings.Contains(want, "kube_node_info{") {
+		t.Errorf("expected the metrics of the stripped node to equal\n%s\ngot\n%s", want, got)
+	}
+
+	if stripped.Status.Images != nil || stripped.Status.Addresses != nil || stripped.Spec.Taints != nil {
+		t.Errorf("expected images, addresses and taints to be stripped, got %+v", stripped)
+	}
+	if want := map[string]string{"zone": "a"}; !reflect.DeepEqual(stripped.Labels, want) {
+		t.Errorf("expected labels %v, got %v", want, stripped.Labels)
+	}
+	if stripped.Annotations != nil {
+		t.Errorf("expected annotations to be stripped, got %v", stripped.Annotations)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// openMetricsUnits are the units announced with a UNIT line for the metric
+// families whose names end in them.
+var openMetricsUnits = []string{"bytes", "cores"}
+
+var openMetricsHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// openMetricsAccepted reports whether the Accept header of a request
+// prefers the OpenMetrics text format over the text exposition format.
+func openMetricsAccepted(header http.Header) bool {
+	var openMetrics, text float64
+	for _, part := range strings.Split(header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/openmetrics-text":
+			if q > openMetrics {
+				openMetrics = q
+			}
+		case "text/plain", "text/*", "*/*":
+			if q > text {
+				text = q
+			}
+		}
+	}
+	return openMetrics > 0 && openMetrics >= text
+}
+
+// writeFamilyOpenMetrics writes the metric family with the given name to w
+// in the OpenMetrics text format. Counters are written under their name
+// without the _total suffix, with a _created sample holding the creation
+// timestamp of the object for each series. Counters without the suffix keep
+// their sample names and are written as unknown.
+func (s *MetricsStore) writeFamilyOpenMetrics(w io.Writer, name string) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	series := s.sortedSeries(name)
+	if len(series) == 0 {
+		return nil
+	}
+
+	md := s.metadata[name]
+	family, typ := name, "unknown"
+	switch md.typ {
+	case dto.MetricType_GAUGE:
+		typ = "gauge"
+	case dto.MetricType_COUNTER:
+		if strings.HasSuffix(name, "_total") {
+			family, typ = strings.TrimSuffix(name, "_total"), "counter"
+		}
+	case dto.MetricType_SUMMARY:
+		typ = "summary"
+	case dto.MetricType_HISTOGRAM:
+		typ = "histogram"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# HELP %s %s\n", family, openMetricsHelpEscaper.Replace(md.help))
+	fmt.Fprintf(&buf, "# TYPE %s %s\n", family, typ)
+	for _, unit := range openMetricsUnits {
+		if strings.HasSuffix(family, "_"+unit) {
+			fmt.Fprintf(&buf, "# UNIT %s %s\n", family, unit)
+			break
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, se := range series {
+		if _, err := w.Write(se.text); err != nil {
+			return err
+		}
+		if typ != "counter" || se.created == 0 {
+			continue
+		}
+		// The sample is written as name, optional labels, a space and the
+		// value. The _created sample takes the same labels.
+		labels := se.text[len(name):bytes.LastIndexByte(se.text, ' ')]
+		if _, err := fmt.Fprintf(w, "%s_created%s %d\n", family, labels, se.created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,12 +28,22 @@ var (
 	descPersistentVolumeLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descPersistentVolumeLabelsDefaultLabels = []string{"persistentvolume"}
 
+	descPersistentVolumeAnnotationsName          = "kube_persistentvolume_annotations"
+	descPersistentVolumeAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descPersistentVolumeAnnotationsDefaultLabels = []string{"persistentvolume"}
+
 	descPersistentVolumeLabels = prometheus.NewDesc(
 		descPersistentVolumeLabelsName,
 		descPersistentVolumeLabelsHelp,
 		descPersistentVolumeLabelsDefaultLabels, nil,
 	)
 
+	descPersistentVolumeAnnotations = prometheus.NewDesc(
+		descPersistentVolumeAnnotationsName,
+		descPersistentVolumeAnnotationsHelp,
+		descPersistentVolumeAnnotationsDefaultLabels, nil,
+	)
+
 	descPersistentVolumeStatusPhase = prometheus.NewDesc(
 		"kube_persistentvolume_status_phase",
 		"The phase indicates if a volume is available, bound to a claim, or released by a claim.",
@@ -59,21 +66,27 @@ func (pvl PersistentVolumeLister) List() (v1.PersistentVolumeList, error) {
 	return pvl()
 }
 
-func RegisterPersistentVolumeCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterPersistentVolumeCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect persistentvolume with %s", client.APIVersion())
-	pvlw := cache.NewListWatchFromClient(client, "persistentvolumes", v1.NamespaceAll, fields.Everything())
-	pvinf := cache.NewSharedInformer(pvlw, &v1.PersistentVolume{}, resyncPeriod)
+	pvinfs := NewSharedInformerList(client, "persistentvolumes", []string{v1.NamespaceAll}, &v1.PersistentVolume{}, opts)
 
 	persistentVolumeLister := PersistentVolumeLister(func() (pvs v1.PersistentVolumeList, err error) {
-		for _, pv := range pvinf.GetStore().List() {
-			pvs.Items = append(pvs.Items, *(pv.(*v1.PersistentVolume)))
+		for _, pvinf := range *pvinfs {
+			for _, pv := range pvinf.GetStore().List() {
+				if !opts.owns(pv) {
+					continue
+				}
+				pvs.Items = append(pvs.Items, *(pv.(*v1.PersistentVolume)))
+			}
 		}
 		return pvs, nil
 	})
 
-	registry.MustRegister(&persistentVolumeCollector{store: persistentVolumeLister})
-	go pvinf.Run(context.Background().Done())
+	collector := &persistentVolumeCollector{store: persistentVolumeLister, opts: opts}
+	registry.mustRegister("persistentvolume", collector, pvinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		collector.collectPersistentVolume(ch, *obj.(*v1.PersistentVolume))
+	}, opts)
 }
 
 type persistentVolumeStore interface {
@@ -83,6 +96,7 @@ type persistentVolumeStore interface {
 // persistentVolumeCollector collects metrics about all persistentVolumes in the cluster.
 type persistentVolumeCollector struct {
 	store persistentVolumeStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -90,6 +104,7 @@ func (collector *persistentVolumeCollector) Describe(ch chan<- *prometheus.Desc)
 	ch <- descPersistentVolumeStatusPhase
 	ch <- descPersistentVolumeInfo
 	ch <- descPersistentVolumeLabels
+	ch <- descPersistentVolumeAnnotations
 }
 
 func persistentVolumeLabelsDesc(labelKeys []string) *prometheus.Desc {
@@ -101,6 +116,15 @@ func persistentVolumeLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func persistentVolumeAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descPersistentVolumeAnnotationsName,
+		descPersistentVolumeAnnotationsHelp,
+		append(descPersistentVolumeAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 // Collect implements the prometheus.Collector interface.
 func (collector *persistentVolumeCollector) Collect(ch chan<- prometheus.Metric) {
 	persistentVolumeCollector, err := collector.store.List()
@@ -121,13 +145,21 @@ func (collector *persistentVolumeCollector) Collect(ch chan<- prometheus.Metric)
 
 func (collector *persistentVolumeCollector) collectPersistentVolume(ch chan<- prometheus.Metric, pv v1.PersistentVolume) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{pv.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(pv.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(collector.opts.allowedLabels("persistentvolumes", pv.Labels))
 	addGauge(persistentVolumeLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := collector.opts.allowedAnnotations("persistentvolumes", pv.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(persistentVolumeAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	addGauge(descPersistentVolumeInfo, 1, pv.Spec.StorageClassName)
 	// Set current phase to 1, others to 0 if it is set.
 	if p := pv.Status.Phase; p != "" {
@@ -41,6 +41,8 @@ func TestPersistentVolumeCollector(t *testing.T) {
 			# TYPE kube_persistentvolume_labels gauge
 			# HELP kube_persistentvolume_info Information about persistentvolume.
 			# TYPE kube_persistentvolume_info gauge
+			# HELP kube_persistentvolume_annotations Kubernetes annotations converted to Prometheus labels.
+			# TYPE kube_persistentvolume_annotations gauge
 	`
 	cases := []struct {
 		pvs     []v1.PersistentVolume
@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,12 +28,22 @@ var (
 	descPersistentVolumeClaimLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descPersistentVolumeClaimLabelsDefaultLabels = []string{"namespace", "persistentvolumeclaim"}
 
+	descPersistentVolumeClaimAnnotationsName          = "kube_persistentvolumeclaim_annotations"
+	descPersistentVolumeClaimAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descPersistentVolumeClaimAnnotationsDefaultLabels = []string{"namespace", "persistentvolumeclaim"}
+
 	descPersistentVolumeClaimLabels = prometheus.NewDesc(
 		descPersistentVolumeClaimLabelsName,
 		descPersistentVolumeClaimLabelsHelp,
 		descPersistentVolumeClaimLabelsDefaultLabels, nil,
 	)
 
+	descPersistentVolumeClaimAnnotations = prometheus.NewDesc(
+		descPersistentVolumeClaimAnnotationsName,
+		descPersistentVolumeClaimAnnotationsHelp,
+		descPersistentVolumeClaimAnnotationsDefaultLabels, nil,
+	)
+
 	descPersistentVolumeClaimInfo = prometheus.NewDesc(
 		"kube_persistentvolumeclaim_info",
 		"Information about persistent volume claim.",
@@ -72,21 +79,27 @@ func (l PersistentVolumeClaimLister) List() (v1.PersistentVolumeClaimList, error
 	return l()
 }
 
-func RegisterPersistentVolumeClaimCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterPersistentVolumeClaimCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect persistentvolumeclaim with %s", client.APIVersion())
-	pvclw := cache.NewListWatchFromClient(client, "persistentvolumeclaims", namespace, fields.Everything())
-	pvcinf := cache.NewSharedInformer(pvclw, &v1.PersistentVolumeClaim{}, resyncPeriod)
+	pvcinfs := NewSharedInformerList(client, "persistentvolumeclaims", namespaces, &v1.PersistentVolumeClaim{}, opts)
 
 	persistentVolumeClaimLister := PersistentVolumeClaimLister(func() (pvcs v1.PersistentVolumeClaimList, err error) {
-		for _, pvc := range pvcinf.GetStore().List() {
-			pvcs.Items = append(pvcs.Items, *(pvc.(*v1.PersistentVolumeClaim)))
+		for _, pvcinf := range *pvcinfs {
+			for _, pvc := range pvcinf.GetStore().List() {
+				if !opts.owns(pvc) {
+					continue
+				}
+				pvcs.Items = append(pvcs.Items, *(pvc.(*v1.PersistentVolumeClaim)))
+			}
 		}
 		return pvcs, nil
 	})
 
-	registry.MustRegister(&persistentVolumeClaimCollector{store: persistentVolumeClaimLister})
-	go pvcinf.Run(context.Background().Done())
+	collector := &persistentVolumeClaimCollector{store: persistentVolumeClaimLister, opts: opts}
+	registry.mustRegister("persistentvolumeclaim", collector, pvcinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		collector.collectPersistentVolumeClaim(ch, *obj.(*v1.PersistentVolumeClaim))
+	}, opts)
 }
 
 type persistentVolumeClaimStore interface {
@@ -96,11 +109,13 @@ type persistentVolumeClaimStore interface {
 // persistentVolumeClaimCollector collects metrics about all persistentVolumeClaims in the cluster.
 type persistentVolumeClaimCollector struct {
 	store persistentVolumeClaimStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
 func (collector *persistentVolumeClaimCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descPersistentVolumeClaimLabels
+	ch <- descPersistentVolumeClaimAnnotations
 	ch <- descPersistentVolumeClaimInfo
 	ch <- descPersistentVolumeClaimStatusPhase
 	ch <- descPersistentVolumeClaimResourceRequestsStorage
@@ -115,6 +130,15 @@ func persistentVolumeClaimLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func persistentVolumeClaimAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descPersistentVolumeClaimAnnotationsName,
+		descPersistentVolumeClaimAnnotationsHelp,
+		append(descPersistentVolumeClaimAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 // Collect implements the prometheus.Collector interface.
 func (collector *persistentVolumeClaimCollector) Collect(ch chan<- prometheus.Metric) {
 	persistentVolumeClaimCollector, err := collector.store.List()
@@ -151,13 +175,21 @@ func getPersistentVolumeClaimClass(claim *v1.PersistentVolumeClaim) string {
 
 func (collector *persistentVolumeClaimCollector) collectPersistentVolumeClaim(ch chan<- prometheus.Metric, pvc v1.PersistentVolumeClaim) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{pvc.Namespace, pvc.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(pvc.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(collector.opts.allowedLabels("persistentvolumeclaims", pvc.Labels))
 	addGauge(persistentVolumeClaimLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := collector.opts.allowedAnnotations("persistentvolumeclaims", pvc.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(persistentVolumeClaimAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	storageClassName := getPersistentVolumeClaimClass(&pvc)
 	volumeName := pvc.Spec.VolumeName
 	addGauge(descPersistentVolumeClaimInfo, 1, storageClassName, volumeName)
@@ -44,6 +44,8 @@ func TestPersistentVolumeClaimCollector(t *testing.T) {
 		# TYPE kube_persistentvolumeclaim_status_phase gauge
 		# HELP kube_persistentvolumeclaim_resource_requests_storage_bytes The capacity of storage requested by the persistent volume claim.
 		# TYPE kube_persistentvolumeclaim_resource_requests_storage_bytes gauge
+		# HELP kube_persistentvolumeclaim_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_persistentvolumeclaim_annotations gauge
 	`
 	storageClassName := "rbd"
 	cases := []struct {
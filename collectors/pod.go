@@ -22,12 +22,10 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -38,6 +36,10 @@ var (
 	containerWaitingReasons    = []string{"ContainerCreating", "CrashLoopBackOff", "ErrImagePull", "ImagePullBackOff"}
 	containerTerminatedReasons = []string{"OOMKilled", "Completed", "Error", "ContainerCannotRun"}
 
+	descPodAnnotationsName          = "kube_pod_annotations"
+	descPodAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descPodAnnotationsDefaultLabels = []string{"namespace", "pod"}
+
 	descPodInfo = prometheus.NewDesc(
 		"kube_pod_info",
 		"Information about pod.",
@@ -62,6 +64,12 @@ var (
 		descPodLabelsDefaultLabels, nil,
 	)
 
+	descPodAnnotations = prometheus.NewDesc(
+		descPodAnnotationsName,
+		descPodAnnotationsHelp,
+		descPodAnnotationsDefaultLabels, nil,
+	)
+
 	descPodCreated = prometheus.NewDesc(
 		"kube_pod_created",
 		"Unix creation timestamp",
@@ -189,21 +197,67 @@ func (l PodLister) List() ([]v1.Pod, error) {
 	return l()
 }
 
-func RegisterPodCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterPodCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect pod with %s", client.APIVersion())
-	plw := cache.NewListWatchFromClient(client, "pods", namespace, fields.Everything())
-	pinf := cache.NewSharedInformer(plw, &v1.Pod{}, resyncPeriod)
+	pinfs := NewSharedInformerList(client, "pods", namespaces, &v1.Pod{}, opts)
+	pinfs.SetTransform(func(obj runtime.Object) {
+		stripPod(obj.(*v1.Pod), opts)
+	})
 
 	podLister := PodLister(func() (pods []v1.Pod, err error) {
-		for _, m := range pinf.GetStore().List() {
-			pods = append(pods, *m.(*v1.Pod))
+		for _, pinf := range *pinfs {
+			for _, m := range pinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				pods = append(pods, *m.(*v1.Pod))
+			}
 		}
 		return pods, nil
 	})
 
-	registry.MustRegister(&podCollector{store: podLister})
-	go pinf.Run(context.Background().Done())
+	pc := &podCollector{store: podLister, opts: opts}
+	registry.mustRegister("pod", pc, pinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		pc.collectPod(ch, *obj.(*v1.Pod))
+	}, opts)
+}
+
+// stripPod drops the fields of the pod the collector does not read, like
+// the container images, commands and environment, so that they are never
+// cached.
+func stripPod(p *v1.Pod, opts *Options) {
+	opts.stripMetadata("pods", &p.ObjectMeta)
+
+	containers := make([]v1.Container, len(p.Spec.Containers))
+	for i, c := range p.Spec.Containers {
+		containers[i] = v1.Container{Name: c.Name, Resources: c.Resources}
+	}
+	var volumes []v1.Volume
+	for _, v := range p.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			volumes = append(volumes, v1.Volume{Name: v.Name, VolumeSource: v1.VolumeSource{PersistentVolumeClaim: v.PersistentVolumeClaim}})
+		}
+	}
+	p.Spec = v1.PodSpec{NodeName: p.Spec.NodeName, Containers: containers, Volumes: volumes}
+
+	conditions := make([]v1.PodCondition, len(p.Status.Conditions))
+	for i, c := range p.Status.Conditions {
+		conditions[i] = v1.PodCondition{Type: c.Type, Status: c.Status}
+	}
+	statuses := make([]v1.ContainerStatus, len(p.Status.ContainerStatuses))
+	for i, cs := range p.Status.ContainerStatuses {
+		cs.LastTerminationState = v1.ContainerState{}
+		statuses[i] = cs
+	}
+	p.Status = v1.PodStatus{
+		Phase:             p.Status.Phase,
+		Conditions:        conditions,
+		HostIP:            p.Status.HostIP,
+		PodIP:             p.Status.PodIP,
+		StartTime:         p.Status.StartTime,
+		ContainerStatuses: statuses,
+	}
 }
 
 type podStore interface {
@@ -213,6 +267,7 @@ type podStore interface {
 // podCollector collects metrics about all pods in the cluster.
 type podCollector struct {
 	store podStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -221,6 +276,7 @@ func (pc *podCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descPodStartTime
 	ch <- descPodOwner
 	ch <- descPodLabels
+	ch <- descPodAnnotations
 	ch <- descPodCreated
 	ch <- descPodStatusPhase
 	ch <- descPodStatusReady
@@ -298,9 +354,21 @@ func podLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func podAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descPodAnnotationsName,
+		descPodAnnotationsHelp,
+		append(descPodAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 	nodeName := p.Spec.NodeName
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !pc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{p.Namespace, p.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
 	}
@@ -342,9 +410,14 @@ func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 		}
 	}
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(p.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(pc.opts.allowedLabels("pods", p.Labels))
 	addGauge(podLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := pc.opts.allowedAnnotations("pods", p.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(podAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	if p := p.Status.Phase; p != "" {
 		addGauge(descPodStatusPhase, boolFloat64(p == v1.PodPending), string(v1.PodPending))
 		addGauge(descPodStatusPhase, boolFloat64(p == v1.PodRunning), string(v1.PodRunning))
@@ -360,9 +433,9 @@ func (pc *podCollector) collectPod(ch chan<- prometheus.Metric, p v1.Pod) {
 	for _, c := range p.Status.Conditions {
 		switch c.Type {
 		case v1.PodReady:
-			addConditionMetrics(ch, descPodStatusReady, c.Status, p.Namespace, p.Name)
+			addConditionMetrics(ch, pc.opts, descPodStatusReady, c.Status, p.Namespace, p.Name)
 		case v1.PodScheduled:
-			addConditionMetrics(ch, descPodStatusScheduled, c.Status, p.Namespace, p.Name)
+			addConditionMetrics(ch, pc.opts, descPodStatusScheduled, c.Status, p.Namespace, p.Name)
 		}
 	}
 
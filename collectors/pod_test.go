@@ -17,6 +17,8 @@ limitations under the License.
 package collectors
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -90,6 +92,8 @@ func TestPodCollector(t *testing.T) {
 		# TYPE kube_pod_spec_volumes_persistentvolumeclaims_info gauge
 		# HELP kube_pod_spec_volumes_persistentvolumeclaims_readonly Describes whether a persistentvolumeclaim is mounted read only.
 		# TYPE kube_pod_spec_volumes_persistentvolumeclaims_readonly gauge
+		# HELP kube_pod_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_pod_annotations gauge
 	`
 	cases := []struct {
 		pods    []v1.Pod
@@ -758,3 +762,87 @@ func TestPodCollector(t *testing.T) {
 		}
 	}
 }
+
+func TestStripPodKeepsMetrics(t *testing.T) {
+	startTime := metav1.Unix(1501569018, 0)
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pod1",
+			Namespace:         "ns1",
+			UID:               "uid1",
+			ResourceVersion:   "42",
+			CreationTimestamp: metav1.Unix(1500000000, 0),
+			Labels:            map[string]string{"app": "web", "pod-template-hash": "5d4f8"},
+			Annotations:       map[string]string{"owner": "team-a", "kubectl.kubernetes.io/last-applied-configuration": "{}"},
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs1"}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node1",
+			Containers: []v1.Container{{
+				Name:    "container1",
+				Image:   "k8s.gcr.io/hyperkube1",
+				Command: []string{"/hyperkube", "apiserver"},
+				Env:     []v1.EnvVar{{Name: "TOKEN", Value: "secret"}},
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("200m")},
+					Limits:   v1.ResourceList{v1.ResourceMemory: resource.MustParse("100M")},
+				},
+			}},
+			Volumes: []v1.Volume{
+				{Name: "data", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "claim1", ReadOnly: true}}},
+				{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "config1"}}}},
+			},
+		},
+		Status: v1.PodStatus{
+			Phase:     v1.PodRunning,
+			HostIP:    "1.1.1.1",
+			PodIP:     "1.2.3.4",
+			StartTime: &startTime,
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionTrue, Message: "ready"},
+				{Type: v1.PodScheduled, Status: v1.ConditionTrue, Reason: "Scheduled"},
+			},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:                 "container1",
+				Image:                "k8s.gcr.io/hyperkube1",
+				ImageID:              "docker://sha256:aaa",
+				ContainerID:          "docker://ab123",
+				Ready:                true,
+				RestartCount:         3,
+				State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", Message: "panic: oops"}},
+			}},
+		},
+	}
+	opts := &Options{
+		LabelsAllowList:      map[string][]string{"pods": {"app"}},
+		AnnotationsAllowList: map[string][]string{"pods": {"owner"}},
+	}
+	stripped := *pod.DeepCopy()
+	stripPod(&stripped, opts)
+
+	want, err := collectText(&podCollector{store: mockPodStore{f: func() ([]v1.Pod, error) { return []v1.Pod{pod}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectText(&podCollector{store: mockPodStore{f: func() ([]v1.Pod, error) { return []v1.Pod{stripped}, nil }}, opts: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want || !strings.Contains(want, "kube_pod_info{") {
+		t.Errorf("expected the metrics of the stripped pod to equal\n%s\ngot\n%s", want, got)
+	}
+
+	if c := stripped.Spec.Containers[0]; c.Image != "" || c.Command != nil || c.Env != nil {
+		t.Errorf("expected the container spec to be stripped, got %+v", c)
+	}
+	if len(stripped.Spec.Volumes) != 1 || stripped.Spec.Volumes[0].Name != "data" {
+		t.Errorf("expected only the claim volume to be kept, got %+v", stripped.Spec.Volumes)
+	}
+	if want := map[string]string{"app": "web"}; !reflect.DeepEqual(stripped.Labels, want) {
+		t.Errorf("expected labels %v, got %v", want, stripped.Labels)
+	}
+	if want := map[string]string{"owner": "team-a"}; !reflect.DeepEqual(stripped.Annotations, want) {
+		t.Errorf("expected annotations %v, got %v", want, stripped.Annotations)
+	}
+}
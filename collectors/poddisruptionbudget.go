@@ -0,0 +1,199 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/policy/v1beta1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descPodDisruptionBudgetLabelsName          = "kube_poddisruptionbudget_labels"
+	descPodDisruptionBudgetLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descPodDisruptionBudgetLabelsDefaultLabels = []string{"namespace", "poddisruptionbudget"}
+
+	descPodDisruptionBudgetAnnotationsName          = "kube_poddisruptionbudget_annotations"
+	descPodDisruptionBudgetAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descPodDisruptionBudgetAnnotationsDefaultLabels = []string{"namespace", "poddisruptionbudget"}
+
+	descPodDisruptionBudgetCreated = prometheus.NewDesc(
+		"kube_poddisruptionbudget_created",
+		"Unix creation timestamp",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetStatusCurrentHealthy = prometheus.NewDesc(
+		"kube_poddisruptionbudget_status_current_healthy",
+		"Current number of healthy pods",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetStatusDesiredHealthy = prometheus.NewDesc(
+		"kube_poddisruptionbudget_status_desired_healthy",
+		"Minimum desired number of healthy pods",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetStatusExpectedPods = prometheus.NewDesc(
+		"kube_poddisruptionbudget_status_expected_pods",
+		"Total number of pods counted by this disruption budget",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetStatusPodDisruptionsAllowed = prometheus.NewDesc(
+		"kube_poddisruptionbudget_status_pod_disruptions_allowed",
+		"Number of pod disruptions that are currently allowed",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetStatusObservedGeneration = prometheus.NewDesc(
+		"kube_poddisruptionbudget_status_observed_generation",
+		"Most recent generation observed when updating this PDB status",
+		[]string{"namespace", "poddisruptionbudget"}, nil,
+	)
+
+	descPodDisruptionBudgetLabels = prometheus.NewDesc(
+		descPodDisruptionBudgetLabelsName,
+		descPodDisruptionBudgetLabelsHelp,
+		descPodDisruptionBudgetLabelsDefaultLabels, nil,
+	)
+
+	descPodDisruptionBudgetAnnotations = prometheus.NewDesc(
+		descPodDisruptionBudgetAnnotationsName,
+		descPodDisruptionBudgetAnnotationsHelp,
+		descPodDisruptionBudgetAnnotationsDefaultLabels, nil,
+	)
+)
+
+type PodDisruptionBudgetLister func() ([]v1beta1.PodDisruptionBudget, error)
+
+func (l PodDisruptionBudgetLister) List() ([]v1beta1.PodDisruptionBudget, error) {
+	return l()
+}
+
+func RegisterPodDisruptionBudgetCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	pdbinfs, err := newVersionedInformerList(kubeClient, "poddisruptionbudgets", namespaces, &v1beta1.PodDisruptionBudget{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch poddisruptionbudgets: %v", err)
+		return
+	}
+
+	podDisruptionBudgetLister := PodDisruptionBudgetLister(func() (pdbs []v1beta1.PodDisruptionBudget, err error) {
+		for _, pdbinf := range *pdbinfs {
+			for _, m := range pdbinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				pdbs = append(pdbs, *m.(*v1beta1.PodDisruptionBudget))
+			}
+		}
+		return pdbs, nil
+	})
+
+	pdbc := &podDisruptionBudgetCollector{store: podDisruptionBudgetLister, opts: opts}
+	registry.mustRegister("poddisruptionbudget", pdbc, pdbinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		pdbc.collectPodDisruptionBudget(ch, *obj.(*v1beta1.PodDisruptionBudget))
+	}, opts)
+}
+
+type podDisruptionBudgetStore interface {
+	List() (podDisruptionBudgets []v1beta1.PodDisruptionBudget, err error)
+}
+
+// podDisruptionBudgetCollector collects metrics about all pod disruption
+// budgets in the cluster.
+type podDisruptionBudgetCollector struct {
+	store podDisruptionBudgetStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (pdbc *podDisruptionBudgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descPodDisruptionBudgetCreated
+	ch <- descPodDisruptionBudgetStatusCurrentHealthy
+	ch <- descPodDisruptionBudgetStatusDesiredHealthy
+	ch <- descPodDisruptionBudgetStatusExpectedPods
+	ch <- descPodDisruptionBudgetStatusPodDisruptionsAllowed
+	ch <- descPodDisruptionBudgetStatusObservedGeneration
+	ch <- descPodDisruptionBudgetLabels
+	ch <- descPodDisruptionBudgetAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (pdbc *podDisruptionBudgetCollector) Collect(ch chan<- prometheus.Metric) {
+	pdbs, err := pdbc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "poddisruptionbudget"}).Inc()
+		glog.Errorf("listing pod disruption budgets failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "poddisruptionbudget"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "poddisruptionbudget"}).Observe(float64(len(pdbs)))
+	for _, pdb := range pdbs {
+		pdbc.collectPodDisruptionBudget(ch, pdb)
+	}
+
+	glog.V(4).Infof("collected %d poddisruptionbudgets", len(pdbs))
+}
+
+func podDisruptionBudgetLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descPodDisruptionBudgetLabelsName,
+		descPodDisruptionBudgetLabelsHelp,
+		append(descPodDisruptionBudgetLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func podDisruptionBudgetAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descPodDisruptionBudgetAnnotationsName,
+		descPodDisruptionBudgetAnnotationsHelp,
+		append(descPodDisruptionBudgetAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (pdbc *podDisruptionBudgetCollector) collectPodDisruptionBudget(ch chan<- prometheus.Metric, pdb v1beta1.PodDisruptionBudget) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !pdbc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{pdb.Namespace, pdb.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	if !pdb.CreationTimestamp.IsZero() {
+		addGauge(descPodDisruptionBudgetCreated, float64(pdb.CreationTimestamp.Unix()))
+	}
+	addGauge(descPodDisruptionBudgetStatusCurrentHealthy, float64(pdb.Status.CurrentHealthy))
+	addGauge(descPodDisruptionBudgetStatusDesiredHealthy, float64(pdb.Status.DesiredHealthy))
+	addGauge(descPodDisruptionBudgetStatusExpectedPods, float64(pdb.Status.ExpectedPods))
+	addGauge(descPodDisruptionBudgetStatusPodDisruptionsAllowed, float64(pdb.Status.PodDisruptionsAllowed))
+	addGauge(descPodDisruptionBudgetStatusObservedGeneration, float64(pdb.Status.ObservedGeneration))
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(pdbc.opts.allowedLabels("poddisruptionbudgets", pdb.Labels))
+	addGauge(podDisruptionBudgetLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := pdbc.opts.allowedAnnotations("poddisruptionbudgets", pdb.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(podDisruptionBudgetAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
@@ -0,0 +1,434 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RegisterFunc registers the collectors of a resource with registry,
+// watching the objects of the resource in the given namespaces.
+type RegisterFunc func(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options)
+
+// Registry holds the collectors set up by the Register*Collector functions.
+// The collectors are registered with the embedded prometheus.Registry, so
+// they can still be gathered, while ServeHTTP serves the output cached by
+// their metrics stores. A Registry is also a prometheus.Collector collecting
+// all its collectors, to embed it in another prometheus.Registry.
+//
+// Collectors registered with the embedded MustRegister or Register are only
+// gathered. Register them with MustRegisterCollector to have them served.
+type Registry struct {
+	*prometheus.Registry
+
+	// enableMtx serializes Enable and Disable.
+	enableMtx sync.Mutex
+
+	mtx       sync.RWMutex
+	stores    []*MetricsStore
+	resources map[string]*registeredResource
+	// enabled holds the resources registered by each enabled collector.
+	enabled map[string][]string
+	// enabling collects the resources registered during Enable.
+	enabling []string
+}
+
+// registeredResource is everything mustRegister set up for a resource.
+type registeredResource struct {
+	collector prometheus.Collector
+	store     *MetricsStore
+	informers *SharedInformerList
+	stopCh    chan struct{}
+}
+
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		Registry:  prometheus.NewRegistry(),
+		resources: map[string]*registeredResource{},
+		enabled:   map[string][]string{},
+	}
+}
+
+// Enable calls register, which registers the collectors of the named
+// collector with r, and keeps track of them so that Disable can remove them
+// again. A collector that is already enabled is disabled first. Enable
+// reports whether register registered anything.
+func (r *Registry) Enable(name string, register func(r *Registry)) bool {
+	r.enableMtx.Lock()
+	defer r.enableMtx.Unlock()
+
+	r.disable(name)
+	r.mtx.Lock()
+	r.enabling = []string{}
+	r.mtx.Unlock()
+
+	register(r)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	registered := r.enabling
+	r.enabled[name] = registered
+	r.enabling = nil
+	return len(registered) > 0
+}
+
+// Disable stops the informers of the named collector and removes its
+// metrics. Disabling a collector that is not enabled does nothing.
+func (r *Registry) Disable(name string) {
+	r.enableMtx.Lock()
+	defer r.enableMtx.Unlock()
+
+	r.disable(name)
+}
+
+func (r *Registry) disable(name string) {
+	r.mtx.Lock()
+	resources, ok := r.enabled[name]
+	delete(r.enabled, name)
+	removed := []*registeredResource{}
+	for _, resource := range resources {
+		rr, ok := r.resources[resource]
+		if !ok {
+			continue
+		}
+		delete(r.resources, resource)
+		for i, s := range r.stores {
+			if s == rr.store {
+				r.stores = append(r.stores[:i], r.stores[i+1:]...)
+				break
+			}
+		}
+		removed = append(removed, rr)
+	}
+	r.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	for _, rr := range removed {
+		close(rr.stopCh)
+		r.Unregister(rr.collector)
+	}
+	for _, resource := range resources {
+		ResourcesPerScrapeMetric.DeleteLabelValues(resource)
+		CollectDurationMetric.DeleteLabelValues(resource)
+	}
+}
+
+// Enabled returns the names of the enabled collectors.
+func (r *Registry) Enabled() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	names := make([]string, 0, len(r.enabled))
+	for name := range r.enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MustRegisterStore registers c like the collectors of this package register
+// themselves, so that collectors built elsewhere are served from a metrics
+// store too. generate produces the metrics of a single object watched by
+// informers, which must not have been started yet.
+func (r *Registry) MustRegisterStore(resource string, c prometheus.Collector, informers *SharedInformerList, generate func(obj interface{}, ch chan<- prometheus.Metric), opts *Options) {
+	r.mustRegister(resource, c, informers, generate, opts)
+}
+
+// MustRegisterCollector registers c, which does not watch any objects, so
+// that its metrics are served along with those of the metrics stores. They
+// are collected again on every scrape, and served by every shard.
+func (r *Registry) MustRegisterCollector(resource string, c prometheus.Collector, opts *Options) {
+	generate := func(_ interface{}, ch chan<- prometheus.Metric) {
+		c.Collect(ch)
+	}
+	r.register(resource, c, &SharedInformerList{}, generate, opts, true)
+}
+
+// mustRegister registers c, adds a metrics store to the informers of the
+// resource, which caches the metrics generate produces for each object, and
+// starts the informers. If all metric families of c are excluded, nothing is
+// registered and the informers are not started.
+func (r *Registry) mustRegister(resource string, c prometheus.Collector, informers *SharedInformerList, generate metricsGenerator, opts *Options) *MetricsStore {
+	return r.register(resource, c, informers, generate, opts, false)
+}
+
+// register is mustRegister for both watched and unwatched collectors. The
+// store of an unwatched collector is rendered again on every scrape.
+func (r *Registry) register(resource string, c prometheus.Collector, informers *SharedInformerList, generate metricsGenerator, opts *Options, unwatched bool) *MetricsStore {
+	if opts.filtersMetrics() {
+		f := newMetricFilter(c, opts)
+		if len(f.allowed) == 0 {
+			glog.Infof("All metric families of %s are excluded, not collecting it", resource)
+			return nil
+		}
+		if len(f.denied) > 0 {
+			glog.Infof("Excluding metric families of %s: %s", resource, strings.Join(f.denied, ","))
+		}
+		c = &filteredCollector{collector: c, filter: f}
+	}
+
+	s := newMetricsStore(resource, c, generate, opts)
+	s.unwatched = unwatched
+	if opts.maxSeriesPerFamily() > 0 {
+		c = &cappedCollector{collector: c, store: s}
+	}
+	r.MustRegister(c)
+	informers.AddEventHandler(s)
+
+	stopCh := make(chan struct{})
+	r.mtx.Lock()
+	r.stores = append(r.stores, s)
+	r.resources[resource] = &registeredResource{
+		collector: c,
+		store:     s,
+		informers: informers,
+		stopCh:    stopCh,
+	}
+	if r.enabling != nil {
+		r.enabling = append(r.enabling, resource)
+	}
+	r.mtx.Unlock()
+
+	informers.Run(stopCh)
+	return s
+}
+
+// Describe implements the prometheus.Collector interface.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range r.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	r.observeFamilySeries(r.scrapedStores())
+	resources, collectors := r.registered()
+	for i, c := range collectors {
+		start := time.Now()
+		c.Collect(ch)
+		CollectDurationMetric.WithLabelValues(resources[i]).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (r *Registry) Gather() ([]*dto.MetricFamily, error) {
+	r.observeFamilySeries(r.scrapedStores())
+	return r.Registry.Gather()
+}
+
+func (r *Registry) collectors() []prometheus.Collector {
+	_, collectors := r.registered()
+	return collectors
+}
+
+// registered returns the registered resources in sorted order, together
+// with their collectors.
+func (r *Registry) registered() ([]string, []prometheus.Collector) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	resources := make([]string, 0, len(r.resources))
+	for resource := range r.resources {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	collectors := make([]prometheus.Collector, 0, len(resources))
+	for _, resource := range resources {
+		collectors = append(collectors, r.resources[resource].collector)
+	}
+	return resources, collectors
+}
+
+// Unsynced returns the resources whose informers have not synced yet.
+func (r *Registry) Unsynced() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	unsynced := []string{}
+	for resource, rr := range r.resources {
+		if !rr.informers.HasSynced() {
+			unsynced = append(unsynced, resource)
+		}
+	}
+	sort.Strings(unsynced)
+	return unsynced
+}
+
+// FailingWatches returns the resources for which listing or watching has
+// been failing for longer than timeout.
+func (r *Registry) FailingWatches(timeout time.Duration) []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	failing := []string{}
+	for resource, rr := range r.resources {
+		if d := rr.informers.failingFor(); d > timeout {
+			failing = append(failing, resource)
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// ServeHTTP implements the http.Handler interface. It writes the metrics of
+// all stores in the OpenMetrics text format if the request accepts it, and
+// in the text exposition format otherwise. The protobuf formats, which the
+// stores do not pre-render, are gathered from the collectors.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	openMetrics := openMetricsAccepted(req.Header)
+	if !openMetrics && expfmt.Negotiate(req.Header) != expfmt.FmtText {
+		promhttp.HandlerFor(r, promhttp.HandlerOpts{ErrorLog: gatherErrorLog{}}).ServeHTTP(w, req)
+		return
+	}
+	var writer io.Writer = w
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+	}
+	if gzipAccepted(req.Header) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		writer = gz
+	}
+
+	var err error
+	if openMetrics {
+		err = r.WriteAllOpenMetrics(writer)
+	} else {
+		err = r.WriteAll(writer)
+	}
+	if err != nil {
+		glog.Errorf("writing metrics failed: %s", err)
+	}
+}
+
+// WriteAll writes the metrics of all stores to w, ordered by metric family
+// name like prometheus.Registry gathers them.
+func (r *Registry) WriteAll(w io.Writer) error {
+	return r.writeAll(w, (*MetricsStore).writeFamily)
+}
+
+// WriteAllOpenMetrics writes the metrics of all stores to w in the
+// OpenMetrics text format, ordered like WriteAll orders them and terminated
+// by "# EOF".
+func (r *Registry) WriteAllOpenMetrics(w io.Writer) error {
+	if err := r.writeAll(w, (*MetricsStore).writeFamilyOpenMetrics); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "# EOF\n")
+	return err
+}
+
+func (r *Registry) writeAll(w io.Writer, writeFamily func(s *MetricsStore, w io.Writer, name string) error) error {
+	stores := r.scrapedStores()
+
+	type family struct {
+		name  string
+		store *MetricsStore
+	}
+	families := []family{}
+	for _, s := range stores {
+		s.observe()
+		for _, name := range s.familyNames() {
+			families = append(families, family{name: name, store: s})
+		}
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	durations := map[*MetricsStore]time.Duration{}
+	for _, f := range families {
+		start := time.Now()
+		if err := writeFamily(f.store, w, f.name); err != nil {
+			return err
+		}
+		durations[f.store] += time.Since(start)
+	}
+
+	for _, s := range stores {
+		CollectDurationMetric.WithLabelValues(s.resource).Observe(durations[s].Seconds())
+	}
+	r.observeFamilySeries(stores)
+	return nil
+}
+
+func (r *Registry) registeredStores() []*MetricsStore {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	stores := make([]*MetricsStore, len(r.stores))
+	copy(stores, r.stores)
+	return stores
+}
+
+// scrapedStores returns the registered stores, with the unwatched ones
+// rendered again for the scrape.
+func (r *Registry) scrapedStores() []*MetricsStore {
+	stores := r.registeredStores()
+	for _, s := range stores {
+		s.refresh()
+	}
+	return stores
+}
+
+// observeFamilySeries sets FamilySeriesMetric to the number of series of
+// each metric family in stores.
+func (r *Registry) observeFamilySeries(stores []*MetricsStore) {
+	series := map[string]int{}
+	for _, s := range stores {
+		s.addFamilySeries(series)
+	}
+	FamilySeriesMetric.set(series)
+}
+
+// gatherErrorLog logs the errors of gathering the protobuf formats.
+type gatherErrorLog struct{}
+
+// Println implements the promhttp.Logger interface.
+func (gatherErrorLog) Println(v ...interface{}) {
+	glog.Error(v...)
+}
+
+func gzipAccepted(header http.Header) bool {
+	for _, part := range strings.Split(header.Get("Accept-Encoding"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "gzip" || strings.HasPrefix(part, "gzip;") {
+			return true
+		}
+	}
+	return false
+}
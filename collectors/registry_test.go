@@ -0,0 +1,449 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func testPods() []v1.Pod {
+	var pods []v1.Pod
+	for i := 0; i < 12; i++ {
+		pods = append(pods, v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("pod%d", i),
+				Namespace:         fmt.Sprintf("ns%d", i%3),
+				UID:               types.UID(fmt.Sprintf("uid-pod%d", i)),
+				ResourceVersion:   "1",
+				CreationTimestamp: metav1.Time{Time: time.Unix(1500000000+int64(i), 0)},
+				Labels: map[string]string{
+					"app":                   fmt.Sprintf("app%d", i%4),
+					fmt.Sprintf("l%d", i%2): "x y",
+				},
+			},
+			Spec: v1.PodSpec{
+				NodeName: fmt.Sprintf("node%d", i%2),
+				Containers: []v1.Container{
+					{
+						Name: "container1",
+						Resources: v1.ResourceRequirements{
+							Requests: map[v1.ResourceName]resource.Quantity{
+								v1.ResourceCPU:    resource.MustParse("200m"),
+								v1.ResourceMemory: resource.MustParse("100M"),
+							},
+						},
+					},
+				},
+			},
+			Status: v1.PodStatus{
+				Phase: v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						Name:         "container1",
+						Image:        "k8s.gcr.io/hyperkube1",
+						RestartCount: int32(i),
+						State:        v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					},
+				},
+			},
+		})
+	}
+	return pods
+}
+
+func testNodes() []v1.Node {
+	return []v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "uid-node1"},
+			Status: v1.NodeStatus{
+				Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node0", UID: "uid-node0"},
+			Spec:       v1.NodeSpec{Unschedulable: true},
+		},
+	}
+}
+
+// newTestRegistry creates a Registry with a pod and a node store filled with
+// the given objects, and a prometheus.Registry collecting the same objects
+// through the pod and node collectors.
+func newTestRegistry(pods []v1.Pod, nodes []v1.Node, opts *Options) (*Registry, *prometheus.Registry) {
+	r := NewRegistry()
+	pc := &podCollector{store: mockPodStore{f: func() ([]v1.Pod, error) { return pods, nil }}}
+	podStore := r.mustRegister("pod", pc, &SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {
+		pc.collectPod(ch, *obj.(*v1.Pod))
+	}, opts)
+	nc := &nodeCollector{store: mockNodeStore{list: func() (v1.NodeList, error) { return v1.NodeList{Items: nodes}, nil }}}
+	nodeStore := r.mustRegister("node", nc, &SharedInformerList{}, func(obj interface{}, ch chan<- prometheus.Metric) {
+		nc.collectNode(ch, *obj.(*v1.Node))
+	}, opts)
+
+	for i := range pods {
+		podStore.OnAdd(&pods[i])
+	}
+	for i := range nodes {
+		nodeStore.OnAdd(&nodes[i])
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(pc, nc)
+	return r, reg
+}
+
+func gatherText(g prometheus.Gatherer) (string, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// collectText returns the metrics collected by c in the text format.
+func collectText(c prometheus.Collector) (string, error) {
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		return "", err
+	}
+	return gatherText(reg)
+}
+
+func writeAllText(r *Registry) (string, error) {
+	var buf bytes.Buffer
+	err := r.WriteAll(&buf)
+	return buf.String(), err
+}
+
+func TestRegistryWriteAllMatchesGather(t *testing.T) {
+	pods := testPods()
+	r, reg := newTestRegistry(pods, testNodes(), nil)
+
+	want, err := gatherText(reg)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	got, err := writeAllText(r)
+	if err != nil {
+		t.Fatalf("writing metrics failed: %s", err)
+	}
+	if got != want {
+		t.Errorf("store output does not match gathered output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+	}
+}
+
+func TestMetricsStoreUpdateAndDelete(t *testing.T) {
+	pods := testPods()
+	r, _ := newTestRegistry(pods, nil, nil)
+	podStore := r.stores[0]
+
+	updated := pods[3]
+	updated.ResourceVersion = "2"
+	updated.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "container1", RestartCount: 42}}
+	podStore.OnUpdate(&pods[3], &updated)
+	podStore.OnDelete(&pods[5])
+
+	remaining := append([]v1.Pod{}, pods[:3]...)
+	remaining = append(remaining, updated, pods[4])
+	remaining = append(remaining, pods[6:]...)
+	_, reg := newTestRegistry(remaining, nil, nil)
+
+	want, err := gatherText(reg)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	got, err := writeAllText(r)
+	if err != nil {
+		t.Fatalf("writing metrics failed: %s", err)
+	}
+	if got != want {
+		t.Errorf("store output does not match gathered output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+	}
+}
+
+func TestMetricsStoreDropsDuplicateSeries(t *testing.T) {
+	pods := testPods()[:2]
+	// A pod replacing a deleted pod of the same name, while the deletion
+	// has not been seen yet.
+	replacement := pods[0]
+	replacement.UID = "uid-replacement"
+	r, _ := newTestRegistry(append(pods, replacement), nil, nil)
+	_, reg := newTestRegistry(pods, nil, nil)
+
+	before := telemetryValue(t, ScrapeErrorTotalMetric.WithLabelValues("pod"))
+	want, err := gatherText(reg)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	got, err := writeAllText(r)
+	if err != nil {
+		t.Fatalf("writing metrics failed: %s", err)
+	}
+	if got != want {
+		t.Errorf("store output does not match gathered output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+	}
+	if telemetryValue(t, ScrapeErrorTotalMetric.WithLabelValues("pod")) <= before {
+		t.Error("expected duplicate series to be counted as scrape errors")
+	}
+}
+
+func TestMetricsStoreSharding(t *testing.T) {
+	pods := testPods()
+
+	var all bytes.Buffer
+	for shard := int32(0); shard < 2; shard++ {
+		r, _ := newTestRegistry(pods, nil, &Options{Shard: shard, TotalShards: 2})
+		r.stores[0].mtx.RLock()
+		for uid := range r.stores[0].objects {
+			fmt.Fprintf(&all, "%s\n", uid)
+		}
+		r.stores[0].mtx.RUnlock()
+	}
+
+	seen := map[string]int{}
+	for _, uid := range bytes.Fields(all.Bytes()) {
+		seen[string(uid)]++
+	}
+	for _, p := range pods {
+		if seen[string(p.UID)] != 1 {
+			t.Errorf("pod %s stored by %d shards, want exactly 1", p.Name, seen[string(p.UID)])
+		}
+	}
+}
+
+func TestMetricsStoreSeriesLimit(t *testing.T) {
+	SeriesDroppedTotalMetric.Reset()
+	pods := testPods()
+	r, _ := newTestRegistry(pods, nil, &Options{MaxSeriesPerFamily: 2})
+	podStore := r.stores[0]
+
+	// Every pod has a single kube_pod_info series.
+	if got := podStore.familySeries("kube_pod_info"); got != 2 {
+		t.Errorf("expected 2 kube_pod_info series, got %d", got)
+	}
+	if got, want := telemetryValue(t, SeriesDroppedTotalMetric.WithLabelValues("kube_pod_info")), float64(len(pods)-2); got != want {
+		t.Errorf("expected %v dropped kube_pod_info series, got %v", want, got)
+	}
+
+	// Updates of dropped pods do not count their series again.
+	for i := range pods {
+		podStore.OnUpdate(&pods[i], &pods[i])
+	}
+	if got, want := telemetryValue(t, SeriesDroppedTotalMetric.WithLabelValues("kube_pod_info")), float64(len(pods)-2); got != want {
+		t.Errorf("expected %v dropped kube_pod_info series after updates, got %v", want, got)
+	}
+
+	// Collecting and gathering the registry yields the series kept by the
+	// store.
+	want, err := writeAllText(r)
+	if err != nil {
+		t.Fatalf("writing metrics failed: %s", err)
+	}
+	if got := familySeriesValue("kube_pod_info"); got != 2 {
+		t.Errorf("expected the family series metric to report 2 kube_pod_info series, got %v", got)
+	}
+	gathered, err := gatherText(r)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	collected, err := collectText(r)
+	if err != nil {
+		t.Fatalf("collecting metrics failed: %s", err)
+	}
+	for _, got := range []string{gathered, collected} {
+		if got != want {
+			t.Errorf("output does not match the store output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+		}
+	}
+
+	// Deleting a pod whose series were kept makes room for the series of
+	// the next update.
+	for i := range pods {
+		if len(podStore.families["kube_pod_info"][pods[i].UID]) > 0 {
+			podStore.OnDelete(&pods[i])
+			break
+		}
+	}
+	if got := podStore.familySeries("kube_pod_info"); got != 1 {
+		t.Errorf("expected 1 kube_pod_info series after deleting a pod, got %d", got)
+	}
+}
+
+// familySeriesValue returns the number of series FamilySeriesMetric reports
+// for the named metric family.
+func familySeriesValue(name string) int {
+	FamilySeriesMetric.mtx.RLock()
+	defer FamilySeriesMetric.mtx.RUnlock()
+	return FamilySeriesMetric.series[name]
+}
+
+func telemetryValue(t *testing.T, m prometheus.Metric) float64 {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatalf("writing metric failed: %s", err)
+	}
+	if pb.Counter != nil {
+		return pb.Counter.GetValue()
+	}
+	return pb.Gauge.GetValue()
+}
+
+func TestRegistryWriteAllOpenMetrics(t *testing.T) {
+	r, _ := newTestRegistry(testPods()[:2], testNodes()[:1], nil)
+
+	var buf bytes.Buffer
+	if err := r.WriteAllOpenMetrics(&buf); err != nil {
+		t.Fatalf("writing metrics failed: %s", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		`# HELP kube_node_status_capacity_cpu_cores The total CPU resources of the node.
+# TYPE kube_node_status_capacity_cpu_cores gauge
+# UNIT kube_node_status_capacity_cpu_cores cores
+kube_node_status_capacity_cpu_cores{node="node1"} 4
+`,
+		`# TYPE kube_pod_container_resource_requests_memory_bytes gauge
+# UNIT kube_pod_container_resource_requests_memory_bytes bytes
+`,
+		`# HELP kube_pod_container_status_restarts The number of container restarts per container.
+# TYPE kube_pod_container_status_restarts counter
+kube_pod_container_status_restarts_total{container="container1",namespace="ns0",pod="pod0"} 0
+kube_pod_container_status_restarts_created{container="container1",namespace="ns0",pod="pod0"} 1500000000
+kube_pod_container_status_restarts_total{container="container1",namespace="ns1",pod="pod1"} 1
+kube_pod_container_status_restarts_created{container="container1",namespace="ns1",pod="pod1"} 1500000001
+`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain:\n%s\ngot:\n%s", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "\n# EOF\n") {
+		t.Errorf("expected output to end with # EOF, got:\n%s", got)
+	}
+}
+
+func TestRegistryServeHTTPContentNegotiation(t *testing.T) {
+	r, _ := newTestRegistry(testPods()[:1], nil, nil)
+
+	cases := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "text/plain; version=0.0.4"},
+		{"text/plain;version=0.0.4", "text/plain; version=0.0.4"},
+		{"application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", openMetricsContentType},
+		{"application/openmetrics-text;q=0.3,text/plain;q=0.5", "text/plain; version=0.0.4"},
+		{"application/openmetrics-text", openMetricsContentType},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("accept %q: unexpected status %d", c.accept, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("accept %q: expected content type %q, got %q", c.accept, c.contentType, got)
+		}
+		if openMetrics := strings.HasSuffix(w.Body.String(), "# EOF\n"); openMetrics != (c.contentType == openMetricsContentType) {
+			t.Errorf("accept %q: unexpected body:\n%s", c.accept, w.Body.String())
+		}
+	}
+}
+
+func TestRegistryServeHTTPProtobuf(t *testing.T) {
+	r, reg := newTestRegistry(testPods(), testNodes(), nil)
+
+	want, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if got := expfmt.Format(w.Header().Get("Content-Type")); got != expfmt.FmtProtoDelim {
+		t.Fatalf("expected content type %q, got %q", expfmt.FmtProtoDelim, got)
+	}
+
+	var got []*dto.MetricFamily
+	dec := expfmt.NewDecoder(w.Body, expfmt.FmtProtoDelim)
+	for {
+		mf := &dto.MetricFamily{}
+		if err := dec.Decode(mf); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("decoding response failed: %s", err)
+		}
+		got = append(got, mf)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d metric families, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].String() != want[i].String() {
+			t.Errorf("metric family %d: want %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestRegistryCollectMatchesGather(t *testing.T) {
+	r, reg := newTestRegistry(testPods(), testNodes(), nil)
+
+	want, err := gatherText(reg)
+	if err != nil {
+		t.Fatalf("gathering metrics failed: %s", err)
+	}
+	outer := prometheus.NewRegistry()
+	if err := outer.Register(r); err != nil {
+		t.Fatalf("registering registry failed: %s", err)
+	}
+	got, err := gatherText(outer)
+	if err != nil {
+		t.Fatalf("gathering registry failed: %s", err)
+	}
+	if got != want {
+		t.Errorf("collected output does not match gathered output; want:\n\n%s\n\ngot:\n\n%s", want, got)
+	}
+}
@@ -19,14 +19,15 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/extensions/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
+	descReplicaSetAnnotationsName          = "kube_replicaset_annotations"
+	descReplicaSetAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descReplicaSetAnnotationsDefaultLabels = []string{"namespace", "replicaset"}
+
 	descReplicaSetCreated = prometheus.NewDesc(
 		"kube_replicaset_created",
 		"Unix creation timestamp",
@@ -62,6 +63,12 @@ var (
 		"Sequence number representing a specific generation of the desired state.",
 		[]string{"namespace", "replicaset"}, nil,
 	)
+
+	descReplicaSetAnnotations = prometheus.NewDesc(
+		descReplicaSetAnnotationsName,
+		descReplicaSetAnnotationsHelp,
+		descReplicaSetAnnotationsDefaultLabels, nil,
+	)
 )
 
 type ReplicaSetLister func() ([]v1beta1.ReplicaSet, error)
@@ -70,21 +77,29 @@ func (l ReplicaSetLister) List() ([]v1beta1.ReplicaSet, error) {
 	return l()
 }
 
-func RegisterReplicaSetCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.ExtensionsV1beta1().RESTClient()
-	glog.Infof("collect replicaset with %s", client.APIVersion())
-	rslw := cache.NewListWatchFromClient(client, "replicasets", namespace, fields.Everything())
-	rsinf := cache.NewSharedInformer(rslw, &v1beta1.ReplicaSet{}, resyncPeriod)
+func RegisterReplicaSetCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	rsinfs, err := newVersionedInformerList(kubeClient, "replicasets", namespaces, &v1beta1.ReplicaSet{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch replicasets: %v", err)
+		return
+	}
 
 	replicaSetLister := ReplicaSetLister(func() (replicasets []v1beta1.ReplicaSet, err error) {
-		for _, c := range rsinf.GetStore().List() {
-			replicasets = append(replicasets, *(c.(*v1beta1.ReplicaSet)))
+		for _, rsinf := range *rsinfs {
+			for _, c := range rsinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				replicasets = append(replicasets, *(c.(*v1beta1.ReplicaSet)))
+			}
 		}
 		return replicasets, nil
 	})
 
-	registry.MustRegister(&replicasetCollector{store: replicaSetLister})
-	go rsinf.Run(context.Background().Done())
+	dc := &replicasetCollector{store: replicaSetLister, opts: opts}
+	registry.mustRegister("replicaset", dc, rsinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		dc.collectReplicaSet(ch, *obj.(*v1beta1.ReplicaSet))
+	}, opts)
 }
 
 type replicasetStore interface {
@@ -94,6 +109,7 @@ type replicasetStore interface {
 // replicasetCollector collects metrics about all replicasets in the cluster.
 type replicasetCollector struct {
 	store replicasetStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -105,6 +121,7 @@ func (dc *replicasetCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descReplicaSetStatusObservedGeneration
 	ch <- descReplicaSetSpecReplicas
 	ch <- descReplicaSetMetadataGeneration
+	ch <- descReplicaSetAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -127,6 +144,9 @@ func (dc *replicasetCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (dc *replicasetCollector) collectReplicaSet(ch chan<- prometheus.Metric, d v1beta1.ReplicaSet) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !dc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{d.Namespace, d.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -141,4 +161,18 @@ func (dc *replicasetCollector) collectReplicaSet(ch chan<- prometheus.Metric, d
 		addGauge(descReplicaSetSpecReplicas, float64(*d.Spec.Replicas))
 	}
 	addGauge(descReplicaSetMetadataGeneration, float64(d.ObjectMeta.Generation))
+
+	if annotations, ok := dc.opts.allowedAnnotations("replicasets", d.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(replicaSetAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+func replicaSetAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descReplicaSetAnnotationsName,
+		descReplicaSetAnnotationsHelp,
+		append(descReplicaSetAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
 }
@@ -55,6 +55,8 @@ func TestReplicaSetCollector(t *testing.T) {
 		# TYPE kube_replicaset_status_observed_generation gauge
 		# HELP kube_replicaset_spec_replicas Number of desired pods for a ReplicaSet.
 		# TYPE kube_replicaset_spec_replicas gauge
+		# HELP kube_replicaset_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_replicaset_annotations gauge
 	`
 	cases := []struct {
 		rss  []v1beta1.ReplicaSet
@@ -17,17 +17,17 @@ limitations under the License.
 package collectors
 
 import (
-	"context"
-
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
+	descReplicationControllerAnnotationsName          = "kube_replicationcontroller_annotations"
+	descReplicationControllerAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descReplicationControllerAnnotationsDefaultLabels = []string{"namespace", "replicationcontroller"}
+
 	descReplicationControllerCreated = prometheus.NewDesc(
 		"kube_replicationcontroller_created",
 		"Unix creation timestamp",
@@ -68,6 +68,12 @@ var (
 		"Sequence number representing a specific generation of the desired state.",
 		[]string{"namespace", "replicationcontroller"}, nil,
 	)
+
+	descReplicationControllerAnnotations = prometheus.NewDesc(
+		descReplicationControllerAnnotationsName,
+		descReplicationControllerAnnotationsHelp,
+		descReplicationControllerAnnotationsDefaultLabels, nil,
+	)
 )
 
 type ReplicationControllerLister func() ([]v1.ReplicationController, error)
@@ -76,21 +82,27 @@ func (l ReplicationControllerLister) List() ([]v1.ReplicationController, error)
 	return l()
 }
 
-func RegisterReplicationControllerCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterReplicationControllerCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect replicationcontroller with %s", client.APIVersion())
-	rclw := cache.NewListWatchFromClient(client, "replicationcontrollers", namespace, fields.Everything())
-	rcinf := cache.NewSharedInformer(rclw, &v1.ReplicationController{}, resyncPeriod)
+	rcinfs := NewSharedInformerList(client, "replicationcontrollers", namespaces, &v1.ReplicationController{}, opts)
 
 	replicationControllerLister := ReplicationControllerLister(func() (rcs []v1.ReplicationController, err error) {
-		for _, c := range rcinf.GetStore().List() {
-			rcs = append(rcs, *(c.(*v1.ReplicationController)))
+		for _, rcinf := range *rcinfs {
+			for _, c := range rcinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				rcs = append(rcs, *(c.(*v1.ReplicationController)))
+			}
 		}
 		return rcs, nil
 	})
 
-	registry.MustRegister(&replicationcontrollerCollector{store: replicationControllerLister})
-	go rcinf.Run(context.Background().Done())
+	dc := &replicationcontrollerCollector{store: replicationControllerLister, opts: opts}
+	registry.mustRegister("replicationcontroller", dc, rcinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		dc.collectReplicationController(ch, *obj.(*v1.ReplicationController))
+	}, opts)
 }
 
 type replicationcontrollerStore interface {
@@ -99,6 +111,7 @@ type replicationcontrollerStore interface {
 
 type replicationcontrollerCollector struct {
 	store replicationcontrollerStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -111,6 +124,7 @@ func (dc *replicationcontrollerCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descReplicationControllerStatusObservedGeneration
 	ch <- descReplicationControllerSpecReplicas
 	ch <- descReplicationControllerMetadataGeneration
+	ch <- descReplicationControllerAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -133,6 +147,9 @@ func (dc *replicationcontrollerCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (dc *replicationcontrollerCollector) collectReplicationController(ch chan<- prometheus.Metric, d v1.ReplicationController) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !dc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{d.Namespace, d.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -148,4 +165,18 @@ func (dc *replicationcontrollerCollector) collectReplicationController(ch chan<-
 		addGauge(descReplicationControllerSpecReplicas, float64(*d.Spec.Replicas))
 	}
 	addGauge(descReplicationControllerMetadataGeneration, float64(d.ObjectMeta.Generation))
+
+	if annotations, ok := dc.opts.allowedAnnotations("replicationcontrollers", d.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(replicationControllerAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+func replicationControllerAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descReplicationControllerAnnotationsName,
+		descReplicationControllerAnnotationsHelp,
+		append(descReplicationControllerAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
 }
@@ -57,6 +57,8 @@ func TestReplicationControllerCollector(t *testing.T) {
 		# TYPE kube_replicationcontroller_status_observed_generation gauge
 		# HELP kube_replicationcontroller_spec_replicas Number of desired pods for a ReplicationController.
 		# TYPE kube_replicationcontroller_spec_replicas gauge
+		# HELP kube_replicationcontroller_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_replicationcontroller_annotations gauge
 	`
 	cases := []struct {
 		rss  []v1.ReplicationController
@@ -19,14 +19,15 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
+	descResourceQuotaAnnotationsName          = "kube_resourcequota_annotations"
+	descResourceQuotaAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descResourceQuotaAnnotationsDefaultLabels = []string{"resourcequota", "namespace"}
+
 	descResourceQuotaCreated = prometheus.NewDesc(
 		"kube_resourcequota_created",
 		"Unix creation timestamp",
@@ -42,6 +43,12 @@ var (
 			"type",
 		}, nil,
 	)
+
+	descResourceQuotaAnnotations = prometheus.NewDesc(
+		descResourceQuotaAnnotationsName,
+		descResourceQuotaAnnotationsHelp,
+		descResourceQuotaAnnotationsDefaultLabels, nil,
+	)
 )
 
 type ResourceQuotaLister func() (v1.ResourceQuotaList, error)
@@ -50,21 +57,27 @@ func (l ResourceQuotaLister) List() (v1.ResourceQuotaList, error) {
 	return l()
 }
 
-func RegisterResourceQuotaCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterResourceQuotaCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect resourcequota with %s", client.APIVersion())
-	rqlw := cache.NewListWatchFromClient(client, "resourcequotas", namespace, fields.Everything())
-	rqinf := cache.NewSharedInformer(rqlw, &v1.ResourceQuota{}, resyncPeriod)
+	rqinfs := NewSharedInformerList(client, "resourcequotas", namespaces, &v1.ResourceQuota{}, opts)
 
 	resourceQuotaLister := ResourceQuotaLister(func() (quotas v1.ResourceQuotaList, err error) {
-		for _, rq := range rqinf.GetStore().List() {
-			quotas.Items = append(quotas.Items, *(rq.(*v1.ResourceQuota)))
+		for _, rqinf := range *rqinfs {
+			for _, rq := range rqinf.GetStore().List() {
+				if !opts.owns(rq) {
+					continue
+				}
+				quotas.Items = append(quotas.Items, *(rq.(*v1.ResourceQuota)))
+			}
 		}
 		return quotas, nil
 	})
 
-	registry.MustRegister(&resourceQuotaCollector{store: resourceQuotaLister})
-	go rqinf.Run(context.Background().Done())
+	rqc := &resourceQuotaCollector{store: resourceQuotaLister, opts: opts}
+	registry.mustRegister("resourcequota", rqc, rqinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		rqc.collectResourceQuota(ch, *obj.(*v1.ResourceQuota))
+	}, opts)
 }
 
 type resourceQuotaStore interface {
@@ -74,12 +87,14 @@ type resourceQuotaStore interface {
 // resourceQuotaCollector collects metrics about all resource quotas in the cluster.
 type resourceQuotaCollector struct {
 	store resourceQuotaStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
 func (rqc *resourceQuotaCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descResourceQuotaCreated
 	ch <- descResourceQuota
+	ch <- descResourceQuotaAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -102,6 +117,9 @@ func (rqc *resourceQuotaCollector) Collect(ch chan<- prometheus.Metric) {
 
 func (rqc *resourceQuotaCollector) collectResourceQuota(ch chan<- prometheus.Metric, rq v1.ResourceQuota) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !rqc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{rq.Name, rq.Namespace}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -116,4 +134,17 @@ func (rqc *resourceQuotaCollector) collectResourceQuota(ch chan<- prometheus.Met
 		addGauge(descResourceQuota, float64(qty.MilliValue())/1000, string(res), "used")
 	}
 
+	if annotations, ok := rqc.opts.allowedAnnotations("resourcequotas", rq.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(resourceQuotaAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+func resourceQuotaAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descResourceQuotaAnnotationsName,
+		descResourceQuotaAnnotationsHelp,
+		append(descResourceQuotaAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
 }
@@ -41,6 +41,8 @@ func TestResourceQuotaCollector(t *testing.T) {
 	# TYPE kube_resourcequota gauge
 	# HELP kube_resourcequota_created Unix creation timestamp
 	# TYPE kube_resourcequota_created gauge
+	# HELP kube_resourcequota_annotations Kubernetes annotations converted to Prometheus labels.
+	# TYPE kube_resourcequota_annotations gauge
 	`
 	cases := []struct {
 		quotas  []v1.ResourceQuota
@@ -0,0 +1,202 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descRoleLabelsName          = "kube_role_labels"
+	descRoleLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descRoleLabelsDefaultLabels = []string{"namespace", "role"}
+
+	descRoleAnnotationsName          = "kube_role_annotations"
+	descRoleAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descRoleAnnotationsDefaultLabels = []string{"namespace", "role"}
+
+	descRoleCreated = prometheus.NewDesc(
+		"kube_role_created",
+		"Unix creation timestamp",
+		[]string{"namespace", "role"}, nil,
+	)
+
+	descRoleRules = prometheus.NewDesc(
+		"kube_role_rules",
+		"Number of policy rules of the role.",
+		[]string{"namespace", "role"}, nil,
+	)
+
+	descRoleWildcardVerbs = prometheus.NewDesc(
+		"kube_role_wildcard_verbs",
+		"Whether a rule of the role grants all verbs.",
+		[]string{"namespace", "role"}, nil,
+	)
+
+	descRoleWildcardResources = prometheus.NewDesc(
+		"kube_role_wildcard_resources",
+		"Whether a rule of the role grants access to all resources.",
+		[]string{"namespace", "role"}, nil,
+	)
+
+	descRoleLabels = prometheus.NewDesc(
+		descRoleLabelsName,
+		descRoleLabelsHelp,
+		descRoleLabelsDefaultLabels, nil,
+	)
+
+	descRoleAnnotations = prometheus.NewDesc(
+		descRoleAnnotationsName,
+		descRoleAnnotationsHelp,
+		descRoleAnnotationsDefaultLabels, nil,
+	)
+)
+
+type RoleLister func() ([]rbacv1.Role, error)
+
+func (l RoleLister) List() ([]rbacv1.Role, error) {
+	return l()
+}
+
+func RegisterRoleCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	rinfs, err := newVersionedInformerList(kubeClient, "roles", namespaces, &rbacv1.Role{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch roles: %v", err)
+		return
+	}
+
+	roleLister := RoleLister(func() (roles []rbacv1.Role, err error) {
+		for _, rinf := range *rinfs {
+			for _, m := range rinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				roles = append(roles, *m.(*rbacv1.Role))
+			}
+		}
+		return roles, nil
+	})
+
+	rc := &roleCollector{store: roleLister, opts: opts}
+	registry.mustRegister("role", rc, rinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		rc.collectRole(ch, *obj.(*rbacv1.Role))
+	}, opts)
+}
+
+type roleStore interface {
+	List() (roles []rbacv1.Role, err error)
+}
+
+// roleCollector collects metrics about all roles in the cluster.
+type roleCollector struct {
+	store roleStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (rc *roleCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descRoleCreated
+	ch <- descRoleRules
+	ch <- descRoleWildcardVerbs
+	ch <- descRoleWildcardResources
+	ch <- descRoleLabels
+	ch <- descRoleAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (rc *roleCollector) Collect(ch chan<- prometheus.Metric) {
+	roles, err := rc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "role"}).Inc()
+		glog.Errorf("listing roles failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "role"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "role"}).Observe(float64(len(roles)))
+	for _, r := range roles {
+		rc.collectRole(ch, r)
+	}
+
+	glog.V(4).Infof("collected %d roles", len(roles))
+}
+
+func roleLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descRoleLabelsName,
+		descRoleLabelsHelp,
+		append(descRoleLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func roleAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descRoleAnnotationsName,
+		descRoleAnnotationsHelp,
+		append(descRoleAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (rc *roleCollector) collectRole(ch chan<- prometheus.Metric, r rbacv1.Role) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !rc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{r.Namespace, r.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	if !r.CreationTimestamp.IsZero() {
+		addGauge(descRoleCreated, float64(r.CreationTimestamp.Unix()))
+	}
+	addGauge(descRoleRules, float64(len(r.Rules)))
+	wildcardVerbs, wildcardResources := policyRulesWildcards(r.Rules)
+	addGauge(descRoleWildcardVerbs, boolFloat64(wildcardVerbs))
+	addGauge(descRoleWildcardResources, boolFloat64(wildcardResources))
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(rc.opts.allowedLabels("roles", r.Labels))
+	addGauge(roleLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := rc.opts.allowedAnnotations("roles", r.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(roleAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+// policyRulesWildcards returns whether any of the rules grants all verbs,
+// and whether any grants access to all resources.
+func policyRulesWildcards(rules []rbacv1.PolicyRule) (verbs, resources bool) {
+	for _, rule := range rules {
+		verbs = verbs || containsString(rule.Verbs, rbacv1.VerbAll)
+		resources = resources || containsString(rule.Resources, rbacv1.ResourceAll)
+	}
+	return verbs, resources
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockRoleStore struct {
+	list func() ([]rbacv1.Role, error)
+}
+
+func (rs mockRoleStore) List() ([]rbacv1.Role, error) {
+	return rs.list()
+}
+
+func TestRoleCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_role_created Unix creation timestamp
+		# TYPE kube_role_created gauge
+		# HELP kube_role_rules Number of policy rules of the role.
+		# TYPE kube_role_rules gauge
+		# HELP kube_role_wildcard_verbs Whether a rule of the role grants all verbs.
+		# TYPE kube_role_wildcard_verbs gauge
+		# HELP kube_role_wildcard_resources Whether a rule of the role grants access to all resources.
+		# TYPE kube_role_wildcard_resources gauge
+		# HELP kube_role_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_role_labels gauge
+		# HELP kube_role_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_role_annotations gauge
+	`
+	cases := []struct {
+		roles   []rbacv1.Role
+		metrics []string // which metrics should be checked
+		want    string
+	}{
+		{
+			roles: []rbacv1.Role{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "pod-reader",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Namespace:         "ns1",
+						Labels: map[string]string{
+							"team": "web",
+						},
+					},
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch"}},
+						{APIGroups: []string{""}, Resources: []string{"pods/log"}, Verbs: []string{"get"}},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "admin",
+						Namespace: "ns2",
+					},
+					Rules: []rbacv1.PolicyRule{
+						{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}},
+						{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+					},
+				},
+			},
+			want: metadata + `
+				kube_role_created{namespace="ns1",role="pod-reader"} 1.5e+09
+				kube_role_rules{namespace="ns1",role="pod-reader"} 2
+				kube_role_rules{namespace="ns2",role="admin"} 2
+				kube_role_wildcard_verbs{namespace="ns1",role="pod-reader"} 0
+				kube_role_wildcard_verbs{namespace="ns2",role="admin"} 1
+				kube_role_wildcard_resources{namespace="ns1",role="pod-reader"} 0
+				kube_role_wildcard_resources{namespace="ns2",role="admin"} 1
+				kube_role_labels{label_team="web",namespace="ns1",role="pod-reader"} 1
+				kube_role_labels{namespace="ns2",role="admin"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		rc := &roleCollector{
+			store: &mockRoleStore{
+				list: func() ([]rbacv1.Role, error) {
+					return c.roles, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(rc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
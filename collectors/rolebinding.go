@@ -0,0 +1,195 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	descRoleBindingLabelsName          = "kube_rolebinding_labels"
+	descRoleBindingLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descRoleBindingLabelsDefaultLabels = []string{"namespace", "rolebinding"}
+
+	descRoleBindingAnnotationsName          = "kube_rolebinding_annotations"
+	descRoleBindingAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descRoleBindingAnnotationsDefaultLabels = []string{"namespace", "rolebinding"}
+
+	descRoleBindingInfo = prometheus.NewDesc(
+		"kube_rolebinding_info",
+		"Information about rolebinding.",
+		[]string{"namespace", "rolebinding", "role_kind", "role_name"}, nil,
+	)
+
+	descRoleBindingCreated = prometheus.NewDesc(
+		"kube_rolebinding_created",
+		"Unix creation timestamp",
+		[]string{"namespace", "rolebinding"}, nil,
+	)
+
+	descRoleBindingSubject = prometheus.NewDesc(
+		"kube_rolebinding_subject",
+		"Subject the rolebinding grants the referenced role to.",
+		[]string{"namespace", "rolebinding", "role_kind", "role_name", "subject_kind", "subject_name", "subject_namespace"}, nil,
+	)
+
+	descRoleBindingLabels = prometheus.NewDesc(
+		descRoleBindingLabelsName,
+		descRoleBindingLabelsHelp,
+		descRoleBindingLabelsDefaultLabels, nil,
+	)
+
+	descRoleBindingAnnotations = prometheus.NewDesc(
+		descRoleBindingAnnotationsName,
+		descRoleBindingAnnotationsHelp,
+		descRoleBindingAnnotationsDefaultLabels, nil,
+	)
+)
+
+type RoleBindingLister func() ([]rbacv1.RoleBinding, error)
+
+func (l RoleBindingLister) List() ([]rbacv1.RoleBinding, error) {
+	return l()
+}
+
+func RegisterRoleBindingCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	rbinfs, err := newVersionedInformerList(kubeClient, "rolebindings", namespaces, &rbacv1.RoleBinding{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch rolebindings: %v", err)
+		return
+	}
+
+	roleBindingLister := RoleBindingLister(func() (roleBindings []rbacv1.RoleBinding, err error) {
+		for _, rbinf := range *rbinfs {
+			for _, m := range rbinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				roleBindings = append(roleBindings, *m.(*rbacv1.RoleBinding))
+			}
+		}
+		return roleBindings, nil
+	})
+
+	rbc := &roleBindingCollector{store: roleBindingLister, opts: opts}
+	registry.mustRegister("rolebinding", rbc, rbinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		rbc.collectRoleBinding(ch, *obj.(*rbacv1.RoleBinding))
+	}, opts)
+}
+
+type roleBindingStore interface {
+	List() (roleBindings []rbacv1.RoleBinding, err error)
+}
+
+// roleBindingCollector collects metrics about all role bindings in the
+// cluster.
+type roleBindingCollector struct {
+	store roleBindingStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (rbc *roleBindingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descRoleBindingInfo
+	ch <- descRoleBindingCreated
+	ch <- descRoleBindingSubject
+	ch <- descRoleBindingLabels
+	ch <- descRoleBindingAnnotations
+}
+
+// Collect implements the prometheus.Collector interface.
+func (rbc *roleBindingCollector) Collect(ch chan<- prometheus.Metric) {
+	roleBindings, err := rbc.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "rolebinding"}).Inc()
+		glog.Errorf("listing rolebindings failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "rolebinding"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "rolebinding"}).Observe(float64(len(roleBindings)))
+	for _, rb := range roleBindings {
+		rbc.collectRoleBinding(ch, rb)
+	}
+
+	glog.V(4).Infof("collected %d rolebindings", len(roleBindings))
+}
+
+func roleBindingLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descRoleBindingLabelsName,
+		descRoleBindingLabelsHelp,
+		append(descRoleBindingLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func roleBindingAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descRoleBindingAnnotationsName,
+		descRoleBindingAnnotationsHelp,
+		append(descRoleBindingAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+func (rbc *roleBindingCollector) collectRoleBinding(ch chan<- prometheus.Metric, rb rbacv1.RoleBinding) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !rbc.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{rb.Namespace, rb.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	addGauge(descRoleBindingInfo, 1, rb.RoleRef.Kind, rb.RoleRef.Name)
+	if !rb.CreationTimestamp.IsZero() {
+		addGauge(descRoleBindingCreated, float64(rb.CreationTimestamp.Unix()))
+	}
+	for _, s := range uniqueSubjects(rb.Subjects) {
+		addGauge(descRoleBindingSubject, 1, rb.RoleRef.Kind, rb.RoleRef.Name, s.Kind, s.Name, s.Namespace)
+	}
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(rbc.opts.allowedLabels("rolebindings", rb.Labels))
+	addGauge(roleBindingLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := rbc.opts.allowedAnnotations("rolebindings", rb.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(roleBindingAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+// uniqueSubjects returns subjects without the repeated ones, which would
+// otherwise be reported as duplicate series.
+func uniqueSubjects(subjects []rbacv1.Subject) []rbacv1.Subject {
+	seen := map[rbacv1.Subject]bool{}
+	unique := make([]rbacv1.Subject, 0, len(subjects))
+	for _, s := range subjects {
+		// The API group is not reported, subjects differing only in it are
+		// the same series.
+		s.APIGroup = ""
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		unique = append(unique, s)
+	}
+	return unique
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockRoleBindingStore struct {
+	list func() ([]rbacv1.RoleBinding, error)
+}
+
+func (rs mockRoleBindingStore) List() ([]rbacv1.RoleBinding, error) {
+	return rs.list()
+}
+
+func TestRoleBindingCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_rolebinding_info Information about rolebinding.
+		# TYPE kube_rolebinding_info gauge
+		# HELP kube_rolebinding_created Unix creation timestamp
+		# TYPE kube_rolebinding_created gauge
+		# HELP kube_rolebinding_subject Subject the rolebinding grants the referenced role to.
+		# TYPE kube_rolebinding_subject gauge
+		# HELP kube_rolebinding_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_rolebinding_labels gauge
+		# HELP kube_rolebinding_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_rolebinding_annotations gauge
+	`
+	cases := []struct {
+		roleBindings []rbacv1.RoleBinding
+		metrics      []string // which metrics should be checked
+		want         string
+	}{
+		{
+			roleBindings: []rbacv1.RoleBinding{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "read-pods",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Namespace:         "ns1",
+						Labels: map[string]string{
+							"team": "web",
+						},
+					},
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "pod-reader"},
+					Subjects: []rbacv1.Subject{
+						{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "jane"},
+						{Kind: rbacv1.ServiceAccountKind, Name: "builder", Namespace: "ci"},
+						// Repeated subjects are reported once.
+						{Kind: rbacv1.UserKind, Name: "jane"},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "admins",
+						Namespace: "ns2",
+					},
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+				},
+			},
+			want: metadata + `
+				kube_rolebinding_created{namespace="ns1",rolebinding="read-pods"} 1.5e+09
+				kube_rolebinding_info{namespace="ns1",role_kind="Role",role_name="pod-reader",rolebinding="read-pods"} 1
+				kube_rolebinding_info{namespace="ns2",role_kind="ClusterRole",role_name="admin",rolebinding="admins"} 1
+				kube_rolebinding_labels{label_team="web",namespace="ns1",rolebinding="read-pods"} 1
+				kube_rolebinding_labels{namespace="ns2",rolebinding="admins"} 1
+				kube_rolebinding_subject{namespace="ns1",role_kind="Role",role_name="pod-reader",rolebinding="read-pods",subject_kind="ServiceAccount",subject_name="builder",subject_namespace="ci"} 1
+				kube_rolebinding_subject{namespace="ns1",role_kind="Role",role_name="pod-reader",rolebinding="read-pods",subject_kind="User",subject_name="jane",subject_namespace=""} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		rbc := &roleBindingCollector{
+			store: &mockRoleBindingStore{
+				list: func() ([]rbacv1.RoleBinding, error) {
+					return c.roleBindings, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(rbc, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
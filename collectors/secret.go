@@ -19,11 +19,9 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,6 +29,10 @@ var (
 	descSecretLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descSecretLabelsDefaultLabels = []string{"namespace", "secret"}
 
+	descSecretAnnotationsName          = "kube_secret_annotations"
+	descSecretAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descSecretAnnotationsDefaultLabels = []string{"namespace", "secret"}
+
 	descSecretInfo = prometheus.NewDesc(
 		"kube_secret_info",
 		"Information about secret.",
@@ -49,6 +51,12 @@ var (
 		descSecretLabelsDefaultLabels, nil,
 	)
 
+	descSecretAnnotations = prometheus.NewDesc(
+		descSecretAnnotationsName,
+		descSecretAnnotationsHelp,
+		descSecretAnnotationsDefaultLabels, nil,
+	)
+
 	descSecretCreated = prometheus.NewDesc(
 		"kube_secret_created",
 		"Unix creation timestamp",
@@ -68,21 +76,38 @@ func (l SecretLister) List() ([]v1.Secret, error) {
 	return l()
 }
 
-func RegisterSecretCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterSecretCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect secret with %s", client.APIVersion())
-	slw := cache.NewListWatchFromClient(client, "secrets", namespace, fields.Everything())
-	sinf := cache.NewSharedInformer(slw, &v1.Secret{}, resyncPeriod)
+	sinfs := NewSharedInformerList(client, "secrets", namespaces, &v1.Secret{}, opts)
+	sinfs.SetTransform(func(obj runtime.Object) {
+		stripSecret(obj.(*v1.Secret), opts)
+	})
 
 	secretLister := SecretLister(func() (secrets []v1.Secret, err error) {
-		for _, m := range sinf.GetStore().List() {
-			secrets = append(secrets, *m.(*v1.Secret))
+		for _, sinf := range *sinfs {
+			for _, m := range sinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				secrets = append(secrets, *m.(*v1.Secret))
+			}
 		}
 		return secrets, nil
 	})
 
-	registry.MustRegister(&secretCollector{store: secretLister})
-	go sinf.Run(context.Background().Done())
+	sc := &secretCollector{store: secretLister, opts: opts}
+	registry.mustRegister("secret", sc, sinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		sc.collectSecret(ch, *obj.(*v1.Secret))
+	}, opts)
+}
+
+// stripSecret drops the data of the secret and the labels and annotations
+// that are not exported, so that they are never cached.
+func stripSecret(s *v1.Secret, opts *Options) {
+	s.Data = nil
+	s.StringData = nil
+	opts.stripMetadata("secrets", &s.ObjectMeta)
 }
 
 type secretStore interface {
@@ -92,6 +117,7 @@ type secretStore interface {
 // secretCollector collects metrics about all secrets in the cluster.
 type secretCollector struct {
 	store secretStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -99,6 +125,7 @@ func (sc *secretCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descSecretInfo
 	ch <- descSecretCreated
 	ch <- descSecretLabels
+	ch <- descSecretAnnotations
 	ch <- descSecretMetadataResourceVersion
 	ch <- descSecretType
 }
@@ -130,8 +157,20 @@ func secretLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func secretAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descSecretAnnotationsName,
+		descSecretAnnotationsHelp,
+		append(descSecretAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (sc *secretCollector) collectSecret(ch chan<- prometheus.Metric, s v1.Secret) {
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !sc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{s.Namespace, s.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
 	}
@@ -144,8 +183,13 @@ func (sc *secretCollector) collectSecret(ch chan<- prometheus.Metric, s v1.Secre
 	if !s.CreationTimestamp.IsZero() {
 		addGauge(descSecretCreated, float64(s.CreationTimestamp.Unix()))
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(s.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(sc.opts.allowedLabels("secrets", s.Labels))
 	addGauge(secretLabelsDesc(labelKeys), 1, labelValues...)
 
+	if annotations, ok := sc.opts.allowedAnnotations("secrets", s.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(secretAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+
 	addGauge(descSecretMetadataResourceVersion, 1, string(s.ObjectMeta.ResourceVersion))
 }
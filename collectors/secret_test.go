@@ -49,6 +49,8 @@ func TestSecretCollector(t *testing.T) {
 		# TYPE kube_secret_created gauge
 		# HELP kube_secret_metadata_resource_version Resource version representing a specific version of secret.
 		# TYPE kube_secret_metadata_resource_version gauge
+		# HELP kube_secret_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_secret_annotations gauge
 	`
 	cases := []struct {
 		secrets []v1.Secret
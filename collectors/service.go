@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,6 +28,10 @@ var (
 	descServiceLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descServiceLabelsDefaultLabels = []string{"namespace", "service"}
 
+	descServiceAnnotationsName          = "kube_service_annotations"
+	descServiceAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descServiceAnnotationsDefaultLabels = []string{"namespace", "service"}
+
 	descServiceInfo = prometheus.NewDesc(
 		"kube_service_info",
 		"Information about service.",
@@ -54,6 +55,12 @@ var (
 		descServiceLabelsHelp,
 		descServiceLabelsDefaultLabels, nil,
 	)
+
+	descServiceAnnotations = prometheus.NewDesc(
+		descServiceAnnotationsName,
+		descServiceAnnotationsHelp,
+		descServiceAnnotationsDefaultLabels, nil,
+	)
 )
 
 type ServiceLister func() ([]v1.Service, error)
@@ -62,21 +69,27 @@ func (l ServiceLister) List() ([]v1.Service, error) {
 	return l()
 }
 
-func RegisterServiceCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
+func RegisterServiceCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
 	client := kubeClient.CoreV1().RESTClient()
 	glog.Infof("collect service with %s", client.APIVersion())
-	slw := cache.NewListWatchFromClient(client, "services", namespace, fields.Everything())
-	sinf := cache.NewSharedInformer(slw, &v1.Service{}, resyncPeriod)
+	sinfs := NewSharedInformerList(client, "services", namespaces, &v1.Service{}, opts)
 
 	serviceLister := ServiceLister(func() (services []v1.Service, err error) {
-		for _, m := range sinf.GetStore().List() {
-			services = append(services, *m.(*v1.Service))
+		for _, sinf := range *sinfs {
+			for _, m := range sinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				services = append(services, *m.(*v1.Service))
+			}
 		}
 		return services, nil
 	})
 
-	registry.MustRegister(&serviceCollector{store: serviceLister})
-	go sinf.Run(context.Background().Done())
+	sc := &serviceCollector{store: serviceLister, opts: opts}
+	registry.mustRegister("service", sc, sinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		sc.collectService(ch, *obj.(*v1.Service))
+	}, opts)
 }
 
 type serviceStore interface {
@@ -86,12 +99,14 @@ type serviceStore interface {
 // serviceCollector collects metrics about all services in the cluster.
 type serviceCollector struct {
 	store serviceStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
 func (pc *serviceCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descServiceInfo
 	ch <- descServiceLabels
+	ch <- descServiceAnnotations
 	ch <- descServiceCreated
 	ch <- descServiceSpecType
 }
@@ -122,8 +137,20 @@ func serviceLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func serviceAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descServiceAnnotationsName,
+		descServiceAnnotationsHelp,
+		append(descServiceAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (sc *serviceCollector) collectService(ch chan<- prometheus.Metric, s v1.Service) {
 	addConstMetric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64, lv ...string) {
+		if !sc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{s.Namespace, s.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, t, v, lv...)
 	}
@@ -136,6 +163,11 @@ func (sc *serviceCollector) collectService(ch chan<- prometheus.Metric, s v1.Ser
 	if !s.CreationTimestamp.IsZero() {
 		addGauge(descServiceCreated, float64(s.CreationTimestamp.Unix()))
 	}
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(s.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(sc.opts.allowedLabels("services", s.Labels))
 	addGauge(serviceLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := sc.opts.allowedAnnotations("services", s.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(serviceAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 }
@@ -44,6 +44,8 @@ func TestServiceCollector(t *testing.T) {
 		# TYPE kube_service_labels gauge
 		# HELP kube_service_spec_type Type about service.
 		# TYPE kube_service_spec_type gauge
+		# HELP kube_service_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_service_annotations gauge
 	`
 	cases := []struct {
 		services []v1.Service
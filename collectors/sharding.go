@@ -0,0 +1,45 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"hash/fnv"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// owns reports whether the metrics of obj are exposed by this shard. Every
+// object belongs to exactly one shard, determined by the hash of its UID.
+func (o *Options) owns(obj interface{}) bool {
+	if o == nil || o.TotalShards <= 1 {
+		return true
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		glog.Errorf("sharding object of type %T failed: %s", obj, err)
+		return false
+	}
+	return shardForUID(m.GetUID(), o.TotalShards) == o.Shard
+}
+
+func shardForUID(uid types.UID, totalShards int) int32 {
+	h := fnv.New64a()
+	h.Write([]byte(uid))
+	return int32(h.Sum64() % uint64(totalShards))
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOptionsOwns(t *testing.T) {
+	const totalShards = 3
+
+	var pods []*v1.Pod
+	for i := 0; i < 1000; i++ {
+		pods = append(pods, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fmt.Sprintf("pod%d", i),
+				UID:  types.UID(fmt.Sprintf("6d4b1ba4-0f6a-11e8-b3d5-%012d", i)),
+			},
+		})
+	}
+
+	owners := make(map[string]int)
+	for shard := int32(0); shard < totalShards; shard++ {
+		opts := &Options{Shard: shard, TotalShards: totalShards}
+		owned := 0
+		for _, p := range pods {
+			if opts.owns(p) {
+				owners[p.Name]++
+				owned++
+			}
+		}
+		if owned == 0 {
+			t.Errorf("shard %d does not own any of the %d pods", shard, len(pods))
+		}
+	}
+
+	for _, p := range pods {
+		if owners[p.Name] != 1 {
+			t.Errorf("pod %s is owned by %d shards, want exactly 1", p.Name, owners[p.Name])
+		}
+	}
+
+	for _, opts := range []*Options{nil, {}, {Shard: 0, TotalShards: 1}} {
+		for _, p := range pods {
+			if !opts.owns(p) {
+				t.Fatalf("pod %s not owned by %+v, want all pods to be owned without sharding", p.Name, opts)
+			}
+		}
+	}
+}
@@ -19,11 +19,8 @@ package collectors
 import (
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"k8s.io/api/apps/v1beta1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -31,6 +28,10 @@ var (
 	descStatefulSetLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
 	descStatefulSetLabelsDefaultLabels = []string{"namespace", "statefulset"}
 
+	descStatefulSetAnnotationsName          = "kube_statefulset_annotations"
+	descStatefulSetAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descStatefulSetAnnotationsDefaultLabels = []string{"namespace", "statefulset"}
+
 	descStatefulSetCreated = prometheus.NewDesc(
 		"kube_statefulset_created",
 		"Unix creation timestamp",
@@ -84,6 +85,12 @@ var (
 		descStatefulSetLabelsHelp,
 		descStatefulSetLabelsDefaultLabels, nil,
 	)
+
+	descStatefulSetAnnotations = prometheus.NewDesc(
+		descStatefulSetAnnotationsName,
+		descStatefulSetAnnotationsHelp,
+		descStatefulSetAnnotationsDefaultLabels, nil,
+	)
 )
 
 type StatefulSetLister func() ([]v1beta1.StatefulSet, error)
@@ -92,21 +99,29 @@ func (l StatefulSetLister) List() ([]v1beta1.StatefulSet, error) {
 	return l()
 }
 
-func RegisterStatefulSetCollector(registry prometheus.Registerer, kubeClient kubernetes.Interface, namespace string) {
-	client := kubeClient.AppsV1beta1().RESTClient()
-	glog.Infof("collect statefulset with %s", client.APIVersion())
-	dlw := cache.NewListWatchFromClient(client, "statefulsets", namespace, fields.Everything())
-	dinf := cache.NewSharedInformer(dlw, &v1beta1.StatefulSet{}, resyncPeriod)
+func RegisterStatefulSetCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	dinfs, err := newVersionedInformerList(kubeClient, "statefulsets", namespaces, &v1beta1.StatefulSet{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch statefulsets: %v", err)
+		return
+	}
 
 	statefulSetLister := StatefulSetLister(func() (statefulSets []v1beta1.StatefulSet, err error) {
-		for _, c := range dinf.GetStore().List() {
-			statefulSets = append(statefulSets, *(c.(*v1beta1.StatefulSet)))
+		for _, dinf := range *dinfs {
+			for _, c := range dinf.GetStore().List() {
+				if !opts.owns(c) {
+					continue
+				}
+				statefulSets = append(statefulSets, *(c.(*v1beta1.StatefulSet)))
+			}
 		}
 		return statefulSets, nil
 	})
 
-	registry.MustRegister(&statefulSetCollector{store: statefulSetLister})
-	go dinf.Run(context.Background().Done())
+	dc := &statefulSetCollector{store: statefulSetLister, opts: opts}
+	registry.mustRegister("statefulset", dc, dinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		dc.collectStatefulSet(ch, *obj.(*v1beta1.StatefulSet))
+	}, opts)
 }
 
 type statefulSetStore interface {
@@ -115,6 +130,7 @@ type statefulSetStore interface {
 
 type statefulSetCollector struct {
 	store statefulSetStore
+	opts  *Options
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -128,6 +144,7 @@ func (dc *statefulSetCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- descStatefulSetSpecReplicas
 	ch <- descStatefulSetMetadataGeneration
 	ch <- descStatefulSetLabels
+	ch <- descStatefulSetAnnotations
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -157,8 +174,20 @@ func statefulSetLabelsDesc(labelKeys []string) *prometheus.Desc {
 	)
 }
 
+func statefulSetAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descStatefulSetAnnotationsName,
+		descStatefulSetAnnotationsHelp,
+		append(descStatefulSetAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
 func (dc *statefulSetCollector) collectStatefulSet(ch chan<- prometheus.Metric, statefulSet v1beta1.StatefulSet) {
 	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !dc.opts.allows(desc) {
+			return
+		}
 		lv = append([]string{statefulSet.Namespace, statefulSet.Name}, lv...)
 		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
 	}
@@ -178,6 +207,11 @@ func (dc *statefulSetCollector) collectStatefulSet(ch chan<- prometheus.Metric,
 	}
 	addGauge(descStatefulSetMetadataGeneration, float64(statefulSet.ObjectMeta.Generation))
 
-	labelKeys, labelValues := kubeLabelsToPrometheusLabels(statefulSet.Labels)
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(dc.opts.allowedLabels("statefulsets", statefulSet.Labels))
 	addGauge(statefulSetLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := dc.opts.allowedAnnotations("statefulsets", statefulSet.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(statefulSetAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
 }
@@ -63,6 +63,8 @@ func TestStatefuleSetCollector(t *testing.T) {
  		# TYPE kube_statefulset_metadata_generation gauge
 		# HELP kube_statefulset_labels Kubernetes labels converted to Prometheus labels.
 		# TYPE kube_statefulset_labels gauge
+		# HELP kube_statefulset_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_statefulset_annotations gauge
  	`
 	cases := []struct {
 		depls []v1beta1.StatefulSet
@@ -0,0 +1,210 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// isDefaultStorageClassAnnotation marks the default storage class of a
+	// cluster, betaIsDefaultStorageClassAnnotation is its older beta form.
+	isDefaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaIsDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+var (
+	descStorageClassLabelsName          = "kube_storageclass_labels"
+	descStorageClassLabelsHelp          = "Kubernetes labels converted to Prometheus labels."
+	descStorageClassLabelsDefaultLabels = []string{"storageclass"}
+
+	descStorageClassAnnotationsName          = "kube_storageclass_annotations"
+	descStorageClassAnnotationsHelp          = "Kubernetes annotations converted to Prometheus labels."
+	descStorageClassAnnotationsDefaultLabels = []string{"storageclass"}
+
+	descStorageClassInfo = prometheus.NewDesc(
+		"kube_storageclass_info",
+		"Information about storageclass.",
+		[]string{"storageclass", "provisioner", "reclaim_policy", "volume_binding_mode"}, nil,
+	)
+
+	descStorageClassCreated = prometheus.NewDesc(
+		"kube_storageclass_created",
+		"Unix creation timestamp",
+		[]string{"storageclass"}, nil,
+	)
+
+	descStorageClassDefault = prometheus.NewDesc(
+		"kube_storageclass_default",
+		"Whether the storageclass is marked as the default storageclass of the cluster.",
+		[]string{"storageclass"}, nil,
+	)
+
+	descStorageClassAllowVolumeExpansion = prometheus.NewDesc(
+		"kube_storageclass_allow_volume_expansion",
+		"Whether volumes of the storageclass can be expanded.",
+		[]string{"storageclass"}, nil,
+	)
+
+	descStorageClassLabels = prometheus.NewDesc(
+		descStorageClassLabelsName,
+		descStorageClassLabelsHelp,
+		descStorageClassLabelsDefaultLabels, nil,
+	)
+
+	descStorageClassAnnotations = prometheus.NewDesc(
+		descStorageClassAnnotationsName,
+		descStorageClassAnnotationsHelp,
+		descStorageClassAnnotationsDefaultLabels, nil,
+	)
+)
+
+type StorageClassLister func() ([]storagev1.StorageClass, error)
+
+func (l StorageClassLister) List() ([]storagev1.StorageClass, error) {
+	return l()
+}
+
+func RegisterStorageClassCollector(registry *Registry, kubeClient kubernetes.Interface, namespaces []string, opts *Options) {
+	scinfs, err := newVersionedInformerList(kubeClient, "storageclasses", []string{v1.NamespaceAll}, &storagev1.StorageClass{}, opts)
+	if err != nil {
+		glog.Errorf("Failed to watch storageclasses: %v", err)
+		return
+	}
+
+	storageClassLister := StorageClassLister(func() (scs []storagev1.StorageClass, err error) {
+		for _, scinf := range *scinfs {
+			for _, m := range scinf.GetStore().List() {
+				if !opts.owns(m) {
+					continue
+				}
+				scs = append(scs, *m.(*storagev1.StorageClass))
+			}
+		}
+		return scs, nil
+	})
+
+	collector := &storageClassCollector{store: storageClassLister, opts: opts}
+	registry.mustRegister("storageclass", collector, scinfs, func(obj interface{}, ch chan<- prometheus.Metric) {
+		collector.collectStorageClass(ch, *obj.(*storagev1.StorageClass))
+	}, opts)
+}
+
+type storageClassStore interface {
+	List() ([]storagev1.StorageClass, error)
+}
+
+// storageClassCollector collects metrics about all storage classes in the
+// cluster.
+type storageClassCollector struct {
+	store storageClassStore
+	opts  *Options
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *storageClassCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descStorageClassInfo
+	ch <- descStorageClassCreated
+	ch <- descStorageClassDefault
+	ch <- descStorageClassAllowVolumeExpansion
+	ch <- descStorageClassLabels
+	ch <- descStorageClassAnnotations
+}
+
+func storageClassLabelsDesc(labelKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descStorageClassLabelsName,
+		descStorageClassLabelsHelp,
+		append(descStorageClassLabelsDefaultLabels, labelKeys...),
+		nil,
+	)
+}
+
+func storageClassAnnotationsDesc(annotationKeys []string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		descStorageClassAnnotationsName,
+		descStorageClassAnnotationsHelp,
+		append(descStorageClassAnnotationsDefaultLabels, annotationKeys...),
+		nil,
+	)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *storageClassCollector) Collect(ch chan<- prometheus.Metric) {
+	scs, err := collector.store.List()
+	if err != nil {
+		ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "storageclass"}).Inc()
+		glog.Errorf("listing storageclasses failed: %s", err)
+		return
+	}
+	ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "storageclass"}).Add(0)
+
+	ResourcesPerScrapeMetric.With(prometheus.Labels{"resource": "storageclass"}).Observe(float64(len(scs)))
+	for _, sc := range scs {
+		collector.collectStorageClass(ch, sc)
+	}
+
+	glog.V(4).Infof("collected %d storageclasses", len(scs))
+}
+
+func (collector *storageClassCollector) collectStorageClass(ch chan<- prometheus.Metric, sc storagev1.StorageClass) {
+	addGauge := func(desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.opts.allows(desc) {
+			return
+		}
+		lv = append([]string{sc.Name}, lv...)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	// Unset policies are defaulted by the API server, the defaults are
+	// reported for objects read without defaulting.
+	reclaimPolicy := v1.PersistentVolumeReclaimDelete
+	if sc.ReclaimPolicy != nil {
+		reclaimPolicy = *sc.ReclaimPolicy
+	}
+	bindingMode := storagev1.VolumeBindingImmediate
+	if sc.VolumeBindingMode != nil {
+		bindingMode = *sc.VolumeBindingMode
+	}
+	addGauge(descStorageClassInfo, 1, sc.Provisioner, string(reclaimPolicy), string(bindingMode))
+	if !sc.CreationTimestamp.IsZero() {
+		addGauge(descStorageClassCreated, float64(sc.CreationTimestamp.Unix()))
+	}
+	addGauge(descStorageClassDefault, boolFloat64(isDefaultStorageClass(sc)))
+	addGauge(descStorageClassAllowVolumeExpansion, boolFloat64(sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion))
+
+	labelKeys, labelValues := kubeLabelsToPrometheusLabels(collector.opts.allowedLabels("storageclasses", sc.Labels))
+	addGauge(storageClassLabelsDesc(labelKeys), 1, labelValues...)
+
+	if annotations, ok := collector.opts.allowedAnnotations("storageclasses", sc.Annotations); ok {
+		annotationKeys, annotationValues := kubeAnnotationsToPrometheusAnnotations(annotations)
+		addGauge(storageClassAnnotationsDesc(annotationKeys), 1, annotationValues...)
+	}
+}
+
+// isDefaultStorageClass returns whether sc is marked as default class, by
+// the GA or the beta annotation.
+func isDefaultStorageClass(sc storagev1.StorageClass) bool {
+	if sc.Annotations[isDefaultStorageClassAnnotation] == "true" {
+		return true
+	}
+	return sc.Annotations[betaIsDefaultStorageClassAnnotation] == "true"
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockStorageClassStore struct {
+	list func() ([]storagev1.StorageClass, error)
+}
+
+func (ss mockStorageClassStore) List() ([]storagev1.StorageClass, error) {
+	return ss.list()
+}
+
+func TestStorageClassCollector(t *testing.T) {
+	// Fixed metadata on type and help text. We prepend this to every expected
+	// output so we only have to modify a single place when doing adjustments.
+	const metadata = `
+		# HELP kube_storageclass_info Information about storageclass.
+		# TYPE kube_storageclass_info gauge
+		# HELP kube_storageclass_created Unix creation timestamp
+		# TYPE kube_storageclass_created gauge
+		# HELP kube_storageclass_default Whether the storageclass is marked as the default storageclass of the cluster.
+		# TYPE kube_storageclass_default gauge
+		# HELP kube_storageclass_allow_volume_expansion Whether volumes of the storageclass can be expanded.
+		# TYPE kube_storageclass_allow_volume_expansion gauge
+		# HELP kube_storageclass_labels Kubernetes labels converted to Prometheus labels.
+		# TYPE kube_storageclass_labels gauge
+		# HELP kube_storageclass_annotations Kubernetes annotations converted to Prometheus labels.
+		# TYPE kube_storageclass_annotations gauge
+	`
+	retain := v1.PersistentVolumeReclaimRetain
+	waitForFirstConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+	allowExpansion := true
+	cases := []struct {
+		classes []storagev1.StorageClass
+		metrics []string // which metrics should be checked
+		want    string
+	}{
+		{
+			classes: []storagev1.StorageClass{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "standard",
+						CreationTimestamp: metav1.Time{Time: time.Unix(1500000000, 0)},
+						Labels: map[string]string{
+							"tier": "default",
+						},
+						Annotations: map[string]string{
+							isDefaultStorageClassAnnotation: "true",
+						},
+					},
+					Provisioner: "kubernetes.io/gce-pd",
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "legacy",
+						Annotations: map[string]string{
+							betaIsDefaultStorageClassAnnotation: "true",
+						},
+					},
+					Provisioner: "kubernetes.io/aws-ebs",
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local",
+						Annotations: map[string]string{
+							isDefaultStorageClassAnnotation: "false",
+						},
+					},
+					Provisioner:          "kubernetes.io/no-provisioner",
+					ReclaimPolicy:        &retain,
+					VolumeBindingMode:    &waitForFirstConsumer,
+					AllowVolumeExpansion: &allowExpansion,
+				},
+			},
+			want: metadata + `
+				kube_storageclass_info{provisioner="kubernetes.io/aws-ebs",reclaim_policy="Delete",storageclass="legacy",volume_binding_mode="Immediate"} 1
+				kube_storageclass_info{provisioner="kubernetes.io/gce-pd",reclaim_policy="Delete",storageclass="standard",volume_binding_mode="Immediate"} 1
+				kube_storageclass_info{provisioner="kubernetes.io/no-provisioner",reclaim_policy="Retain",storageclass="local",volume_binding_mode="WaitForFirstConsumer"} 1
+				kube_storageclass_created{storageclass="standard"} 1.5e+09
+				kube_storageclass_default{storageclass="legacy"} 1
+				kube_storageclass_default{storageclass="local"} 0
+				kube_storageclass_default{storageclass="standard"} 1
+				kube_storageclass_allow_volume_expansion{storageclass="legacy"} 0
+				kube_storageclass_allow_volume_expansion{storageclass="local"} 1
+				kube_storageclass_allow_volume_expansion{storageclass="standard"} 0
+				kube_storageclass_labels{label_tier="default",storageclass="standard"} 1
+				kube_storageclass_labels{storageclass="legacy"} 1
+				kube_storageclass_labels{storageclass="local"} 1
+			`,
+		},
+	}
+	for _, c := range cases {
+		collector := &storageClassCollector{
+			store: &mockStorageClassStore{
+				list: func() ([]storagev1.StorageClass, error) {
+					return c.classes, nil
+				},
+			},
+		}
+		if err := gatherAndCompare(collector, c.want, c.metrics); err != nil {
+			t.Errorf("unexpected collecting result:\n%s", err)
+		}
+	}
+}
@@ -0,0 +1,633 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"k8s.io/kube-state-metrics/builder"
+	kcollectors "k8s.io/kube-state-metrics/collectors"
+)
+
+// configCheckInterval is how often the config file is checked for changes.
+const configCheckInterval = 10 * time.Second
+
+// Reasons reported by ksm_collector_enabled for configured collectors that
+// are not enabled.
+const (
+	reasonNotServed       = "not_served"
+	reasonMetricsExcluded = "metrics_excluded"
+	reasonClientError     = "client_error"
+)
+
+// config holds the settings of kube-state-metrics. It is built from the
+// flags, and settings present in the --config file take precedence over
+// them.
+type config struct {
+	Apiserver           string                     `json:"apiserver"`
+	Kubeconfig          string                     `json:"kubeconfig"`
+	KubeAPIQPS          float32                    `json:"kubeAPIQPS"`
+	KubeAPIBurst        int                        `json:"kubeAPIBurst"`
+	KubeAPITimeout      duration                   `json:"kubeAPITimeout"`
+	KubeAPIContentType  string                     `json:"kubeAPIContentType"`
+	KubeAPIPageSize     int64                      `json:"kubeAPIPageSize"`
+	Host                string                     `json:"host"`
+	Port                int                        `json:"port"`
+	TelemetryHost       string                     `json:"telemetryHost"`
+	TelemetryPort       int                        `json:"telemetryPort"`
+	Namespaces          []string                   `json:"namespaces"`
+	MetricAllowlist     []string                   `json:"metricAllowlist"`
+	MetricDenylist      []string                   `json:"metricDenylist"`
+	Shard               int32                      `json:"shard"`
+	TotalShards         int                        `json:"totalShards"`
+	WatchFailureTimeout duration                   `json:"watchFailureTimeout"`
+	EventSeriesTTL      duration                   `json:"eventSeriesTTL"`
+	MaxSeriesPerFamily  int                        `json:"maxSeriesPerFamily"`
+	TLSCertFile         string                     `json:"tlsCertFile"`
+	TLSPrivateKeyFile   string                     `json:"tlsPrivateKeyFile"`
+	ClientCAFile        string                     `json:"clientCAFile"`
+	AuthTokenReview     bool                       `json:"authTokenReview"`
+	AuthNonResourceURL  string                     `json:"authNonResourceURL"`
+	Collectors          map[string]collectorConfig `json:"collectors"`
+	// CustomResources declares collectors for custom resources.
+	CustomResources []customResourceConfig `json:"customResources"`
+}
+
+// collectorConfig holds the settings of a single collector.
+type collectorConfig struct {
+	// Namespaces overrides the namespaces watched by the collector.
+	Namespaces    []string `json:"namespaces"`
+	LabelSelector string   `json:"labelSelector"`
+	FieldSelector string   `json:"fieldSelector"`
+	// LabelsAllowlist restricts the exported Kubernetes labels, all labels
+	// are exported if it is not set.
+	LabelsAllowlist []string `json:"labelsAllowlist"`
+	// AnnotationsAllowlist selects the exported Kubernetes annotations, no
+	// annotations are exported if it is not set.
+	AnnotationsAllowlist []string `json:"annotationsAllowlist"`
+}
+
+// customResourceConfig declares a collector for a custom resource. The
+// collector is named after the plural resource name.
+type customResourceConfig struct {
+	kcollectors.CustomResourceConfig
+	// Namespaces overrides the namespaces watched by the collector.
+	Namespaces    []string `json:"namespaces"`
+	LabelSelector string   `json:"labelSelector"`
+	FieldSelector string   `json:"fieldSelector"`
+}
+
+// duration is a time.Duration written as a string like "5m" in the config
+// file.
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// configFromOptions builds the config set by the flags.
+func configFromOptions(options *options, collectors collectorSet, namespaces namespaceList) config {
+	c := config{
+		Apiserver:           options.apiserver,
+		Kubeconfig:          options.kubeconfig,
+		KubeAPIQPS:          options.apiQPS,
+		KubeAPIBurst:        options.apiBurst,
+		KubeAPITimeout:      duration{options.apiTimeout},
+		KubeAPIContentType:  options.apiContentType,
+		KubeAPIPageSize:     options.apiPageSize,
+		Host:                options.host,
+		Port:                options.port,
+		TelemetryHost:       options.telemetryHost,
+		TelemetryPort:       options.telemetryPort,
+		Namespaces:          namespaces,
+		MetricAllowlist:     splitList(options.metricAllow),
+		MetricDenylist:      splitList(options.metricDeny),
+		Shard:               options.shard,
+		TotalShards:         options.totalShards,
+		WatchFailureTimeout: duration{options.watchTimeout},
+		EventSeriesTTL:      duration{options.eventSeriesTTL},
+		MaxSeriesPerFamily:  options.maxSeries,
+		TLSCertFile:         options.tlsCertFile,
+		TLSPrivateKeyFile:   options.tlsKeyFile,
+		ClientCAFile:        options.clientCAFile,
+		AuthTokenReview:     options.authTokenReview,
+		AuthNonResourceURL:  options.authURL,
+		Collectors:          map[string]collectorConfig{},
+	}
+	for name := range collectors {
+		cc := collectorConfig{
+			LabelSelector:        options.labelSelectors.selectors[name],
+			FieldSelector:        options.fieldSelectors.selectors[name],
+			AnnotationsAllowlist: options.annotationsAllow[name],
+		}
+		if options.labelsAllow != nil {
+			cc.LabelsAllowlist = options.labelsAllow[name]
+			if cc.LabelsAllowlist == nil {
+				cc.LabelsAllowlist = []string{}
+			}
+		}
+		c.Collectors[name] = cc
+	}
+	return c
+}
+
+// loadConfig reads the config file at path on top of base.
+func loadConfig(path string, base config) (config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config{}, err
+	}
+	return parseConfig(path, data, base)
+}
+
+// parseConfig parses the config file content data on top of base. Lists and
+// the collectors section in the file replace those of base as a whole.
+func parseConfig(path string, data []byte, base config) (config, error) {
+	c := base
+	c.Namespaces = nil
+	c.MetricAllowlist = nil
+	c.MetricDenylist = nil
+	c.Collectors = nil
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return config{}, fmt.Errorf("parsing %s failed: %v", path, err)
+	}
+	if c.Namespaces == nil {
+		c.Namespaces = base.Namespaces
+	}
+	if c.MetricAllowlist == nil {
+		c.MetricAllowlist = base.MetricAllowlist
+	}
+	if c.MetricDenylist == nil {
+		c.MetricDenylist = base.MetricDenylist
+	}
+	if c.Collectors == nil {
+		c.Collectors = base.Collectors
+	}
+	if c.CustomResources == nil {
+		c.CustomResources = base.CustomResources
+	}
+
+	if err := c.validate(); err != nil {
+		return config{}, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func (c config) validate() error {
+	if c.TotalShards < 1 {
+		return fmt.Errorf("total shards must be at least 1, got %d", c.TotalShards)
+	}
+	if c.Shard < 0 || int(c.Shard) >= c.TotalShards {
+		return fmt.Errorf("shard must be between 0 and %d, got %d", c.TotalShards-1, c.Shard)
+	}
+	if c.KubeAPIQPS <= 0 || c.KubeAPIBurst <= 0 {
+		return fmt.Errorf("the apiserver QPS and burst must be positive, got %v and %d", c.KubeAPIQPS, c.KubeAPIBurst)
+	}
+	if c.KubeAPITimeout.Duration < 0 {
+		return fmt.Errorf("the apiserver timeout must not be negative, got %s", c.KubeAPITimeout.Duration)
+	}
+	if c.KubeAPIContentType != contentTypeProtobuf && c.KubeAPIContentType != runtime.ContentTypeJSON {
+		return fmt.Errorf("the apiserver content type must be %s or %s, got %q", contentTypeProtobuf, runtime.ContentTypeJSON, c.KubeAPIContentType)
+	}
+	if c.KubeAPIPageSize <= 0 {
+		return fmt.Errorf("the apiserver page size must be positive, got %d", c.KubeAPIPageSize)
+	}
+	if c.EventSeriesTTL.Duration <= 0 {
+		return fmt.Errorf("the event series TTL must be positive, got %s", c.EventSeriesTTL.Duration)
+	}
+	if c.MaxSeriesPerFamily < 0 {
+		return fmt.Errorf("the series limit must not be negative, got %d", c.MaxSeriesPerFamily)
+	}
+	if (c.TLSCertFile == "") != (c.TLSPrivateKeyFile == "") {
+		return fmt.Errorf("the TLS certificate and private key must be set together")
+	}
+	if c.ClientCAFile != "" && c.TLSCertFile == "" {
+		return fmt.Errorf("verifying client certificates requires a TLS certificate")
+	}
+	if c.AuthTokenReview && c.AuthNonResourceURL == "" {
+		return fmt.Errorf("the non-resource URL to authorize requests for must be set")
+	}
+	if _, err := compileMetricList(c.MetricAllowlist); err != nil {
+		return fmt.Errorf("invalid metric allowlist: %v", err)
+	}
+	if _, err := compileMetricList(c.MetricDenylist); err != nil {
+		return fmt.Errorf("invalid metric denylist: %v", err)
+	}
+	for name, cc := range c.Collectors {
+		if _, ok := builder.AvailableCollectors[name]; !ok {
+			return fmt.Errorf("collector %q does not exist", name)
+		}
+		if _, err := labels.Parse(cc.LabelSelector); err != nil {
+			return fmt.Errorf("invalid label selector for collector %q: %v", name, err)
+		}
+		if _, err := fields.ParseSelector(cc.FieldSelector); err != nil {
+			return fmt.Errorf("invalid field selector for collector %q: %v", name, err)
+		}
+	}
+	names := map[string]bool{}
+	for i, cr := range c.CustomResources {
+		if err := cr.Validate(); err != nil {
+			return fmt.Errorf("invalid custom resource %d: %v", i, err)
+		}
+		name := cr.CollectorName()
+		if names[name] {
+			return fmt.Errorf("custom resource collector %q is already defined", name)
+		}
+		names[name] = true
+		if _, err := labels.Parse(cr.LabelSelector); err != nil {
+			return fmt.Errorf("invalid label selector for collector %q: %v", name, err)
+		}
+		if _, err := fields.ParseSelector(cr.FieldSelector); err != nil {
+			return fmt.Errorf("invalid field selector for collector %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// collectorSettings holds everything a collector is set up with. On reload
+// a collector is only restarted when its settings change.
+type collectorSettings struct {
+	namespaces      []string
+	collector       collectorConfig
+	customResource  *kcollectors.CustomResourceConfig
+	metricAllowlist []string
+	metricDenylist  []string
+	shard           int32
+	totalShards     int
+	pageSize        int64
+	listTimeout     time.Duration
+	eventSeriesTTL  time.Duration
+	maxSeries       int
+}
+
+func (c config) collectorSettings() map[string]collectorSettings {
+	settings := map[string]collectorSettings{}
+	for name, cc := range c.Collectors {
+		settings[name] = c.settings(cc)
+	}
+	for i := range c.CustomResources {
+		cr := c.CustomResources[i]
+		s := c.settings(collectorConfig{
+			Namespaces:    cr.Namespaces,
+			LabelSelector: cr.LabelSelector,
+			FieldSelector: cr.FieldSelector,
+		})
+		s.customResource = &cr.CustomResourceConfig
+		settings[cr.CollectorName()] = s
+	}
+	return settings
+}
+
+func (c config) settings(cc collectorConfig) collectorSettings {
+	namespaces := c.Namespaces
+	if len(cc.Namespaces) > 0 {
+		namespaces = cc.Namespaces
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return collectorSettings{
+		namespaces:      namespaces,
+		collector:       cc,
+		metricAllowlist: c.MetricAllowlist,
+		metricDenylist:  c.MetricDenylist,
+		shard:           c.Shard,
+		totalShards:     c.TotalShards,
+		pageSize:        c.KubeAPIPageSize,
+		listTimeout:     c.KubeAPITimeout.Duration,
+		eventSeriesTTL:  c.EventSeriesTTL.Duration,
+		maxSeries:       c.MaxSeriesPerFamily,
+	}
+}
+
+// options returns the options of the named collector.
+func (s collectorSettings) options(name string) (*kcollectors.Options, error) {
+	// The selectors of a custom resource are looked up by the plural
+	// resource name its informers watch.
+	if s.customResource != nil {
+		name = s.customResource.GroupVersionResource().Resource
+	}
+	metricAllowList, err := compileMetricList(s.metricAllowlist)
+	if err != nil {
+		return nil, err
+	}
+	metricDenyList, err := compileMetricList(s.metricDenylist)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &kcollectors.Options{
+		Shard:              s.shard,
+		TotalShards:        s.totalShards,
+		MetricAllowList:    metricAllowList,
+		MetricDenyList:     metricDenyList,
+		PageSize:           s.pageSize,
+		ListTimeout:        s.listTimeout,
+		EventSeriesTTL:     s.eventSeriesTTL,
+		MaxSeriesPerFamily: s.maxSeries,
+	}
+	if s.collector.LabelSelector != "" {
+		opts.LabelSelectors = map[string]string{name: s.collector.LabelSelector}
+	}
+	if s.collector.FieldSelector != "" {
+		opts.FieldSelectors = map[string]string{name: s.collector.FieldSelector}
+	}
+	if s.collector.LabelsAllowlist != nil {
+		opts.LabelsAllowList = map[string][]string{name: s.collector.LabelsAllowlist}
+	}
+	if s.collector.AnnotationsAllowlist != nil {
+		opts.AnnotationsAllowList = map[string][]string{name: s.collector.AnnotationsAllowlist}
+	}
+	return opts, nil
+}
+
+// reloader applies the config to the registry. It reloads the config file on
+// SIGHUP or when the file changes, and only restarts the collectors whose
+// settings changed.
+type reloader struct {
+	path       string
+	base       config
+	registry   *kcollectors.Registry
+	kubeClient clientset.Interface
+	// restConfig is used to create the clients of custom resource
+	// collectors.
+	restConfig *rest.Config
+	// discovery finds the served resources on every apply, so that
+	// collectors of resources added later are started. It is nil when no
+	// API server is used.
+	discovery discovery.ServerResourcesInterface
+	// collectors holds the register functions of the built-in collectors.
+	collectors map[string]kcollectors.RegisterFunc
+
+	mtx      sync.Mutex
+	current  config
+	settings map[string]collectorSettings
+	// apiResources are the discovered resources, nil if discovery never
+	// succeeded.
+	apiResources kcollectors.APIResources
+	// reasons holds why each configured collector is not enabled, an empty
+	// string for enabled collectors.
+	reasons map[string]string
+	// data is the config file content of the last reload attempt.
+	data []byte
+}
+
+func newReloader(path string, base config, registry *kcollectors.Registry, kubeClient clientset.Interface, restConfig *rest.Config, discoveryClient discovery.ServerResourcesInterface) *reloader {
+	return &reloader{
+		path:       path,
+		base:       base,
+		registry:   registry,
+		kubeClient: kubeClient,
+		restConfig: restConfig,
+		discovery:  discoveryClient,
+		collectors: builder.AvailableCollectors,
+		settings:   map[string]collectorSettings{},
+		reasons:    map[string]string{},
+	}
+}
+
+// apply enables, disables and restarts collectors to match c.
+func (r *reloader) apply(c config) error {
+	apiResources := r.discoverAPIResources()
+	settings := c.collectorSettings()
+	opts := map[string]*kcollectors.Options{}
+	for name, s := range settings {
+		o, err := s.options(name)
+		if err != nil {
+			return fmt.Errorf("collector %q: %v", name, err)
+		}
+		o.APIResources = apiResources
+		opts[name] = o
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.apiResources = apiResources
+
+	if r.current.Collectors != nil {
+		warnUnreloadable(r.current, c)
+	}
+
+	for name := range r.settings {
+		if _, ok := settings[name]; !ok {
+			r.registry.Disable(name)
+			delete(r.reasons, name)
+			glog.Infof("Disabled collector %s", name)
+		}
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := settings[name]
+		old, ok := r.settings[name]
+		// Collectors whose resource was not served are retried, the
+		// resource may have been added since.
+		if ok && reflect.DeepEqual(old, s) && r.reasons[name] != reasonNotServed {
+			continue
+		}
+		if r.apiResources != nil && !r.served(name, s) {
+			glog.Warningf("Skipping collector %s, the API server does not serve %s in any supported version", name, name)
+			r.registry.Disable(name)
+			r.reasons[name] = reasonNotServed
+			continue
+		}
+		var err error
+		register, o := r.collectors[name], opts[name]
+		registered := r.registry.Enable(name, func(registry *kcollectors.Registry) {
+			if s.customResource != nil {
+				err = kcollectors.RegisterCustomResourceCollector(registry, r.restConfig, s.namespaces, o, s.customResource)
+				return
+			}
+			register(registry, r.kubeClient, s.namespaces, o)
+		})
+		r.reasons[name] = ""
+		if err != nil {
+			glog.Errorf("Failed to create the client of collector %s: %v", name, err)
+			r.reasons[name] = reasonClientError
+		} else if !registered {
+			r.reasons[name] = reasonMetricsExcluded
+		}
+		if ok {
+			glog.Infof("Restarted collector %s", name)
+		}
+	}
+
+	kcollectors.CollectorEnabledMetric.Reset()
+	for name, reason := range r.reasons {
+		kcollectors.CollectorEnabledMetric.WithLabelValues(name, reason).Set(boolFloat64(reason == ""))
+	}
+
+	kcollectors.ShardOrdinalMetric.Set(float64(c.Shard))
+	kcollectors.TotalShardsMetric.Set(float64(c.TotalShards))
+
+	r.current = c
+	r.settings = settings
+	glog.Infof("Active collectors: %s", strings.Join(r.registry.Enabled(), ","))
+	return nil
+}
+
+// discoverAPIResources returns the resources served by the API server. If
+// discovery fails, the resources discovered before are returned.
+func (r *reloader) discoverAPIResources() kcollectors.APIResources {
+	r.mtx.Lock()
+	previous := r.apiResources
+	r.mtx.Unlock()
+	if r.discovery == nil {
+		return previous
+	}
+
+	apiResources, err := kcollectors.DiscoverAPIResources(r.discovery)
+	if err != nil {
+		glog.Warningf("Discovering the served API resources failed: %v", err)
+		if previous != nil {
+			return previous
+		}
+	}
+	if len(apiResources) == 0 {
+		glog.Warning("No API resources discovered, watching every resource in its default version")
+		return previous
+	}
+	return apiResources
+}
+
+// served reports whether the API server serves the resource of the named
+// collector.
+func (r *reloader) served(name string, s collectorSettings) bool {
+	if s.customResource != nil {
+		gvr := s.customResource.GroupVersionResource()
+		return r.apiResources.Serves(gvr.GroupVersion(), gvr.Resource)
+	}
+	_, served := r.apiResources.ServedVersion(name)
+	return served
+}
+
+// warnUnreloadable logs the settings that changed in c but only take effect
+// on restart.
+func warnUnreloadable(old, c config) {
+	if old.Apiserver != c.Apiserver || old.Kubeconfig != c.Kubeconfig {
+		glog.Warning("Changing the apiserver or kubeconfig requires a restart")
+	}
+	if old.KubeAPIQPS != c.KubeAPIQPS || old.KubeAPIBurst != c.KubeAPIBurst || old.KubeAPIContentType != c.KubeAPIContentType {
+		glog.Warning("Changing the apiserver QPS, burst or content type requires a restart")
+	}
+	if old.Host != c.Host || old.Port != c.Port || old.TelemetryHost != c.TelemetryHost || old.TelemetryPort != c.TelemetryPort {
+		glog.Warning("Changing the listen addresses requires a restart")
+	}
+	if old.TLSCertFile != c.TLSCertFile || old.TLSPrivateKeyFile != c.TLSPrivateKeyFile || old.ClientCAFile != c.ClientCAFile ||
+		old.AuthTokenReview != c.AuthTokenReview || old.AuthNonResourceURL != c.AuthNonResourceURL {
+		glog.Warning("Changing the TLS or authentication settings requires a restart")
+	}
+}
+
+// reload loads the config file and applies it. Unless force is set, nothing
+// is done if the file content did not change since the last attempt.
+func (r *reloader) reload(force bool) error {
+	data, err := ioutil.ReadFile(r.path)
+	if err == nil {
+		r.mtx.Lock()
+		unchanged := bytes.Equal(data, r.data)
+		r.data = data
+		r.mtx.Unlock()
+		if unchanged && !force {
+			return nil
+		}
+
+		var c config
+		c, err = parseConfig(r.path, data, r.base)
+		if err == nil {
+			err = r.apply(c)
+		}
+	}
+
+	if err != nil {
+		kcollectors.ConfigLastReloadSuccessfulMetric.Set(0)
+		return err
+	}
+	kcollectors.ConfigLastReloadSuccessfulMetric.Set(1)
+	kcollectors.ConfigLastReloadSuccessTimestampMetric.Set(float64(time.Now().Unix()))
+	glog.Infof("Loaded config %s", r.path)
+	return nil
+}
+
+// run reloads the config on SIGHUP and when the config file changes.
+func (r *reloader) run() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		force := false
+		select {
+		case <-hup:
+			force = true
+		case <-ticker.C:
+		}
+		if err := r.reload(force); err != nil {
+			glog.Errorf("Reloading config failed: %v", err)
+		}
+	}
+}
+
+// watchTimeout returns the watch failure timeout of the current config.
+func (r *reloader) watchTimeout() time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.current.WatchFailureTimeout.Duration
+}
+
+func boolFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
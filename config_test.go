@@ -0,0 +1,433 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+
+	kcollectors "k8s.io/kube-state-metrics/collectors"
+)
+
+// testOptions returns the options set by the flag defaults.
+func testOptions() *options {
+	return &options{
+		apiQPS:         5,
+		apiBurst:       10,
+		apiContentType: contentTypeProtobuf,
+		apiPageSize:    500,
+		eventSeriesTTL: time.Hour,
+		port:           80,
+		host:           "0.0.0.0",
+		telemetryPort:  81,
+		telemetryHost:  "0.0.0.0",
+		collectors:     collectorSet{},
+		labelSelectors: newLabelSelectorMap(),
+		fieldSelectors: newFieldSelectorMap(),
+		totalShards:    1,
+		watchTimeout:   5 * time.Minute,
+		authURL:        metricsPath,
+	}
+}
+
+func TestParseConfigPrecedence(t *testing.T) {
+	opts := testOptions()
+	opts.port = 8080
+	opts.apiPageSize = 100
+	opts.metricDeny = "kube_pod_labels"
+	opts.labelSelectors.Set("pods=app=web")
+	base := configFromOptions(opts, collectorSet{"pods": {}, "nodes": {}}, namespaceList{"ns1"})
+
+	c, err := parseConfig("config.yaml", []byte("port: 9090\ntelemetryPort: 9091\nkubeAPITimeout: 30s\n"), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings in the file take precedence over the flags.
+	if c.Port != 9090 || c.TelemetryPort != 9091 || c.KubeAPITimeout.Duration != 30*time.Second {
+		t.Errorf("expected the port, telemetry port and timeout of the file, got %d, %d and %s", c.Port, c.TelemetryPort, c.KubeAPITimeout.Duration)
+	}
+	// Settings missing from the file keep the value of the flags.
+	if c.KubeAPIPageSize != 100 || c.Host != "0.0.0.0" || c.KubeAPIQPS != 5 {
+		t.Errorf("expected the page size, host and QPS of the flags, got %d, %q and %v", c.KubeAPIPageSize, c.Host, c.KubeAPIQPS)
+	}
+	if !reflect.DeepEqual(c.Namespaces, []string{"ns1"}) {
+		t.Errorf("expected the namespaces of the flags, got %v", c.Namespaces)
+	}
+	if !reflect.DeepEqual(c.MetricDenylist, []string{"kube_pod_labels"}) {
+		t.Errorf("expected the metric denylist of the flags, got %v", c.MetricDenylist)
+	}
+	if !reflect.DeepEqual(c.Collectors, base.Collectors) {
+		t.Errorf("expected the collectors of the flags %v, got %v", base.Collectors, c.Collectors)
+	}
+	if c.Collectors["pods"].LabelSelector != "app=web" {
+		t.Errorf("expected the pods label selector of the flags, got %q", c.Collectors["pods"].LabelSelector)
+	}
+}
+
+func TestParseConfigReplacesLists(t *testing.T) {
+	opts := testOptions()
+	opts.metricAllow = "kube_pod_.*,kube_node_.*"
+	opts.labelSelectors.Set("pods=app=web")
+	base := configFromOptions(opts, collectorSet{"pods": {}, "nodes": {}}, namespaceList{"ns1", "ns2"})
+
+	cases := []struct {
+		data            string
+		namespaces      []string
+		metricAllowlist []string
+		collectors      map[string]collectorConfig
+	}{
+		{
+			data:            "namespaces: [ns3]\nmetricAllowlist: [kube_deployment_.*]\n",
+			namespaces:      []string{"ns3"},
+			metricAllowlist: []string{"kube_deployment_.*"},
+			collectors:      base.Collectors,
+		},
+		// An empty list in the file replaces the list of the flags too.
+		{
+			data:            "namespaces: []\nmetricAllowlist: []\n",
+			namespaces:      []string{},
+			metricAllowlist: []string{},
+			collectors:      base.Collectors,
+		},
+		// The collectors section replaces the collectors of the flags as a
+		// whole, including their selectors.
+		{
+			data:            "collectors:\n  deployments:\n    namespaces: [ns4]\n  pods: {}\n",
+			namespaces:      []string{"ns1", "ns2"},
+			metricAllowlist: []string{"kube_pod_.*", "kube_node_.*"},
+			collectors: map[string]collectorConfig{
+				"deployments": {Namespaces: []string{"ns4"}},
+				"pods":        {},
+			},
+		},
+	}
+
+	for i, c := range cases {
+		got, err := parseConfig("config.yaml", []byte(c.data), base)
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Namespaces, c.namespaces) {
+			t.Errorf("case %d: expected namespaces %v, got %v", i, c.namespaces, got.Namespaces)
+		}
+		if !reflect.DeepEqual(got.MetricAllowlist, c.metricAllowlist) {
+			t.Errorf("case %d: expected metric allowlist %v, got %v", i, c.metricAllowlist, got.MetricAllowlist)
+		}
+		if !reflect.DeepEqual(got.Collectors, c.collectors) {
+			t.Errorf("case %d: expected collectors %v, got %v", i, c.collectors, got.Collectors)
+		}
+	}
+	if !reflect.DeepEqual(base.Namespaces, []string{"ns1", "ns2"}) {
+		t.Errorf("parsing the config changed the namespaces of the flags to %v", base.Namespaces)
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	base := configFromOptions(testOptions(), collectorSet{"pods": {}}, nil)
+
+	cases := []string{
+		"port: [80]",
+		"kubeAPITimeout: 30",
+		"kubeAPITimeout: 30 seconds",
+		"totalShards: 0",
+		"shard: 2\ntotalShards: 2",
+		"shard: -1",
+		"kubeAPIQPS: 0",
+		"kubeAPIBurst: -1",
+		"kubeAPITimeout: -1s",
+		"kubeAPIContentType: text/plain",
+		"kubeAPIPageSize: 0",
+		"eventSeriesTTL: 0s",
+		"maxSeriesPerFamily: -1",
+		"tlsCertFile: /etc/tls/tls.crt",
+		"tlsPrivateKeyFile: /etc/tls/tls.key",
+		"clientCAFile: /etc/tls/ca.crt",
+		"authTokenReview: true\nauthNonResourceURL: \"\"",
+		"metricAllowlist: [\"kube_pod_(\"]",
+		"metricDenylist: [\"kube_pod_[\"]",
+		"collectors:\n  nonexisting: {}",
+		"collectors:\n  pods:\n    labelSelector: \"app in\"",
+		"collectors:\n  pods:\n    fieldSelector: spec.nodeName",
+		"customResources:\n- group: example.com\n  version: v1\n  kind: Widget\n  metrics:\n  - name: info\n- group: example.com\n  version: v2\n  kind: Widget\n  metrics:\n  - name: info\n",
+	}
+
+	for _, data := range cases {
+		if _, err := parseConfig("config.yaml", []byte(data), base); err == nil {
+			t.Errorf("expected an error for config %q", data)
+		}
+	}
+}
+
+func TestCustomResourceCollectorNames(t *testing.T) {
+	base := configFromOptions(testOptions(), collectorSet{"services": {}}, nil)
+	data := `
+customResources:
+- group: example.com
+  version: v1
+  kind: Widget
+  labelSelector: app=web
+  metrics:
+  - name: info
+- group: example.org
+  version: v1
+  kind: Widget
+  metrics:
+  - name: info
+- group: serving.knative.dev
+  version: v1
+  kind: Service
+  metrics:
+  - name: info
+`
+	c, err := parseConfig("config.yaml", []byte(data), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Custom resources sharing a plural with each other or with a built-in
+	// collector get collectors of their own.
+	settings := c.collectorSettings()
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"services", "services.serving.knative.dev", "widgets.example.com", "widgets.example.org"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected collectors %v, got %v", want, names)
+	}
+	if settings["services"].customResource != nil {
+		t.Error("expected the built-in services collector to be kept")
+	}
+
+	// The selectors apply to the plural resource name the informers watch.
+	opts, err := settings["widgets.example.com"].options("widgets.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"widgets": "app=web"}; !reflect.DeepEqual(opts.LabelSelectors, want) {
+		t.Errorf("expected label selectors %v, got %v", want, opts.LabelSelectors)
+	}
+}
+
+func TestAllowListMapSet(t *testing.T) {
+	cases := []struct {
+		values []string
+		want   allowListMap
+		err    bool
+	}{
+		{
+			values: []string{"pods=[app,team],nodes=[*]"},
+			want:   allowListMap{"pods": {"app", "team"}, "nodes": {"*"}},
+		},
+		{
+			values: []string{" pods=[ app , team ] , nodes=[]"},
+			want:   allowListMap{"pods": {"app", "team"}, "nodes": {}},
+		},
+		// Repeated flags add collectors, a later entry for the same
+		// collector replaces the earlier one.
+		{
+			values: []string{"pods=[app]", "nodes=[zone]", "pods=[team]"},
+			want:   allowListMap{"pods": {"team"}, "nodes": {"zone"}},
+		},
+		{values: []string{"pods=app"}, err: true},
+		{values: []string{"pods=[app"}, err: true},
+		{values: []string{"pods]app["}, err: true},
+		{values: []string{"nonexisting=[app]"}, err: true},
+	}
+
+	for i, c := range cases {
+		var m allowListMap
+		var err error
+		for _, value := range c.values {
+			if err = m.Set(value); err != nil {
+				break
+			}
+		}
+		if c.err {
+			if err == nil {
+				t.Errorf("case %d: expected an error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, c.want) {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, m)
+		}
+	}
+}
+
+func TestReloaderRestartsChangedCollectors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksm-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	registered := map[string]int{}
+	register := func(name string) kcollectors.RegisterFunc {
+		return func(*kcollectors.Registry, kubernetes.Interface, []string, *kcollectors.Options) {
+			registered[name]++
+		}
+	}
+
+	registry := kcollectors.NewRegistry()
+	r := newReloader(path, configFromOptions(testOptions(), collectorSet{}, nil), registry, nil, nil, nil)
+	r.collectors = map[string]kcollectors.RegisterFunc{
+		"pods":        register("pods"),
+		"nodes":       register("nodes"),
+		"deployments": register("deployments"),
+	}
+
+	steps := []struct {
+		data       string
+		force      bool
+		registered map[string]int
+		enabled    []string
+	}{
+		{
+			data:       "collectors:\n  pods:\n    labelSelector: app=web\n  nodes: {}\n",
+			force:      true,
+			registered: map[string]int{"pods": 1, "nodes": 1},
+			enabled:    []string{"nodes", "pods"},
+		},
+		// Only the collector whose settings changed is restarted.
+		{
+			data:       "collectors:\n  pods:\n    labelSelector: app=db\n  nodes: {}\n",
+			registered: map[string]int{"pods": 2, "nodes": 1},
+			enabled:    []string{"nodes", "pods"},
+		},
+		// Added collectors are started and removed ones disabled.
+		{
+			data:       "collectors:\n  pods:\n    labelSelector: app=db\n  deployments: {}\n",
+			registered: map[string]int{"pods": 2, "nodes": 1, "deployments": 1},
+			enabled:    []string{"deployments", "pods"},
+		},
+		// Settings that do not affect collectors restart nothing.
+		{
+			data:       "port: 8080\ncollectors:\n  pods:\n    labelSelector: app=db\n  deployments: {}\n",
+			registered: map[string]int{"pods": 2, "nodes": 1, "deployments": 1},
+			enabled:    []string{"deployments", "pods"},
+		},
+		// Global settings apply to every collector.
+		{
+			data:       "port: 8080\nmetricDenylist: [kube_pod_labels]\ncollectors:\n  pods:\n    labelSelector: app=db\n  deployments: {}\n",
+			registered: map[string]int{"pods": 3, "nodes": 1, "deployments": 2},
+			enabled:    []string{"deployments", "pods"},
+		},
+		// An invalid config leaves the collectors as they are.
+		{
+			data:       "collectors:\n  pods:\n    labelSelector: \"app in\"\n",
+			registered: map[string]int{"pods": 3, "nodes": 1, "deployments": 2},
+			enabled:    []string{"deployments", "pods"},
+		},
+	}
+
+	for i, s := range steps {
+		if err := ioutil.WriteFile(path, []byte(s.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := r.reload(s.force)
+		if i == len(steps)-1 {
+			if err == nil {
+				t.Errorf("step %d: expected an error", i)
+			}
+		} else if err != nil {
+			t.Errorf("step %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(registered, s.registered) {
+			t.Errorf("step %d: expected registrations %v, got %v", i, s.registered, registered)
+		}
+		if enabled := registry.Enabled(); !reflect.DeepEqual(enabled, s.enabled) {
+			t.Errorf("step %d: expected enabled collectors %v, got %v", i, s.enabled, enabled)
+		}
+	}
+
+	// An unchanged file is not parsed and applied again.
+	if err := r.reload(false); err != nil {
+		t.Fatal(err)
+	}
+	if registered["pods"] != 3 {
+		t.Errorf("expected no restart for an unchanged file, got %d registrations of pods", registered["pods"])
+	}
+}
+
+// fakeDiscovery serves the resources in lists.
+type fakeDiscovery struct {
+	discovery.ServerResourcesInterface
+	lists []*metav1.APIResourceList
+}
+
+func (d *fakeDiscovery) ServerResources() ([]*metav1.APIResourceList, error) {
+	return d.lists, nil
+}
+
+func TestReloaderDiscoversAddedResources(t *testing.T) {
+	d := &fakeDiscovery{lists: []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods"}}},
+	}}
+	registered := map[string]int{}
+	register := func(name string) kcollectors.RegisterFunc {
+		return func(*kcollectors.Registry, kubernetes.Interface, []string, *kcollectors.Options) {
+			registered[name]++
+		}
+	}
+
+	r := newReloader("", config{}, kcollectors.NewRegistry(), nil, nil, d)
+	r.collectors = map[string]kcollectors.RegisterFunc{
+		"pods":        register("pods"),
+		"deployments": register("deployments"),
+	}
+	c := configFromOptions(testOptions(), collectorSet{"pods": {}, "deployments": {}}, nil)
+
+	if err := r.apply(c); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"pods": 1}; !reflect.DeepEqual(registered, want) {
+		t.Errorf("expected registrations %v, got %v", want, registered)
+	}
+	if r.reasons["deployments"] != reasonNotServed {
+		t.Errorf("expected deployments not to be served, got reason %q", r.reasons["deployments"])
+	}
+
+	// Applying the same config again starts the collectors of resources
+	// served since.
+	d.lists = append(d.lists, &metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}}})
+	if err := r.apply(c); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"pods": 1, "deployments": 1}; !reflect.DeepEqual(registered, want) {
+		t.Errorf("expected registrations %v, got %v", want, registered)
+	}
+	if r.reasons["deployments"] == reasonNotServed {
+		t.Errorf("expected deployments to be served after discovering them again")
+	}
+}
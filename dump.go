@@ -0,0 +1,166 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	kcollectors "k8s.io/kube-state-metrics/collectors"
+)
+
+// syncCheckInterval is how often dump checks whether the caches synced.
+const syncCheckInterval = 100 * time.Millisecond
+
+// Formats dump writes the metrics in.
+const (
+	dumpFormatText = "text"
+	dumpFormatJSON = "json"
+)
+
+// jsonMetricFamily is the JSON representation of a metric family written by
+// dump.
+type jsonMetricFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// dump waits for the caches of all collectors of registry to sync, and
+// writes their metrics once to path, or to stdout if path is empty. It fails
+// if the caches do not sync within timeout, or if a collector reported a
+// scrape error.
+func dump(registry *kcollectors.Registry, path, format string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		unsynced := registry.Unsynced()
+		if len(unsynced) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("caches did not sync within %s: %s", timeout, strings.Join(unsynced, ","))
+		}
+		time.Sleep(syncCheckInterval)
+	}
+
+	mfs, gatherErr := registry.Gather()
+
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeMetricFamilies(w, mfs, format); err != nil {
+		return fmt.Errorf("writing metrics failed: %v", err)
+	}
+	glog.Infof("Wrote %d metric families", len(mfs))
+
+	if gatherErr != nil {
+		return fmt.Errorf("gathering metrics failed: %v", gatherErr)
+	}
+	if failed := scrapeErrors(); len(failed) > 0 {
+		return fmt.Errorf("scrape errors reported for: %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+func writeMetricFamilies(w io.Writer, mfs []*dto.MetricFamily, format string) error {
+	if format == dumpFormatJSON {
+		families := make([]jsonMetricFamily, 0, len(mfs))
+		for _, mf := range mfs {
+			f := jsonMetricFamily{
+				Name:    mf.GetName(),
+				Help:    mf.GetHelp(),
+				Type:    strings.ToLower(mf.GetType().String()),
+				Metrics: make([]jsonMetric, 0, len(mf.Metric)),
+			}
+			for _, m := range mf.Metric {
+				jm := jsonMetric{Labels: map[string]string{}}
+				for _, l := range m.Label {
+					jm.Labels[l.GetName()] = l.GetValue()
+				}
+				switch {
+				case m.Gauge != nil:
+					jm.Value = m.Gauge.GetValue()
+				case m.Counter != nil:
+					jm.Value = m.Counter.GetValue()
+				case m.Untyped != nil:
+					jm.Value = m.Untyped.GetValue()
+				}
+				f.Metrics = append(f.Metrics, jm)
+			}
+			families = append(families, f)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(families)
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scrapeErrors returns the resources for which scrape errors were counted.
+func scrapeErrors() []string {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(kcollectors.ScrapeErrorTotalMetric)
+	mfs, err := reg.Gather()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	failed := []string{}
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			if m.Counter.GetValue() == 0 {
+				continue
+			}
+			for _, l := range m.Label {
+				if l.GetName() == "resource" {
+					failed = append(failed, l.GetValue())
+				}
+			}
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2018 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	kcollectors "k8s.io/kube-state-metrics/collectors"
+)
+
+var (
+	descDumpInfo = prometheus.NewDesc(
+		"kube_widget_info",
+		"Information about the widget.",
+		[]string{"namespace", "widget"}, nil,
+	)
+	descDumpRestarts = prometheus.NewDesc(
+		"kube_widget_restarts_total",
+		"Number of widget restarts.",
+		[]string{"widget"}, nil,
+	)
+)
+
+// dumpCollector collects two widget metric families and, if failing is set,
+// reports a scrape error like the collectors of resources whose listing
+// failed.
+type dumpCollector struct {
+	failing bool
+}
+
+func (c dumpCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descDumpInfo
+	ch <- descDumpRestarts
+}
+
+func (c dumpCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.failing {
+		kcollectors.ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "widget"}).Inc()
+		return
+	}
+	kcollectors.ScrapeErrorTotalMetric.With(prometheus.Labels{"resource": "widget"}).Add(0)
+	ch <- prometheus.MustNewConstMetric(descDumpInfo, prometheus.GaugeValue, 1, "ns1", "widget1")
+	ch <- prometheus.MustNewConstMetric(descDumpInfo, prometheus.GaugeValue, 1, "ns2", "widget2")
+	ch <- prometheus.MustNewConstMetric(descDumpRestarts, prometheus.CounterValue, 3, "widget1")
+}
+
+func TestWriteMetricFamilies(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(dumpCollector{})
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kcollectors.ScrapeErrorTotalMetric.Reset()
+
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: dumpFormatText,
+			want: `# HELP kube_widget_info Information about the widget.
+# TYPE kube_widget_info gauge
+kube_widget_info{namespace="ns1",widget="widget1"} 1
+kube_widget_info{namespace="ns2",widget="widget2"} 1
+# HELP kube_widget_restarts_total Number of widget restarts.
+# TYPE kube_widget_restarts_total counter
+kube_widget_restarts_total{widget="widget1"} 3
+`,
+		},
+		{
+			format: dumpFormatJSON,
+			want: `[
+  {
+    "name": "kube_widget_info",
+    "help": "Information about the widget.",
+    "type": "gauge",
+    "metrics": [
+      {
+        "labels": {
+          "namespace": "ns1",
+          "widget": "widget1"
+        },
+        "value": 1
+      },
+      {
+        "labels": {
+          "namespace": "ns2",
+          "widget": "widget2"
+        },
+        "value": 1
+      }
+    ]
+  },
+  {
+    "name": "kube_widget_restarts_total",
+    "help": "Number of widget restarts.",
+    "type": "counter",
+    "metrics": [
+      {
+        "labels": {
+          "widget": "widget1"
+        },
+        "value": 3
+      }
+    ]
+  }
+]
+`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := writeMetricFamilies(&buf, mfs, c.format); err != nil {
+			t.Errorf("format %s: unexpected error: %v", c.format, err)
+			continue
+		}
+		if got := buf.String(); got != c.want {
+			t.Errorf("format %s: expected\n%s\ngot\n%s", c.format, c.want, got)
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksm-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		failing bool
+		format  string
+		// want is contained in the output.
+		want string
+		err  string
+	}{
+		{format: dumpFormatText, want: `kube_widget_info{namespace="ns1",widget="widget1"} 1`},
+		{format: dumpFormatJSON, want: `"name": "kube_widget_info"`},
+		// A scrape error fails the dump once the output is written.
+		{failing: true, format: dumpFormatText, err: "scrape errors reported for: widget"},
+		{failing: true, format: dumpFormatJSON, err: "scrape errors reported for: widget"},
+	}
+
+	for i, c := range cases {
+		kcollectors.ScrapeErrorTotalMetric.Reset()
+		registry := kcollectors.NewRegistry()
+		registry.Enable("widgets", func(r *kcollectors.Registry) {
+			r.MustRegisterStore("widget", dumpCollector{failing: c.failing}, &kcollectors.SharedInformerList{}, func(interface{}, chan<- prometheus.Metric) {}, &kcollectors.Options{})
+		})
+		path := filepath.Join(dir, "metrics")
+
+		err := dump(registry, path, c.format, time.Second)
+		registry.Disable("widgets")
+		if c.err == "" && err != nil {
+			t.Errorf("case %d: unexpected error: %v", i, err)
+			continue
+		}
+		if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("case %d: expected an error containing %q, got %v", i, c.err, err)
+		}
+
+		out, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("case %d: reading the output failed: %v", i, err)
+			continue
+		}
+		if !strings.Contains(string(out), c.want) {
+			t.Errorf("case %d: expected the output to contain %q, got\n%s", i, c.want, out)
+		}
+	}
+	kcollectors.ScrapeErrorTotalMetric.Reset()
+}
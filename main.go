@@ -24,9 +24,11 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/openshift/origin/pkg/util/proc"
@@ -34,9 +36,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 
+	"k8s.io/kube-state-metrics/builder"
 	kcollectors "k8s.io/kube-state-metrics/collectors"
 	"k8s.io/kube-state-metrics/version"
 )
@@ -44,58 +52,16 @@ import (
 const (
 	metricsPath = "/metrics"
 	healthzPath = "/healthz"
-)
+	readyzPath  = "/readyz"
 
-var (
-	defaultCollectors = collectorSet{
-		"daemonsets":               struct{}{},
-		"deployments":              struct{}{},
-		"limitranges":              struct{}{},
-		"nodes":                    struct{}{},
-		"pods":                     struct{}{},
-		"replicasets":              struct{}{},
-		"replicationcontrollers":   struct{}{},
-		"resourcequotas":           struct{}{},
-		"services":                 struct{}{},
-		"jobs":                     struct{}{},
-		"cronjobs":                 struct{}{},
-		"statefulsets":             struct{}{},
-		"persistentvolumes":        struct{}{},
-		"persistentvolumeclaims":   struct{}{},
-		"namespaces":               struct{}{},
-		"horizontalpodautoscalers": struct{}{},
-		"endpoints":                struct{}{},
-		"secrets":                  struct{}{},
-		"configmaps":               struct{}{},
-	}
-	availableCollectors = map[string]func(registry prometheus.Registerer, kubeClient clientset.Interface, namespace string){
-		"cronjobs":                 kcollectors.RegisterCronJobCollector,
-		"daemonsets":               kcollectors.RegisterDaemonSetCollector,
-		"deployments":              kcollectors.RegisterDeploymentCollector,
-		"jobs":                     kcollectors.RegisterJobCollector,
-		"limitranges":              kcollectors.RegisterLimitRangeCollector,
-		"nodes":                    kcollectors.RegisterNodeCollector,
-		"pods":                     kcollectors.RegisterPodCollector,
-		"replicasets":              kcollectors.RegisterReplicaSetCollector,
-		"replicationcontrollers":   kcollectors.RegisterReplicationControllerCollector,
-		"resourcequotas":           kcollectors.RegisterResourceQuotaCollector,
-		"services":                 kcollectors.RegisterServiceCollector,
-		"statefulsets":             kcollectors.RegisterStatefulSetCollector,
-		"persistentvolumes":        kcollectors.RegisterPersistentVolumeCollector,
-		"persistentvolumeclaims":   kcollectors.RegisterPersistentVolumeClaimCollector,
-		"namespaces":               kcollectors.RegisterNamespaceCollector,
-		"horizontalpodautoscalers": kcollectors.RegisterHorizontalPodAutoScalerCollector,
-		"endpoints":                kcollectors.RegisterEndpointCollector,
-		"secrets":                  kcollectors.RegisterSecretCollector,
-		"configmaps":               kcollectors.RegisterConfigMapCollector,
-	}
+	contentTypeProtobuf = "application/vnd.kubernetes.protobuf"
 )
 
 // promLogger implements promhttp.Logger
 type promLogger struct{}
 
 func (pl promLogger) Println(v ...interface{}) {
-	glog.Error(v)
+	glog.Error(v...)
 }
 
 type collectorSet map[string]struct{}
@@ -111,7 +77,7 @@ func (c *collectorSet) Set(value string) error {
 	s := *c
 	cols := strings.Split(value, ",")
 	for _, col := range cols {
-		_, ok := availableCollectors[col]
+		_, ok := builder.AvailableCollectors[col]
 		if !ok {
 			glog.Fatalf("Collector \"%s\" does not exist", col)
 		}
@@ -136,21 +102,189 @@ func (c *collectorSet) Type() string {
 	return "string"
 }
 
+type namespaceList []string
+
+func (n *namespaceList) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *namespaceList) Set(value string) error {
+	for _, ns := range strings.Split(value, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || n.contains(ns) {
+			continue
+		}
+		*n = append(*n, ns)
+	}
+	return nil
+}
+
+func (n namespaceList) contains(namespace string) bool {
+	for _, ns := range n {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *namespaceList) Type() string {
+	return "string"
+}
+
+// selectorMap maps collectors to a label or field selector, set with
+// repeated <collector>=<selector> flags.
+type selectorMap struct {
+	selectors map[string]string
+	parse     func(selector string) (string, error)
+}
+
+func newLabelSelectorMap() selectorMap {
+	return selectorMap{
+		selectors: map[string]string{},
+		parse: func(selector string) (string, error) {
+			s, err := labels.Parse(selector)
+			if err != nil {
+				return "", err
+			}
+			return s.String(), nil
+		},
+	}
+}
+
+func newFieldSelectorMap() selectorMap {
+	return selectorMap{
+		selectors: map[string]string{},
+		parse: func(selector string) (string, error) {
+			s, err := fields.ParseSelector(selector)
+			if err != nil {
+				return "", err
+			}
+			return s.String(), nil
+		},
+	}
+}
+
+func (m *selectorMap) String() string {
+	ss := []string{}
+	for col, selector := range m.selectors {
+		ss = append(ss, col+"="+selector)
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, " ")
+}
+
+func (m *selectorMap) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("selector %q must be of the form <collector>=<selector>", value)
+	}
+	col := parts[0]
+	if _, ok := builder.AvailableCollectors[col]; !ok {
+		return fmt.Errorf("collector %q does not exist", col)
+	}
+	selector, err := m.parse(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid selector for collector %q: %v", col, err)
+	}
+	m.selectors[col] = selector
+	return nil
+}
+
+func (m *selectorMap) Type() string {
+	return "string"
+}
+
+// allowListMap maps collectors to allowed keys, set as a comma-separated list
+// of <collector>=[<key>,...] entries.
+type allowListMap map[string][]string
+
+func (m *allowListMap) String() string {
+	ss := []string{}
+	for col, keys := range *m {
+		ss = append(ss, col+"=["+strings.Join(keys, ",")+"]")
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, ",")
+}
+
+func (m *allowListMap) Set(value string) error {
+	if *m == nil {
+		*m = allowListMap{}
+	}
+	for value = strings.TrimSpace(value); value != ""; {
+		i := strings.Index(value, "=[")
+		j := strings.Index(value, "]")
+		if i < 0 || j < i {
+			return fmt.Errorf("%q is not of the form <collector>=[<key>,...]", value)
+		}
+		col := strings.TrimSpace(value[:i])
+		if _, ok := builder.AvailableCollectors[col]; !ok {
+			return fmt.Errorf("collector %q does not exist", col)
+		}
+		keys := []string{}
+		for _, key := range strings.Split(value[i+2:j], ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+		(*m)[col] = keys
+		value = strings.TrimPrefix(strings.TrimSpace(value[j+1:]), ",")
+	}
+	return nil
+}
+
+func (m *allowListMap) Type() string {
+	return "string"
+}
+
 type options struct {
-	apiserver     string
-	kubeconfig    string
-	help          bool
-	port          int
-	host          string
-	telemetryPort int
-	telemetryHost string
-	collectors    collectorSet
-	namespace     string
-	version       bool
+	apiserver        string
+	kubeconfig       string
+	apiQPS           float32
+	apiBurst         int
+	apiTimeout       time.Duration
+	apiContentType   string
+	apiPageSize      int64
+	eventSeriesTTL   time.Duration
+	maxSeries        int
+	help             bool
+	port             int
+	host             string
+	telemetryPort    int
+	telemetryHost    string
+	collectors       collectorSet
+	namespace        string
+	namespaces       namespaceList
+	labelSelectors   selectorMap
+	fieldSelectors   selectorMap
+	metricAllow      string
+	metricDeny       string
+	labelsAllow      allowListMap
+	annotationsAllow allowListMap
+	shard            int32
+	totalShards      int
+	watchTimeout     time.Duration
+	tlsCertFile      string
+	tlsKeyFile       string
+	clientCAFile     string
+	authTokenReview  bool
+	authURL          string
+	once             bool
+	onceOutput       string
+	onceFormat       string
+	onceTimeout      time.Duration
+	fromFiles        string
+	config           string
+	version          bool
 }
 
 func main() {
-	options := &options{collectors: make(collectorSet)}
+	options := &options{
+		collectors:     make(collectorSet),
+		labelSelectors: newLabelSelectorMap(),
+		fieldSelectors: newFieldSelectorMap(),
+	}
 	flags := pflag.NewFlagSet("", pflag.ExitOnError)
 	// add glog flags
 	flags.AddGoFlagSet(flag.CommandLine)
@@ -159,13 +293,42 @@ func main() {
 	flags.Lookup("logtostderr").NoOptDefVal = "true"
 	flags.StringVar(&options.apiserver, "apiserver", "", `The URL of the apiserver to use as a master`)
 	flags.StringVar(&options.kubeconfig, "kubeconfig", "", "Absolute path to the kubeconfig file")
+	flags.Float32Var(&options.apiQPS, "kube-api-qps", 5, "QPS to use while talking with the apiserver")
+	flags.IntVar(&options.apiBurst, "kube-api-burst", 10, "Burst to use while talking with the apiserver")
+	flags.DurationVar(&options.apiTimeout, "kube-api-timeout", 0, "Timeout of each list or list page request to the apiserver. 0 means no timeout. Watches are not bounded by it; they are ended by the apiserver")
+	flags.StringVar(&options.apiContentType, "kube-api-content-type", contentTypeProtobuf, "Content type of requests sent to the apiserver, application/vnd.kubernetes.protobuf or application/json. Custom resources are always requested as JSON")
+	flags.Int64Var(&options.apiPageSize, "kube-api-page-size", 500, "Number of objects requested per page when listing the latest version of a resource. Lists served from the apiserver watch cache are not paginated")
 	flags.BoolVarP(&options.help, "help", "h", false, "Print help text")
 	flags.IntVar(&options.port, "port", 80, `Port to expose metrics on.`)
 	flags.StringVar(&options.host, "host", "0.0.0.0", `Host to expose metrics on.`)
 	flags.IntVar(&options.telemetryPort, "telemetry-port", 81, `Port to expose kube-state-metrics self metrics on.`)
 	flags.StringVar(&options.telemetryHost, "telemetry-host", "0.0.0.0", `Host to expose kube-state-metrics self metrics on.`)
-	flags.Var(&options.collectors, "collectors", fmt.Sprintf("Comma-separated list of collectors to be enabled. Defaults to %q", &defaultCollectors))
+	flags.Var(&options.collectors, "collectors", fmt.Sprintf("Comma-separated list of collectors to be enabled. Defaults to %q", strings.Join(builder.DefaultCollectors, ",")))
 	flags.StringVar(&options.namespace, "namespace", metav1.NamespaceAll, "namespace to be enabled for collecting resources")
+	flags.MarkDeprecated("namespace", "use --namespaces instead")
+	flags.Var(&options.namespaces, "namespaces", "Comma-separated list of namespaces to be enabled for collecting resources. Defaults to all namespaces")
+	flags.Var(&options.labelSelectors, "selector", "Label selector restricting the objects watched by a collector, of the form <collector>=<selector>, e.g. pods=app!=batch. May be repeated for different collectors")
+	flags.Var(&options.fieldSelectors, "field-selector", "Field selector restricting the objects watched by a collector, of the form <collector>=<selector>, e.g. pods=status.phase!=Succeeded. May be repeated for different collectors")
+	flags.StringVar(&options.metricAllow, "metric-allowlist", "", "Comma-separated list of regular expressions matched against the whole metric family name. If set, only matching metric families are exposed")
+	flags.StringVar(&options.metricDeny, "metric-denylist", "", "Comma-separated list of regular expressions matched against the whole metric family name. Matching metric families are not exposed")
+	flags.Var(&options.labelsAllow, "labels-allowlist", "Comma-separated list of Kubernetes label keys exported on the kube_*_labels metrics per collector, e.g. pods=[app,team],nodes=[*]. If set, collectors that are not listed export no labels. Defaults to all labels of all collectors")
+	flags.Var(&options.annotationsAllow, "annotations-allowlist", "Comma-separated list of Kubernetes annotation keys exported on the kube_*_annotations metrics per collector, e.g. deployments=[owner,cost-center],pods=[*]. Collectors that are not listed export no annotations")
+	flags.Int32Var(&options.shard, "shard", 0, "The ordinal of the shard whose objects this instance exposes metrics for, starting at 0")
+	flags.IntVar(&options.totalShards, "total-shards", 1, "The number of kube-state-metrics instances the objects are sharded across")
+	flags.DurationVar(&options.watchTimeout, "watch-failure-timeout", 5*time.Minute, "How long listing or watching a resource may keep failing before the health check fails. 0 disables the check")
+	flags.IntVar(&options.maxSeries, "max-series-per-family", 0, "Maximum number of series of each metric family. Further series are dropped and counted by ksm_metric_family_series_dropped_total. 0 means no limit")
+	flags.DurationVar(&options.eventSeriesTTL, "event-series-ttl", time.Hour, "How long the events collector keeps a series without new events")
+	flags.StringVar(&options.tlsCertFile, "tls-cert-file", "", "Path to the TLS certificate to serve the metrics and telemetry endpoints with. Both are served over plain HTTP if not set")
+	flags.StringVar(&options.tlsKeyFile, "tls-private-key-file", "", "Path to the private key of --tls-cert-file")
+	flags.StringVar(&options.clientCAFile, "client-ca-file", "", "Path to the CA certificates verifying client certificates. Unless --auth-token-review is set, a verified client certificate is required for every request")
+	flags.BoolVar(&options.authTokenReview, "auth-token-review", false, "Authenticate requests with client certificates or with bearer tokens checked by TokenReviews, and authorize them with SubjectAccessReviews. The health checks are not protected")
+	flags.StringVar(&options.authURL, "auth-non-resource-url", metricsPath, "The non-resource URL users need get access to when --auth-token-review is set")
+	flags.BoolVar(&options.once, "once", false, "Wait for the caches of the enabled collectors to sync, write their metrics once and exit. Exits non-zero if a collector reported a scrape error")
+	flags.StringVar(&options.onceOutput, "once-output", "", "File to write the metrics to with --once. Defaults to stdout")
+	flags.StringVar(&options.onceFormat, "once-format", dumpFormatText, "Format to write the metrics in with --once, text or json")
+	flags.DurationVar(&options.onceTimeout, "once-timeout", 5*time.Minute, "How long to wait for the caches to sync with --once")
+	flags.StringVar(&options.fromFiles, "from-files", "", "Comma-separated list of files and directories of YAML or JSON manifests and kubectl dumps to compute the metrics from instead of a cluster. Implies --once")
+	flags.StringVar(&options.config, "config", "", "Path to a YAML config file. Its settings take precedence over the flags, and it is reloaded on SIGHUP and when it changes")
 	flags.BoolVarP(&options.version, "version", "", false, "kube-state-metrics build version information")
 
 	flags.Usage = func() {
@@ -191,20 +354,53 @@ func main() {
 	var collectors collectorSet
 	if len(options.collectors) == 0 {
 		glog.Info("Using default collectors")
-		collectors = defaultCollectors
+		collectors = collectorSet{}
+		for _, c := range builder.DefaultCollectors {
+			collectors[c] = struct{}{}
+		}
 	} else {
 		collectors = options.collectors
 	}
 
-	if options.namespace == metav1.NamespaceAll {
+	namespaces := options.namespaces
+	if options.namespace != metav1.NamespaceAll && !namespaces.contains(options.namespace) {
+		namespaces = append(namespaces, options.namespace)
+	}
+	if len(namespaces) == 0 {
 		glog.Info("Using all namespace")
 	} else {
-		glog.Infof("Using %s namespace", options.namespace)
+		glog.Infof("Using %s namespaces", namespaces.String())
+	}
+
+	cfg := configFromOptions(options, collectors, namespaces)
+	if options.config != "" {
+		cfg, err = loadConfig(options.config, cfg)
+	} else {
+		err = cfg.validate()
+	}
+	if err != nil {
+		glog.Fatalf("Error: %v", err)
+	}
+	if options.onceFormat != dumpFormatText && options.onceFormat != dumpFormatJSON {
+		glog.Fatalf("Error: unknown output format %q", options.onceFormat)
+	}
+	if cfg.TotalShards > 1 {
+		glog.Infof("Using shard %d of %d", cfg.Shard, cfg.TotalShards)
 	}
 
 	proc.StartReaper()
 
-	kubeClient, err := createKubeClient(options.apiserver, options.kubeconfig)
+	var (
+		kubeClient clientset.Interface
+		restConfig *rest.Config
+	)
+	offline := options.fromFiles != ""
+	if offline {
+		options.once = true
+		kubeClient, restConfig, err = createFileClient(splitList(options.fromFiles))
+	} else {
+		kubeClient, restConfig, err = createKubeClient(cfg)
+	}
 	if err != nil {
 		glog.Fatalf("Failed to create client: %v", err)
 	}
@@ -212,24 +408,104 @@ func main() {
 	ksmMetricsRegistry := prometheus.NewRegistry()
 	ksmMetricsRegistry.Register(kcollectors.ResourcesPerScrapeMetric)
 	ksmMetricsRegistry.Register(kcollectors.ScrapeErrorTotalMetric)
+	ksmMetricsRegistry.Register(kcollectors.CollectDurationMetric)
+	ksmMetricsRegistry.Register(kcollectors.FamilySeriesMetric)
+	ksmMetricsRegistry.Register(kcollectors.SeriesDroppedTotalMetric)
+	ksmMetricsRegistry.Register(kcollectors.ShardOrdinalMetric)
+	ksmMetricsRegistry.Register(kcollectors.TotalShardsMetric)
+	ksmMetricsRegistry.Register(kcollectors.CollectorEnabledMetric)
+	if options.config != "" {
+		ksmMetricsRegistry.Register(kcollectors.ConfigLastReloadSuccessfulMetric)
+		ksmMetricsRegistry.Register(kcollectors.ConfigLastReloadSuccessTimestampMetric)
+	}
 	ksmMetricsRegistry.Register(prometheus.NewProcessCollector(os.Getpid(), ""))
 	ksmMetricsRegistry.Register(prometheus.NewGoCollector())
-	go telemetryServer(ksmMetricsRegistry, options.telemetryHost, options.telemetryPort)
 
-	registry := prometheus.NewRegistry()
-	registerCollectors(registry, kubeClient, collectors, options.namespace)
-	metricsServer(registry, options.host, options.port)
+	auth, err := newServerAuth(cfg, kubeClient)
+	if err != nil {
+		glog.Fatalf("Failed to set up authentication: %v", err)
+	}
+	if !options.once {
+		go telemetryServer(ksmMetricsRegistry, cfg.TelemetryHost, cfg.TelemetryPort, auth)
+	}
+
+	var discoveryClient discovery.ServerResourcesInterface
+	if !offline {
+		discoveryClient = kubeClient.Discovery()
+	}
+
+	registry := kcollectors.NewRegistry()
+	reloader := newReloader(options.config, configFromOptions(options, collectors, namespaces), registry, kubeClient, restConfig, discoveryClient)
+	if options.config != "" {
+		if err := reloader.reload(true); err != nil {
+			glog.Fatalf("Error: %v", err)
+		}
+		if !options.once {
+			go reloader.run()
+		}
+	} else if err := reloader.apply(cfg); err != nil {
+		glog.Fatalf("Error: %v", err)
+	}
+
+	if options.once {
+		if err := dump(registry, options.onceOutput, options.onceFormat, options.onceTimeout); err != nil {
+			glog.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	metricsServer(registry, cfg.Host, cfg.Port, reloader.watchTimeout, auth)
 }
 
-func createKubeClient(apiserver string, kubeconfig string) (clientset.Interface, error) {
-	config, err := clientcmd.BuildConfigFromFlags(apiserver, kubeconfig)
+// compileMetricList compiles a list of regular expressions into one
+// expression matching whole metric family names. It returns nil for an empty
+// list.
+func compileMetricList(exprs []string) (*regexp.Regexp, error) {
+	alternatives := []string{}
+	for _, expr := range exprs {
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			continue
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, "(?:"+expr+")")
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + strings.Join(alternatives, "|") + ")$")
+}
+
+// splitList splits a comma-separated flag value, it returns nil for an
+// empty value.
+func splitList(value string) []string {
+	var list []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func createKubeClient(c config) (clientset.Interface, *rest.Config, error) {
+	config, err := clientcmd.BuildConfigFromFlags(c.Apiserver, c.Kubeconfig)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	config.QPS = c.KubeAPIQPS
+	config.Burst = c.KubeAPIBurst
+	config.ContentType = c.KubeAPIContentType
+	if c.KubeAPIContentType == contentTypeProtobuf {
+		// Fall back to JSON for the few built-in types without protobuf
+		// support.
+		config.AcceptContentTypes = contentTypeProtobuf + "," + runtime.ContentTypeJSON
 	}
 
 	kubeClient, err := clientset.NewForConfig(config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Informers don't seem to do a good job logging error messages when it
@@ -238,16 +514,33 @@ func createKubeClient(apiserver string, kubeconfig string) (clientset.Interface,
 	glog.Infof("Testing communication with server")
 	v, err := kubeClient.Discovery().ServerVersion()
 	if err != nil {
-		return nil, fmt.Errorf("ERROR communicating with apiserver: %v", err)
+		return nil, nil, fmt.Errorf("ERROR communicating with apiserver: %v", err)
 	}
 	glog.Infof("Running with Kubernetes cluster version: v%s.%s. git version: %s. git tree state: %s. commit: %s. platform: %s",
 		v.Major, v.Minor, v.GitVersion, v.GitTreeState, v.GitCommit, v.Platform)
 	glog.Infof("Communication with server successful")
 
-	return kubeClient, nil
+	return kubeClient, config, nil
 }
 
-func telemetryServer(registry prometheus.Gatherer, host string, port int) {
+// createFileClient creates a client whose requests are answered with the
+// objects read from the given files and directories.
+func createFileClient(paths []string) (clientset.Interface, *rest.Config, error) {
+	store, err := kcollectors.NewFileStore(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	glog.Infof("Read %d objects from %s", store.Len(), strings.Join(paths, ","))
+
+	config := store.RESTConfig()
+	kubeClient, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kubeClient, config, nil
+}
+
+func telemetryServer(registry prometheus.Gatherer, host string, port int, auth *serverAuth) {
 	// Address to listen on for web interface and telemetry
 	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
 
@@ -269,10 +562,10 @@ func telemetryServer(registry prometheus.Gatherer, host string, port int) {
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(listenAddress, mux))
+	log.Fatal(auth.listenAndServe(listenAddress, auth.handler(mux)))
 }
 
-func metricsServer(registry prometheus.Gatherer, host string, port int) {
+func metricsServer(registry *kcollectors.Registry, host string, port int, watchTimeout func() time.Duration, auth *serverAuth) {
 	// Address to listen on for web interface and telemetry
 	listenAddress := net.JoinHostPort(host, strconv.Itoa(port))
 
@@ -287,9 +580,26 @@ func metricsServer(registry prometheus.Gatherer, host string, port int) {
 	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
 
 	// Add metricsPath
-	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: promLogger{}}))
+	mux.Handle(metricsPath, registry)
 	// Add healthzPath
 	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+		if timeout := watchTimeout(); timeout > 0 {
+			if failing := registry.FailingWatches(timeout); len(failing) > 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintf(w, "watches failing for more than %s: %s", timeout, strings.Join(failing, ","))
+				return
+			}
+		}
+		w.WriteHeader(200)
+		w.Write([]byte("ok"))
+	})
+	// Add readyzPath
+	mux.HandleFunc(readyzPath, func(w http.ResponseWriter, r *http.Request) {
+		if unsynced := registry.Unsynced(); len(unsynced) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "waiting for caches to sync: %s", strings.Join(unsynced, ","))
+			return
+		}
 		w.WriteHeader(200)
 		w.Write([]byte("ok"))
 	})
@@ -302,24 +612,10 @@ func metricsServer(registry prometheus.Gatherer, host string, port int) {
 			 <ul>
              <li><a href='` + metricsPath + `'>metrics</a></li>
              <li><a href='` + healthzPath + `'>healthz</a></li>
+             <li><a href='` + readyzPath + `'>readyz</a></li>
 			 </ul>
              </body>
              </html>`))
 	})
-	log.Fatal(http.ListenAndServe(listenAddress, mux))
-}
-
-// registerCollectors creates and starts informers and initializes and
-// registers metrics for collection.
-func registerCollectors(registry prometheus.Registerer, kubeClient clientset.Interface, enabledCollectors collectorSet, namespace string) {
-	activeCollectors := []string{}
-	for c := range enabledCollectors {
-		f, ok := availableCollectors[c]
-		if ok {
-			f(registry, kubeClient, namespace)
-			activeCollectors = append(activeCollectors, c)
-		}
-	}
-
-	glog.Infof("Active collectors: %s", strings.Join(activeCollectors, ","))
+	log.Fatal(auth.listenAndServe(listenAddress, auth.handler(mux, healthzPath, readyzPath)))
 }